@@ -0,0 +1,260 @@
+package audit
+
+import (
+	"context"
+	"core-service/config"
+	"core-service/middleware"
+	"core-service/models"
+	"core-service/oauth"
+	"core-service/tlsutil"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type contextKey string
+
+const recordContextKey contextKey = "audit_record"
+
+// Record collects what a handler reports about the mutation it performed.
+// Fields left empty fall back to values derived from the request.
+type Record struct {
+	TenantID     string
+	ResourceType string
+	ResourceID   string
+	Action       string
+	Before       interface{}
+	After        interface{}
+	skip         bool
+}
+
+func recordFrom(r *http.Request) *Record {
+	record, _ := r.Context().Value(recordContextKey).(*Record)
+	return record
+}
+
+// SetResource names the resource a request acted on
+func SetResource(r *http.Request, resourceType, resourceID string) {
+	if record := recordFrom(r); record != nil {
+		record.ResourceType = resourceType
+		record.ResourceID = resourceID
+	}
+}
+
+// SetTenant records the tenant that owns the resource
+func SetTenant(r *http.Request, tenantID string) {
+	if record := recordFrom(r); record != nil {
+		record.TenantID = tenantID
+	}
+}
+
+// SetAction overrides the action derived from the HTTP method
+func SetAction(r *http.Request, action string) {
+	if record := recordFrom(r); record != nil {
+		record.Action = action
+	}
+}
+
+// SetChange records the resource state before and after the request. Either
+// side may be nil for creations and deletions.
+func SetChange(r *http.Request, before, after interface{}) {
+	if record := recordFrom(r); record != nil {
+		record.Before = before
+		record.After = after
+	}
+}
+
+// Skip marks a request that doesn't mutate anything despite its method
+func Skip(r *http.Request) {
+	if record := recordFrom(r); record != nil {
+		record.skip = true
+	}
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Middleware records an audit entry for every mutating request once the
+// handler has finished
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		record := &Record{}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		ctx := context.WithValue(r.Context(), recordContextKey, record)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		if record.skip {
+			return
+		}
+
+		entry := buildEntry(r, record, recorder.status)
+		appendCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := Append(appendCtx, &entry); err != nil {
+			log.Printf("Failed to write audit entry for %s %s: %v", r.Method, r.URL.Path, err)
+		}
+	})
+}
+
+func buildEntry(r *http.Request, record *Record, status int) models.AuditEntry {
+	actor, actorType := resolveActor(r)
+
+	entry := models.AuditEntry{
+		Timestamp:    time.Now().UTC().Truncate(time.Millisecond),
+		Actor:        actor,
+		ActorType:    actorType,
+		TenantID:     record.TenantID,
+		ResourceType: record.ResourceType,
+		ResourceID:   record.ResourceID,
+		Action:       record.Action,
+		RequestID:    middleware.RequestIDFromContext(r.Context()),
+		SourceIP:     sourceIP(r),
+		Method:       r.Method,
+		Path:         r.URL.Path,
+		StatusCode:   status,
+		Outcome:      "success",
+	}
+	if status >= 400 {
+		entry.Outcome = "failure"
+	}
+
+	if entry.ResourceType == "" {
+		entry.ResourceType = r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				entry.ResourceType = template
+			}
+		}
+	}
+	if entry.Action == "" {
+		switch r.Method {
+		case http.MethodPost:
+			entry.Action = "create"
+		case http.MethodPut, http.MethodPatch:
+			entry.Action = "update"
+		case http.MethodDelete:
+			entry.Action = "delete"
+		default:
+			entry.Action = strings.ToLower(r.Method)
+		}
+	}
+
+	before := toFields(record.Before)
+	after := toFields(record.After)
+	if record.Before != nil {
+		entry.Before = encode(before)
+	}
+	if record.After != nil {
+		entry.After = encode(after)
+	}
+	entry.Diff = diff(before, after)
+	return entry
+}
+
+// resolveActor identifies the caller from a valid bearer token, a verified
+// client certificate or an API key, in that order
+func resolveActor(r *http.Request) (string, string) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if claims, err := oauth.ParseToken(token); err == nil {
+			if claims.Subject != "" {
+				return claims.Subject, "client"
+			}
+			return claims.ServiceName, "service"
+		}
+	}
+	if san, ok := tlsutil.VerifiedPeerSAN(r.TLS, config.App.Auth.AllowedClientSANs); ok {
+		return san, "certificate"
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		// Never store the key itself
+		sum := sha256.Sum256([]byte(key))
+		return "api-key:" + hex.EncodeToString(sum[:])[:12], "api_key"
+	}
+	return "anonymous", "anonymous"
+}
+
+func sourceIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// toFields converts a resource to its JSON field map
+func toFields(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+func encode(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// diff lists the top-level fields whose values differ, sorted by name
+func diff(before, after map[string]interface{}) []models.FieldChange {
+	keys := map[string]bool{}
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	var changes []models.FieldChange
+	for key := range keys {
+		from, hadFrom := before[key]
+		to, hasTo := after[key]
+		if hadFrom && hasTo && reflect.DeepEqual(from, to) {
+			continue
+		}
+		change := models.FieldChange{Field: key}
+		if hadFrom {
+			change.From = encode(from)
+		}
+		if hasTo {
+			change.To = encode(to)
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"context"
+	"core-service/config"
+	"core-service/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const collectionName = "audit_log"
+
+// maxAppendAttempts bounds retries when replicas race for the next sequence number
+const maxAppendAttempts = 10
+
+// EnsureIndexes creates the audit log indexes. The unique seq index is what
+// keeps the chain linear when several replicas append at once.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := config.GetCollection(collectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "resource_type", Value: 1}, {Key: "timestamp", Value: -1}}},
+	})
+	return err
+}
+
+// Hash computes an entry's chain hash from its fields and PrevHash
+func Hash(entry models.AuditEntry) (string, error) {
+	entry.ID = bson.ObjectID{}
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Append links an entry to the end of the chain and stores it. Entries are
+// only ever inserted, never updated or deleted.
+func Append(ctx context.Context, entry *models.AuditEntry) error {
+	collection := config.GetCollection(collectionName)
+
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		var last models.AuditEntry
+		err := collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})).Decode(&last)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			entry.Seq = 1
+			entry.PrevHash = ""
+		case err != nil:
+			return err
+		default:
+			entry.Seq = last.Seq + 1
+			entry.PrevHash = last.Hash
+		}
+
+		hash, err := Hash(*entry)
+		if err != nil {
+			return err
+		}
+		entry.Hash = hash
+
+		result, err := collection.InsertOne(ctx, entry)
+		if mongo.IsDuplicateKeyError(err) {
+			// Another replica took this sequence number, link to its entry instead
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entry.ID = result.InsertedID.(bson.ObjectID)
+		return nil
+	}
+	return fmt.Errorf("gave up appending audit entry after %d attempts", maxAppendAttempts)
+}
+
+// Verify walks the chain in order and returns the sequence number of the
+// first entry whose hash or link doesn't match, or 0 if the chain is intact
+func Verify(ctx context.Context) (int64, int64, error) {
+	collection := config.GetCollection(collectionName)
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var checked int64
+	prevHash := ""
+	expectedSeq := int64(1)
+	for cursor.Next(ctx) {
+		var entry models.AuditEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return 0, checked, err
+		}
+		hash, err := Hash(entry)
+		if err != nil {
+			return 0, checked, err
+		}
+		if entry.Seq != expectedSeq || entry.PrevHash != prevHash || entry.Hash != hash {
+			return entry.Seq, checked, nil
+		}
+		checked++
+		prevHash = entry.Hash
+		expectedSeq++
+	}
+	return 0, checked, cursor.Err()
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Secrets are kept out of Config and read from App.Reload.ConfigDir, a
+// directory of files named after settings as mounted from a Kubernetes Secret
+// volume, falling back to environment variables. They are re-read
+// periodically and on SIGHUP. After a rotation the previous value is still
+// accepted for App.Reload.SecretGracePeriod, so tokens already in flight keep
+// working.
+
+// rotatingSecret keeps the current value of a secret and, for a grace period
+// after rotation, the previous one
+type rotatingSecret struct {
+	mu            sync.RWMutex
+	current       []byte
+	previous      []byte
+	previousUntil time.Time
+}
+
+// set replaces the secret and reports whether an existing value was rotated
+func (s *rotatingSecret) set(value []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if string(value) == string(s.current) {
+		return false
+	}
+	rotated := len(s.current) > 0
+	if rotated {
+		s.previous = s.current
+		s.previousUntil = time.Now().Add(App.Reload.SecretGracePeriod)
+	}
+	s.current = value
+	return rotated
+}
+
+// accepted returns the current secret followed by the previous one while it
+// is still within its grace period
+func (s *rotatingSecret) accepted() [][]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var values [][]byte
+	if len(s.current) > 0 {
+		values = append(values, s.current)
+	}
+	if len(s.previous) > 0 && time.Now().Before(s.previousUntil) {
+		values = append(values, s.previous)
+	}
+	return values
+}
+
+var jwtSecret rotatingSecret
+var oauthAdminClientSecret rotatingSecret
+var reloadMu sync.Mutex
+
+// JWTSecrets returns the shared secrets accepted for legacy service tokens
+func JWTSecrets() [][]byte {
+	return jwtSecret.accepted()
+}
+
+// OAuthAdminClientSecrets returns the accepted secrets of the bootstrap admin client
+func OAuthAdminClientSecrets() [][]byte {
+	return oauthAdminClientSecret.accepted()
+}
+
+// lookup reads a setting from the config directory, falling back to the environment
+func lookup(key string) string {
+	if App.Reload.ConfigDir != "" {
+		if data, err := os.ReadFile(filepath.Join(App.Reload.ConfigDir, key)); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return os.Getenv(key)
+}
+
+// Reload re-reads the reloadable settings. A missing JWT_SECRET keeps the
+// previous value so a half-written volume can't lock callers out.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	secret := lookup("JWT_SECRET")
+	if secret == "" {
+		return errors.New("JWT_SECRET is required")
+	}
+	if jwtSecret.set([]byte(secret)) {
+		log.Printf("JWT secret rotated, previous secret accepted for %s", App.Reload.SecretGracePeriod)
+	}
+
+	if oauthAdminClientSecret.set([]byte(lookup("OAUTH_ADMIN_CLIENT_SECRET"))) {
+		log.Printf("OAuth admin client secret rotated, previous secret accepted for %s", App.Reload.SecretGracePeriod)
+	}
+	return nil
+}
+
+// WatchConfig reloads secrets on SIGHUP and, when a config directory is set,
+// every App.Reload.Interval
+func WatchConfig() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if App.Reload.ConfigDir != "" {
+		ticker := time.NewTicker(App.Reload.Interval)
+		tick = ticker.C
+	}
+
+	go func() {
+		for {
+			select {
+			case <-hup:
+				log.Println("SIGHUP received, reloading configuration")
+			case <-tick:
+			}
+			if err := Reload(); err != nil {
+				log.Printf("Configuration reload failed, keeping previous values: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting binds one configuration value to its environment variable. The
+// command-line flag name is derived from it, e.g. MONGO_URI -> -mongo-uri.
+type setting struct {
+	env   string
+	usage string
+	set   func(string) error
+}
+
+func (s setting) flagName() string {
+	return strings.ReplaceAll(strings.ToLower(s.env), "_", "-")
+}
+
+func stringSetting(env, usage string, target *string) setting {
+	return setting{env: env, usage: usage, set: func(value string) error {
+		*target = value
+		return nil
+	}}
+}
+
+func intSetting(env, usage string, target *int) setting {
+	return setting{env: env, usage: usage, set: func(value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*target = parsed
+		return nil
+	}}
+}
+
+func uint64Setting(env, usage string, target *uint64) setting {
+	return setting{env: env, usage: usage, set: func(value string) error {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*target = parsed
+		return nil
+	}}
+}
+
+func durationSetting(env, usage string, target *time.Duration) setting {
+	return setting{env: env, usage: usage, set: func(value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*target = parsed
+		return nil
+	}}
+}
+
+func boolSetting(env, usage string, target *bool) setting {
+	return setting{env: env, usage: usage, set: func(value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*target = parsed
+		return nil
+	}}
+}
+
+func listSetting(env, usage string, target *[]string) setting {
+	return setting{env: env, usage: usage, set: func(value string) error {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*target = items
+		return nil
+	}}
+}
@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"core-service/config"
+	"core-service/models"
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Domain event types
+const (
+	TenantCreated  = "TenantCreated"
+	TenantUpdated  = "TenantUpdated"
+	TenantDeleted  = "TenantDeleted"
+	ProjectCreated = "ProjectCreated"
+	ProjectUpdated = "ProjectUpdated"
+	ProjectDeleted = "ProjectDeleted"
+	ServiceCreated = "ServiceCreated"
+	ServiceUpdated = "ServiceUpdated"
+	ServiceDeleted = "ServiceDeleted"
+
+	ServiceConfigUpdated = "ServiceConfigUpdated"
+	SettingsUpdated      = "SettingsUpdated"
+
+	EnvironmentCreated = "EnvironmentCreated"
+	EnvironmentUpdated = "EnvironmentUpdated"
+	EnvironmentDeleted = "EnvironmentDeleted"
+
+	DependencyAdded   = "DependencyAdded"
+	DependencyRemoved = "DependencyRemoved"
+
+	// Reported by deployment-service
+	DeploymentStarted   = "DeploymentStarted"
+	DeploymentSucceeded = "DeploymentSucceeded"
+	DeploymentFailed    = "DeploymentFailed"
+)
+
+// ExternalTypes are the event types other services may publish through
+// POST /events
+var ExternalTypes = []string{DeploymentStarted, DeploymentSucceeded, DeploymentFailed}
+
+const (
+	outboxCollection = "outbox"
+	feedCollection   = "events"
+)
+
+// Enqueue writes an event to the outbox. Call it with the context of the
+// transaction making the change, so the event is stored if and only if the
+// change is.
+func Enqueue(ctx context.Context, eventType, aggregateType string, aggregateID, tenantID bson.ObjectID, payload interface{}) error {
+	event := models.Event{
+		ID:            bson.NewObjectID(),
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID.Hex(),
+		OccurredAt:    time.Now().UTC(),
+	}
+	if !tenantID.IsZero() {
+		event.TenantID = tenantID.Hex()
+	}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		event.Payload = data
+	}
+
+	_, err := config.GetCollection(outboxCollection).InsertOne(ctx, event)
+	return err
+}
@@ -0,0 +1,141 @@
+package events
+
+import (
+	"context"
+	"core-service/config"
+	"core-service/models"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// publishedRetention is how long relayed events stay in the outbox
+const publishedRetention = 24 * time.Hour
+
+// maxPublishAttempts bounds retries when replicas race for the next sequence number
+const maxPublishAttempts = 10
+
+// StartRelay creates the outbox and feed indexes and starts relaying outbox
+// events to the feed every EVENT_RELAY_INTERVAL
+func StartRelay() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := config.GetCollection(outboxCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "published_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(publishedRetention.Seconds()))},
+		{Keys: bson.D{{Key: "occurred_at", Value: 1}}, Options: options.Index().SetPartialFilterExpression(bson.M{"published_at": bson.M{"$exists": false}})},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = config.GetCollection(feedCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "seq", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.App.Events.RelayInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := RelayPending(context.Background()); err != nil {
+				log.Printf("Event relay failed: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// RelayPending publishes unpublished outbox events to the feed, oldest first,
+// and returns how many were published. Every replica runs the relay; the
+// unique seq index keeps the feed gap-free and each event appears once.
+func RelayPending(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	outbox := config.GetCollection(outboxCollection)
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "occurred_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(config.App.Events.RelayBatch))
+	cursor, err := outbox.Find(ctx, bson.M{"published_at": bson.M{"$exists": false}}, findOptions)
+	if err != nil {
+		return 0, err
+	}
+	var pending []models.Event
+	if err := cursor.All(ctx, &pending); err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, event := range pending {
+		if err := publish(ctx, event); err != nil {
+			return published, fmt.Errorf("failed to publish event %s: %v", event.ID.Hex(), err)
+		}
+		now := time.Now()
+		if _, err := outbox.UpdateOne(ctx, bson.M{"_id": event.ID}, bson.M{"$set": bson.M{"published_at": now}}); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+// publish appends an event to the feed with the next sequence number. The
+// feed reuses the outbox _id, so an event another replica already published
+// is detected instead of being appended twice.
+func publish(ctx context.Context, event models.Event) error {
+	feed := config.GetCollection(feedCollection)
+
+	for attempt := 0; attempt < maxPublishAttempts; attempt++ {
+		var last models.Event
+		err := feed.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})).Decode(&last)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			event.Seq = 1
+		case err != nil:
+			return err
+		default:
+			event.Seq = last.Seq + 1
+		}
+
+		_, err = feed.InsertOne(ctx, event)
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		if count, err := feed.CountDocuments(ctx, bson.M{"_id": event.ID}); err != nil {
+			return err
+		} else if count > 0 {
+			return nil
+		}
+		// Another replica took this sequence number, try the next one
+	}
+	return fmt.Errorf("gave up after %d attempts", maxPublishAttempts)
+}
+
+// Feed returns up to limit events published after the given sequence number
+func Feed(ctx context.Context, filter bson.M, since int64, limit int) ([]models.Event, error) {
+	query := bson.M{"seq": bson.M{"$gt": since}}
+	for key, value := range filter {
+		query[key] = value
+	}
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "seq", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := config.GetCollection(feedCollection).Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	var feed []models.Event
+	if err := cursor.All(ctx, &feed); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
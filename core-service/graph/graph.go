@@ -0,0 +1,190 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Graph is a directed graph of services, with an edge from each service to
+// every service it depends on
+type Graph struct {
+	upstream   map[bson.ObjectID][]bson.ObjectID
+	downstream map[bson.ObjectID][]bson.ObjectID
+}
+
+// Edge is a dependency of Service on DependsOn
+type Edge struct {
+	Service   bson.ObjectID
+	DependsOn bson.ObjectID
+}
+
+// Node is a service reached from another one, Depth edges away
+type Node struct {
+	ID    bson.ObjectID
+	Depth int
+}
+
+// CycleError reports a set of dependencies that depend on each other
+type CycleError struct {
+	Path []bson.ObjectID
+}
+
+func (e *CycleError) Error() string {
+	path := ""
+	for i, id := range e.Path {
+		if i > 0 {
+			path += " -> "
+		}
+		path += id.Hex()
+	}
+	return "dependency cycle: " + path
+}
+
+// New builds a graph from its edges
+func New(edges []Edge) *Graph {
+	g := &Graph{
+		upstream:   map[bson.ObjectID][]bson.ObjectID{},
+		downstream: map[bson.ObjectID][]bson.ObjectID{},
+	}
+	for _, edge := range edges {
+		g.upstream[edge.Service] = append(g.upstream[edge.Service], edge.DependsOn)
+		g.downstream[edge.DependsOn] = append(g.downstream[edge.DependsOn], edge.Service)
+	}
+	return g
+}
+
+// PathTo returns the shortest chain of dependencies leading from one service
+// to another, both included, or nil if there is none. Adding an edge from
+// to to from closes a cycle exactly when there is such a path.
+func (g *Graph) PathTo(from, to bson.ObjectID) []bson.ObjectID {
+	previous := map[bson.ObjectID]bson.ObjectID{from: from}
+	queue := []bson.ObjectID{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == to {
+			path := []bson.ObjectID{to}
+			for current != from {
+				current = previous[current]
+				path = append([]bson.ObjectID{current}, path...)
+			}
+			return path
+		}
+		for _, next := range g.upstream[current] {
+			if _, seen := previous[next]; !seen {
+				previous[next] = current
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// Upstream returns the services a service depends on, directly or, with
+// transitive set, through other services
+func (g *Graph) Upstream(id bson.ObjectID, transitive bool) []Node {
+	return walk(g.upstream, id, transitive)
+}
+
+// Downstream returns the services that depend on a service, directly or,
+// with transitive set, through other services
+func (g *Graph) Downstream(id bson.ObjectID, transitive bool) []Node {
+	return walk(g.downstream, id, transitive)
+}
+
+// walk visits the services reachable from start breadth first, so each is
+// returned once at its shortest depth
+func walk(adjacent map[bson.ObjectID][]bson.ObjectID, start bson.ObjectID, transitive bool) []Node {
+	nodes := []Node{}
+	seen := map[bson.ObjectID]bool{start: true}
+	frontier := []bson.ObjectID{start}
+	for depth := 1; len(frontier) > 0; depth++ {
+		var next []bson.ObjectID
+		for _, id := range frontier {
+			for _, neighbour := range adjacent[id] {
+				if seen[neighbour] {
+					continue
+				}
+				seen[neighbour] = true
+				nodes = append(nodes, Node{ID: neighbour, Depth: depth})
+				next = append(next, neighbour)
+			}
+		}
+		if !transitive {
+			break
+		}
+		frontier = next
+	}
+	return nodes
+}
+
+// Waves orders services so that each comes after everything it depends on.
+// Services within a wave don't depend on each other and can be deployed in
+// parallel once the waves before them are done. Dependencies on services
+// outside of ids are followed, so that a dependency through another project
+// still orders the services it connects. Repeated IDs are ordered once, and
+// waves are sorted by ID to keep the order stable.
+func (g *Graph) Waves(services []bson.ObjectID) ([][]bson.ObjectID, error) {
+	include := map[bson.ObjectID]bool{}
+	var ids []bson.ObjectID
+	for _, id := range services {
+		if !include[id] {
+			include[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	// Depend on the nearest included services upstream, looking through
+	// services that aren't included
+	dependsOn := map[bson.ObjectID]map[bson.ObjectID]bool{}
+	for _, id := range ids {
+		dependsOn[id] = map[bson.ObjectID]bool{}
+		seen := map[bson.ObjectID]bool{id: true}
+		queue := append([]bson.ObjectID{}, g.upstream[id]...)
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			if seen[current] {
+				continue
+			}
+			seen[current] = true
+			if include[current] {
+				dependsOn[id][current] = true
+				continue
+			}
+			queue = append(queue, g.upstream[current]...)
+		}
+	}
+
+	var waves [][]bson.ObjectID
+	done := map[bson.ObjectID]bool{}
+	for len(done) < len(ids) {
+		var wave []bson.ObjectID
+		for _, id := range ids {
+			if done[id] {
+				continue
+			}
+			ready := true
+			for dependency := range dependsOn[id] {
+				if !done[dependency] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				wave = append(wave, id)
+			}
+		}
+		if len(wave) == 0 {
+			return nil, fmt.Errorf("dependency cycle among %d services", len(ids)-len(done))
+		}
+		sort.Slice(wave, func(i, j int) bool { return wave[i].Hex() < wave[j].Hex() })
+		for _, id := range wave {
+			done[id] = true
+		}
+		waves = append(waves, wave)
+	}
+	return waves, nil
+}
@@ -0,0 +1,181 @@
+package graph
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// services returns n service IDs in ascending order, so waves sorted by ID
+// can be written out in the order they are expected
+func services(n int) []bson.ObjectID {
+	ids := make([]bson.ObjectID, n)
+	for i := range ids {
+		ids[i] = bson.NewObjectID()
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Hex() < ids[j].Hex() })
+	return ids
+}
+
+func TestWaves(t *testing.T) {
+	s := services(6)
+	a, b, c, d, outside, alsoOutside := s[0], s[1], s[2], s[3], s[4], s[5]
+
+	tests := []struct {
+		name  string
+		edges []Edge
+		ids   []bson.ObjectID
+		want  [][]bson.ObjectID
+	}{
+		{
+			name: "no dependencies",
+			ids:  []bson.ObjectID{c, a, b},
+			want: [][]bson.ObjectID{{a, b, c}},
+		},
+		{
+			name:  "chain",
+			edges: []Edge{{Service: a, DependsOn: b}, {Service: b, DependsOn: c}},
+			ids:   []bson.ObjectID{a, b, c},
+			want:  [][]bson.ObjectID{{c}, {b}, {a}},
+		},
+		{
+			name:  "diamond",
+			edges: []Edge{{Service: a, DependsOn: b}, {Service: a, DependsOn: c}, {Service: b, DependsOn: d}, {Service: c, DependsOn: d}},
+			ids:   []bson.ObjectID{d, c, b, a},
+			want:  [][]bson.ObjectID{{d}, {b, c}, {a}},
+		},
+		{
+			name:  "through a service outside the project",
+			edges: []Edge{{Service: a, DependsOn: outside}, {Service: outside, DependsOn: b}},
+			ids:   []bson.ObjectID{a, b},
+			want:  [][]bson.ObjectID{{b}, {a}},
+		},
+		{
+			name:  "through several services outside the project",
+			edges: []Edge{{Service: a, DependsOn: outside}, {Service: outside, DependsOn: alsoOutside}, {Service: alsoOutside, DependsOn: b}, {Service: b, DependsOn: c}},
+			ids:   []bson.ObjectID{a, b, c},
+			want:  [][]bson.ObjectID{{c}, {b}, {a}},
+		},
+		{
+			name:  "dependency only outside the project",
+			edges: []Edge{{Service: a, DependsOn: outside}, {Service: b, DependsOn: alsoOutside}},
+			ids:   []bson.ObjectID{b, a},
+			want:  [][]bson.ObjectID{{a, b}},
+		},
+		{
+			name:  "cycle entirely outside the project",
+			edges: []Edge{{Service: a, DependsOn: outside}, {Service: outside, DependsOn: alsoOutside}, {Service: alsoOutside, DependsOn: outside}},
+			ids:   []bson.ObjectID{a, b},
+			want:  [][]bson.ObjectID{{a, b}},
+		},
+		{
+			name:  "repeated IDs",
+			edges: []Edge{{Service: a, DependsOn: b}},
+			ids:   []bson.ObjectID{a, b, a, b, b},
+			want:  [][]bson.ObjectID{{b}, {a}},
+		},
+		{
+			name: "nothing to order",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waves, err := New(tt.edges).Waves(tt.ids)
+			if err != nil {
+				t.Fatalf("Waves: %v", err)
+			}
+			if !reflect.DeepEqual(waves, tt.want) {
+				t.Fatalf("Waves = %v, want %v", waves, tt.want)
+			}
+		})
+	}
+}
+
+func TestWavesStableOrder(t *testing.T) {
+	s := services(5)
+	g := New([]Edge{{Service: s[4], DependsOn: s[0]}, {Service: s[3], DependsOn: s[0]}})
+	want, err := g.Waves(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ids := range [][]bson.ObjectID{
+		{s[4], s[3], s[2], s[1], s[0]},
+		{s[2], s[0], s[4], s[1], s[3]},
+	} {
+		waves, err := g.Waves(ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(waves, want) {
+			t.Fatalf("Waves(%v) = %v, want %v whatever the input order", ids, waves, want)
+		}
+	}
+}
+
+func TestWavesCycle(t *testing.T) {
+	s := services(4)
+	a, b, c, outside := s[0], s[1], s[2], s[3]
+
+	tests := []struct {
+		name  string
+		edges []Edge
+		ids   []bson.ObjectID
+	}{
+		{"two services", []Edge{{Service: a, DependsOn: b}, {Service: b, DependsOn: a}}, []bson.ObjectID{a, b, c}},
+		{"three services", []Edge{{Service: a, DependsOn: b}, {Service: b, DependsOn: c}, {Service: c, DependsOn: a}}, []bson.ObjectID{a, b, c}},
+		{"through a service outside the project", []Edge{{Service: a, DependsOn: outside}, {Service: outside, DependsOn: b}, {Service: b, DependsOn: a}}, []bson.ObjectID{a, b}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if waves, err := New(tt.edges).Waves(tt.ids); err == nil {
+				t.Fatalf("Waves = %v, want a cycle error", waves)
+			}
+		})
+	}
+}
+
+func TestPathTo(t *testing.T) {
+	s := services(4)
+	a, b, c, d := s[0], s[1], s[2], s[3]
+	g := New([]Edge{{Service: a, DependsOn: b}, {Service: b, DependsOn: c}, {Service: a, DependsOn: c}})
+
+	if path := g.PathTo(a, c); !reflect.DeepEqual(path, []bson.ObjectID{a, c}) {
+		t.Fatalf("PathTo(a, c) = %v, want the direct edge", path)
+	}
+	if path := g.PathTo(b, c); !reflect.DeepEqual(path, []bson.ObjectID{b, c}) {
+		t.Fatalf("PathTo(b, c) = %v, want [b c]", path)
+	}
+	if path := g.PathTo(c, a); path != nil {
+		t.Fatalf("PathTo(c, a) = %v, want nil against the edges", path)
+	}
+	if path := g.PathTo(a, d); path != nil {
+		t.Fatalf("PathTo(a, d) = %v, want nil", path)
+	}
+}
+
+func TestUpstreamDownstream(t *testing.T) {
+	s := services(4)
+	a, b, c, d := s[0], s[1], s[2], s[3]
+	g := New([]Edge{{Service: a, DependsOn: b}, {Service: b, DependsOn: c}, {Service: a, DependsOn: c}, {Service: d, DependsOn: a}})
+
+	tests := []struct {
+		name string
+		got  []Node
+		want []Node
+	}{
+		{"direct upstream", g.Upstream(a, false), []Node{{b, 1}, {c, 1}}},
+		{"transitive upstream at the shortest depth", g.Upstream(d, true), []Node{{a, 1}, {b, 2}, {c, 2}}},
+		{"direct downstream", g.Downstream(c, false), []Node{{b, 1}, {a, 1}}},
+		{"transitive downstream", g.Downstream(c, true), []Node{{b, 1}, {a, 1}, {d, 2}}},
+		{"nothing upstream", g.Upstream(c, true), []Node{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Fatalf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"core-service/audit"
+	"core-service/config"
+	"core-service/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// auditFilter builds the Mongo filter for the tenant, resource, resource_id,
+// actor and since query parameters
+func auditFilter(r *http.Request) (bson.M, error) {
+	query := r.URL.Query()
+	filter := bson.M{}
+	if tenant := query.Get("tenant"); tenant != "" {
+		filter["tenant_id"] = tenant
+	}
+	if resource := query.Get("resource"); resource != "" {
+		filter["resource_type"] = resource
+	}
+	if resourceID := query.Get("resource_id"); resourceID != "" {
+		filter["resource_id"] = resourceID
+	}
+	if actor := query.Get("actor"); actor != "" {
+		filter["actor"] = actor
+	}
+	if since := query.Get("since"); since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, fmt.Errorf("since must be an RFC 3339 timestamp: %v", err)
+		}
+		filter["timestamp"] = bson.M{"$gte": sinceTime}
+	}
+	return filter, nil
+}
+
+// GetAuditEntries lists audit entries, newest first
+func GetAuditEntries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10 // Default limit
+	}
+
+	skip := (page - 1) * limit
+
+	filter, err := auditFilter(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid query parameters",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	collection := config.GetCollection("audit_log")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to count audit entries",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64(skip))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "seq", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch audit entries",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var entries []models.AuditEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+
+	totalPages := (int(totalCount) + limit - 1) / limit
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        entries,
+		"page":        page,
+		"limit":       limit,
+		"total_count": totalCount,
+		"total_pages": totalPages,
+	})
+}
+
+// ExportAuditEntries streams every matching entry as JSON Lines, oldest first
+func ExportAuditEntries(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilter(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid query parameters",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	collection := config.GetCollection("audit_log")
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch audit entries",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	w.WriteHeader(http.StatusOK)
+
+	// json.Encoder terminates every value with a newline
+	encoder := json.NewEncoder(w)
+	for cursor.Next(ctx) {
+		var entry models.AuditEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return
+		}
+		if err := encoder.Encode(entry); err != nil {
+			return
+		}
+	}
+}
+
+// VerifyAuditLog recomputes the hash chain and reports the first broken entry
+func VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	brokenSeq, checked, err := audit.Verify(ctx)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to verify audit log",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	response := map[string]interface{}{
+		"valid":           brokenSeq == 0,
+		"entries_checked": checked,
+	}
+	if brokenSeq != 0 {
+		response["first_invalid_seq"] = brokenSeq
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"core-service/config"
+	"core-service/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// EnsureCatalogIndexes serves the usual catalog queries: by owner and
+// lifecycle, and by how long ago a service was last deployed
+func EnsureCatalogIndexes(ctx context.Context) error {
+	_, err := config.GetCollection("services").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "lifecycle", Value: 1}, {Key: "tier", Value: 1}}},
+		{Keys: bson.D{{Key: "lifecycle", Value: 1}, {Key: "last_deployed_at", Value: 1}}},
+	})
+	return err
+}
+
+// catalogFilter builds the Mongo filter for the tenant_id, project_id, owner,
+// lifecycle, runtime, tier, not_deployed_days and selector query parameters
+func catalogFilter(r *http.Request) (bson.M, error) {
+	query := r.URL.Query()
+	filter := bson.M{}
+
+	for _, field := range []string{"tenant_id", "project_id"} {
+		if value := query.Get(field); value != "" {
+			id, err := bson.ObjectIDFromHex(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s format", field)
+			}
+			filter[field] = id
+		}
+	}
+	for _, field := range []string{"owner", "runtime"} {
+		if value := query.Get(field); value != "" {
+			filter[field] = value
+		}
+	}
+	if lifecycle := query.Get("lifecycle"); lifecycle != "" {
+		if lifecycle != models.LifecycleExperimental && lifecycle != models.LifecycleProduction && lifecycle != models.LifecycleDeprecated {
+			return nil, fmt.Errorf("unknown lifecycle %q", lifecycle)
+		}
+		filter["lifecycle"] = lifecycle
+	}
+	if value := query.Get("tier"); value != "" {
+		tier, err := strconv.Atoi(value)
+		if err != nil || tier < 1 || tier > models.MaxTier {
+			return nil, fmt.Errorf("tier must be between 1 and %d", models.MaxTier)
+		}
+		filter["tier"] = tier
+	}
+	// Services never deployed count as not deployed in any number of days
+	if value := query.Get("not_deployed_days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 {
+			return nil, errors.New("not_deployed_days must be a positive number of days")
+		}
+		since := time.Now().AddDate(0, 0, -days)
+		filter["$or"] = []bson.M{
+			{"last_deployed_at": bson.M{"$lt": since}},
+			{"last_deployed_at": bson.M{"$exists": false}},
+		}
+	}
+	return filter, nil
+}
+
+// GetCatalogServices queries the service catalog across projects, such as
+// all production services of a team that haven't been deployed in 30 days:
+// ?owner=payments&lifecycle=production&not_deployed_days=30
+func GetCatalogServices(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10 // Default limit
+	}
+
+	skip := (page - 1) * limit
+
+	filter, err := catalogFilter(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid catalog query",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if !withSelector(w, r, filter) {
+		return
+	}
+
+	collection := config.GetCollection("services")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to count services",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}})
+	findOptions.SetSkip(int64(skip))
+	findOptions.SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch services",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var services []models.Service
+	if err := cursor.All(ctx, &services); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if services == nil {
+		services = []models.Service{}
+	}
+
+	totalPages := (int(totalCount) + limit - 1) / limit
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        services,
+		"page":        page,
+		"limit":       limit,
+		"total_count": totalCount,
+		"total_pages": totalPages,
+	})
+}
+
+// deployedService is the part of a deployment-service deployment that
+// tells which service was deployed when
+type deployedService struct {
+	ServiceID bson.ObjectID `json:"service_id"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// recordDeployment moves a service's last_deployed_at forward when
+// deployment-service reports one of its deployments succeeded
+func recordDeployment(ctx context.Context, deployment deployedService) error {
+	deployedAt := deployment.UpdatedAt
+	if deployedAt.IsZero() {
+		deployedAt = time.Now()
+	}
+	_, err := config.GetCollection("services").UpdateOne(ctx,
+		bson.M{"_id": deployment.ServiceID},
+		bson.M{"$max": bson.M{"last_deployed_at": deployedAt}},
+	)
+	return err
+}
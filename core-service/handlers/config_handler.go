@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"context"
+	"core-service/audit"
+	"core-service/config"
+	"core-service/events"
+	"core-service/middleware"
+	"core-service/models"
+	"core-service/secrets"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// errConfigChanged is returned from inside a transaction when a config
+// change was based on a version that is no longer the latest
+var errConfigChanged = errors.New("config changed since base version")
+
+// EnsureConfigIndexes keeps one config per service and version, so two
+// concurrent changes can't both become the next version
+func EnsureConfigIndexes(ctx context.Context) error {
+	_, err := config.GetCollection("service_configs").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "service_id", Value: 1}, {Key: "version", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// findConfig returns a version of a service's config, the latest if version
+// is zero. A service without config has an empty version 0.
+func findConfig(ctx context.Context, serviceID bson.ObjectID, version int) (models.ServiceConfig, error) {
+	filter := bson.M{"service_id": serviceID}
+	if version > 0 {
+		filter["version"] = version
+	}
+
+	var serviceConfig models.ServiceConfig
+	err := config.GetCollection("service_configs").FindOne(ctx, filter,
+		options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}}),
+	).Decode(&serviceConfig)
+	if errors.Is(err, mongo.ErrNoDocuments) && version == 0 {
+		return models.ServiceConfig{ServiceID: serviceID}, nil
+	}
+	return serviceConfig, err
+}
+
+// configRequest parses the service ID and optional version of a config
+// request, writing the error response and returning false if they're invalid
+func configRequest(w http.ResponseWriter, r *http.Request) (bson.ObjectID, int, bool) {
+	serviceObjectID, err := bson.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid service ID format",
+			"msg":   err.Error(),
+		})
+		return serviceObjectID, 0, false
+	}
+
+	version := 0
+	if raw := r.URL.Query().Get("version"); raw != "" {
+		if version, err = strconv.Atoi(raw); err != nil || version < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid version",
+				"msg":   "version must be a positive number",
+			})
+			return serviceObjectID, 0, false
+		}
+	}
+	return serviceObjectID, version, true
+}
+
+// GetServiceConfig returns the latest version of a service's config, or the
+// one given by ?version=, with secrets redacted
+func GetServiceConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	serviceObjectID, version, ok := configRequest(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	serviceConfig, err := findConfig(ctx, serviceObjectID, version)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "config version not found",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch config",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": serviceConfig.Redacted(),
+	})
+}
+
+// GetServiceConfigVersions lists the versions of a service's config, newest
+// first, with secrets redacted
+func GetServiceConfigVersions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	serviceObjectID, _, ok := configRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10 // Default limit
+	}
+
+	skip := (page - 1) * limit
+
+	collection := config.GetCollection("service_configs")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"service_id": serviceObjectID}
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to count config versions",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64(skip))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "version", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch config versions",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var versions []models.ServiceConfig
+	if err := cursor.All(ctx, &versions); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	data := make([]map[string]interface{}, 0, len(versions))
+	for _, version := range versions {
+		data = append(data, version.Redacted())
+	}
+
+	totalPages := (int(totalCount) + limit - 1) / limit
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        data,
+		"page":        page,
+		"limit":       limit,
+		"total_count": totalCount,
+		"total_pages": totalPages,
+	})
+}
+
+// UpdateServiceConfig applies a models.ConfigChange to the latest version of
+// a service's config and stores the result as the next version. Secrets are
+// encrypted before they are stored. With base_version set the change is
+// refused if another change was made since.
+func UpdateServiceConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	serviceObjectID, _, ok := configRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var change models.ConfigChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if err := change.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid config change",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var service models.Service
+	if err := config.GetCollection("services").FindOne(ctx, bson.M{"_id": serviceObjectID}).Decode(&service); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "service not found",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	if change.EnvironmentID != "" {
+		environmentID, _ := bson.ObjectIDFromHex(change.EnvironmentID)
+		count, err := config.GetCollection("environments").CountDocuments(ctx, bson.M{"_id": environmentID, "project_id": service.ProjectID})
+		if err != nil || count == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "environment not found",
+				"msg":   "the service's project has no environment " + change.EnvironmentID,
+			})
+			return
+		}
+	}
+
+	var createdBy string
+	if claims, ok := middleware.ClaimsFromContext(r.Context()); ok {
+		createdBy = claims.Subject
+		if createdBy == "" {
+			createdBy = claims.ServiceName
+		}
+	}
+
+	var before, after models.ServiceConfig
+	err := config.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if before, err = findConfig(ctx, serviceObjectID, 0); err != nil {
+			return err
+		}
+		if change.BaseVersion != nil && *change.BaseVersion != before.Version {
+			return errConfigChanged
+		}
+
+		after = models.ServiceConfig{
+			ServiceID:    serviceObjectID,
+			TenantID:     service.TenantID,
+			Version:      before.Version + 1,
+			Default:      before.Default.Clone(),
+			Environments: make(map[string]models.ConfigScope, len(before.Environments)),
+			Comment:      change.Comment,
+			CreatedBy:    createdBy,
+			CreatedAt:    time.Now(),
+		}
+		for id, scope := range before.Environments {
+			after.Environments[id] = scope.Clone()
+		}
+
+		scope := after.Default
+		if change.EnvironmentID != "" {
+			scope = after.Environments[change.EnvironmentID]
+		}
+		for key, value := range change.Values {
+			delete(scope.Secrets, key)
+			if value == nil {
+				delete(scope.Values, key)
+				continue
+			}
+			if scope.Values == nil {
+				scope.Values = map[string]string{}
+			}
+			scope.Values[key] = *value
+		}
+		for key, value := range change.Secrets {
+			delete(scope.Values, key)
+			if value == nil {
+				delete(scope.Secrets, key)
+				continue
+			}
+			secret, err := secrets.Encrypt(*value, secrets.Binding{
+				ServiceID:     after.ServiceID.Hex(),
+				EnvironmentID: change.EnvironmentID,
+				Key:           key,
+				Version:       after.Version,
+			})
+			if err != nil {
+				return err
+			}
+			if scope.Secrets == nil {
+				scope.Secrets = map[string]models.EncryptedValue{}
+			}
+			scope.Secrets[key] = secret
+		}
+		switch {
+		case change.EnvironmentID == "":
+			after.Default = scope
+		case len(scope.Values) == 0 && len(scope.Secrets) == 0:
+			delete(after.Environments, change.EnvironmentID)
+		default:
+			after.Environments[change.EnvironmentID] = scope
+		}
+
+		result, err := config.GetCollection("service_configs").InsertOne(ctx, after)
+		if err != nil {
+			return err
+		}
+		after.ID = result.InsertedID.(bson.ObjectID)
+		return events.Enqueue(ctx, events.ServiceConfigUpdated, "service", serviceObjectID, service.TenantID, after.Redacted())
+	})
+	if errors.Is(err, errConfigChanged) || mongo.IsDuplicateKeyError(err) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "config changed",
+			"msg":   "the config was changed concurrently, fetch the latest version and try again",
+		})
+		return
+	}
+	if errors.Is(err, secrets.ErrNotConfigured) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "secrets are disabled",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to update config",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "service_config", serviceObjectID.Hex())
+	if !service.TenantID.IsZero() {
+		audit.SetTenant(r, service.TenantID.Hex())
+	}
+	audit.SetChange(r, before.Redacted(), after.Redacted())
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "config updated successfully",
+		"data":    after.Redacted(),
+	})
+}
+
+// ResolveServiceConfig returns the latest version of a service's config, or
+// the one given by ?version=, as it applies to ?environment_id=: the
+// environment's values and secrets over the defaults, with secrets
+// decrypted. It is meant for deployment-service handing config to the
+// deploy target.
+func ResolveServiceConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	serviceObjectID, version, ok := configRequest(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	serviceConfig, err := findConfig(ctx, serviceObjectID, version)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "config version not found",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch config",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	// Secrets are bound to the scope they are stored in, the defaults ("")
+	// or the environment
+	type scopedSecret struct {
+		environmentID string
+		value         models.EncryptedValue
+	}
+	values := map[string]string{}
+	sealed := map[string]scopedSecret{}
+	environmentIDs := []string{""}
+	if environmentID := r.URL.Query().Get("environment_id"); environmentID != "" {
+		environmentIDs = append(environmentIDs, environmentID)
+	}
+	for _, environmentID := range environmentIDs {
+		scope := serviceConfig.Default
+		if environmentID != "" {
+			scope = serviceConfig.Environments[environmentID]
+		}
+		for key, value := range scope.Values {
+			values[key] = value
+			delete(sealed, key)
+		}
+		for key, secret := range scope.Secrets {
+			sealed[key] = scopedSecret{environmentID, secret}
+			delete(values, key)
+		}
+	}
+
+	opened := make(map[string]string, len(sealed))
+	for key, secret := range sealed {
+		value, err := secrets.Decrypt(secret.value, secrets.Binding{
+			ServiceID:     serviceConfig.ServiceID.Hex(),
+			EnvironmentID: secret.environmentID,
+			Key:           key,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "failed to decrypt secret " + key,
+				"msg":   err.Error(),
+			})
+			return
+		}
+		opened[key] = value
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"version": serviceConfig.Version,
+		"values":  values,
+		"secrets": opened,
+	})
+}
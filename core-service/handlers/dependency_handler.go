@@ -0,0 +1,589 @@
+package handlers
+
+import (
+	"context"
+	"core-service/audit"
+	"core-service/config"
+	"core-service/events"
+	"core-service/graph"
+	"core-service/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// EnsureDependencyIndexes keeps each dependency unique and serves lookups in
+// both directions and of a tenant's whole graph
+func EnsureDependencyIndexes(ctx context.Context) error {
+	_, err := config.GetCollection("service_dependencies").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "service_id", Value: 1}, {Key: "depends_on_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "depends_on_id", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}}},
+	})
+	return err
+}
+
+// serviceNode is a service found by a graph query, Depth dependencies away
+// from the service queried
+type serviceNode struct {
+	models.Service
+	Depth int `json:"depth"`
+}
+
+// tenantGraph loads the dependency graph of a tenant
+func tenantGraph(ctx context.Context, tenantID bson.ObjectID) (*graph.Graph, error) {
+	cursor, err := config.GetCollection("service_dependencies").Find(ctx, bson.M{"tenant_id": tenantID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var dependencies []models.Dependency
+	if err := cursor.All(ctx, &dependencies); err != nil {
+		return nil, err
+	}
+	edges := make([]graph.Edge, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		edges = append(edges, graph.Edge{Service: dependency.ServiceID, DependsOn: dependency.DependsOnID})
+	}
+	return graph.New(edges), nil
+}
+
+// lockTenantGraph makes concurrent transactions changing the same tenant's
+// graph conflict, so that two dependencies that only close a cycle together
+// can't both be added
+func lockTenantGraph(ctx context.Context, tenantID bson.ObjectID) error {
+	_, err := config.GetCollection("dependency_graphs").UpdateOne(ctx,
+		bson.M{"_id": tenantID},
+		bson.M{"$inc": bson.M{"version": 1}, "$set": bson.M{"updated_at": time.Now()}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
+
+// findServiceNodes fetches the services of graph nodes, in the order of the
+// nodes. Nodes whose service no longer exists are left out.
+func findServiceNodes(ctx context.Context, nodes []graph.Node) ([]serviceNode, error) {
+	ids := make([]bson.ObjectID, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+	cursor, err := config.GetCollection("services").Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var services []models.Service
+	if err := cursor.All(ctx, &services); err != nil {
+		return nil, err
+	}
+	byID := make(map[bson.ObjectID]models.Service, len(services))
+	for _, service := range services {
+		byID[service.ID] = service
+	}
+
+	result := []serviceNode{}
+	for _, node := range nodes {
+		if service, ok := byID[node.ID]; ok {
+			result = append(result, serviceNode{Service: service, Depth: node.Depth})
+		}
+	}
+	return result, nil
+}
+
+// GetServiceDependencies lists the services a service directly depends on
+func GetServiceDependencies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	serviceObjectID, err := bson.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid service ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := config.GetCollection("service_dependencies").Find(ctx,
+		bson.M{"service_id": serviceObjectID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}),
+	)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch dependencies",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var dependencies []models.Dependency
+	if err := cursor.All(ctx, &dependencies); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if dependencies == nil {
+		dependencies = []models.Dependency{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": dependencies,
+	})
+}
+
+// CreateServiceDependency records that a service depends on another service
+// of its tenant. A dependency that would close a cycle is rejected along with
+// the path it would complete.
+func CreateServiceDependency(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	serviceID := mux.Vars(r)["id"]
+	serviceObjectID, err := bson.ObjectIDFromHex(serviceID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid service ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	var request struct {
+		DependsOnID string `json:"depends_on_id"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	dependsOnObjectID, err := bson.ObjectIDFromHex(request.DependsOnID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "depends_on_id must be a service ID",
+		})
+		return
+	}
+	if dependsOnObjectID == serviceObjectID {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "a service can't depend on itself",
+		})
+		return
+	}
+
+	services := config.GetCollection("services")
+	collection := config.GetCollection("service_dependencies")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var service, dependsOn models.Service
+	if err := services.FindOne(ctx, bson.M{"_id": serviceObjectID}).Decode(&service); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "service not found",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if err := services.FindOne(ctx, bson.M{"_id": dependsOnObjectID}).Decode(&dependsOn); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "service depended on not found",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if service.TenantID != dependsOn.TenantID {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "services can only depend on services of the same tenant",
+		})
+		return
+	}
+
+	dependency := models.Dependency{
+		ServiceID:   serviceObjectID,
+		DependsOnID: dependsOnObjectID,
+		TenantID:    service.TenantID,
+		Description: request.Description,
+		CreatedAt:   time.Now(),
+	}
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := lockTenantGraph(ctx, service.TenantID); err != nil {
+			return err
+		}
+		g, err := tenantGraph(ctx, service.TenantID)
+		if err != nil {
+			return err
+		}
+		// The new dependency closes a cycle if the service depended on
+		// already depends on the service, directly or not
+		if path := g.PathTo(dependsOnObjectID, serviceObjectID); path != nil {
+			return &graph.CycleError{Path: append([]bson.ObjectID{serviceObjectID}, path...)}
+		}
+		result, err := collection.InsertOne(ctx, dependency)
+		if err != nil {
+			return err
+		}
+		dependency.ID = result.InsertedID.(bson.ObjectID)
+		return events.Enqueue(ctx, events.DependencyAdded, "service", serviceObjectID, service.TenantID, dependency)
+	})
+	var cycle *graph.CycleError
+	if errors.As(err, &cycle) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "dependency would create a cycle",
+			"msg":   err.Error(),
+			"cycle": cycle.Path,
+		})
+		return
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "dependency already exists",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to create dependency",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "service_dependency", dependency.ID.Hex())
+	if !service.TenantID.IsZero() {
+		audit.SetTenant(r, service.TenantID.Hex())
+	}
+	audit.SetChange(r, nil, dependency)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "dependency created successfully",
+		"data":    dependency,
+	})
+}
+
+// DeleteServiceDependency removes a service's dependency on another service
+func DeleteServiceDependency(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+
+	serviceObjectID, err := bson.ObjectIDFromHex(params["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid service ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	dependsOnObjectID, err := bson.ObjectIDFromHex(params["dependsOnId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid service ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	collection := config.GetCollection("service_dependencies")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before models.Dependency
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		filter := bson.M{"service_id": serviceObjectID, "depends_on_id": dependsOnObjectID}
+		if err := collection.FindOneAndDelete(ctx, filter).Decode(&before); err != nil {
+			return err
+		}
+		return events.Enqueue(ctx, events.DependencyRemoved, "service", serviceObjectID, before.TenantID, before)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "dependency not found",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to delete dependency",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "service_dependency", before.ID.Hex())
+	if !before.TenantID.IsZero() {
+		audit.SetTenant(r, before.TenantID.Hex())
+	}
+	audit.SetChange(r, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "dependency deleted successfully",
+	})
+}
+
+// queryServiceGraph answers a graph query about the service in the request
+// path with the services query returns, fetched and in its order. It writes
+// an error response and returns false if that fails.
+func queryServiceGraph(w http.ResponseWriter, r *http.Request, query func(g *graph.Graph, id bson.ObjectID) []graph.Node) (models.Service, []serviceNode, bool) {
+	var service models.Service
+	serviceObjectID, err := bson.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid service ID format",
+			"msg":   err.Error(),
+		})
+		return service, nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := config.GetCollection("services").FindOne(ctx, bson.M{"_id": serviceObjectID}).Decode(&service); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "service not found",
+			"msg":   err.Error(),
+		})
+		return service, nil, false
+	}
+
+	g, err := tenantGraph(ctx, service.TenantID)
+	if err == nil {
+		var nodes []serviceNode
+		if nodes, err = findServiceNodes(ctx, query(g, serviceObjectID)); err == nil {
+			return service, nodes, true
+		}
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": "failed to query dependency graph",
+		"msg":   err.Error(),
+	})
+	return service, nil, false
+}
+
+// GetServiceUpstream lists the services a service depends on. With
+// ?transitive=true it includes what those depend on in turn.
+func GetServiceUpstream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	transitive := r.URL.Query().Get("transitive") == "true"
+	_, nodes, ok := queryServiceGraph(w, r, func(g *graph.Graph, id bson.ObjectID) []graph.Node {
+		return g.Upstream(id, transitive)
+	})
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        nodes,
+		"total_count": len(nodes),
+	})
+}
+
+// GetServiceDownstream lists the services that depend on a service. With
+// ?transitive=true it includes what depends on those in turn.
+func GetServiceDownstream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	transitive := r.URL.Query().Get("transitive") == "true"
+	_, nodes, ok := queryServiceGraph(w, r, func(g *graph.Graph, id bson.ObjectID) []graph.Node {
+		return g.Downstream(id, transitive)
+	})
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        nodes,
+		"total_count": len(nodes),
+	})
+}
+
+// GetServiceImpact lists every service that depends on a service, directly
+// or not, and so may be affected when it breaks. The most critical ones come
+// first, and the summary counts them by owner and tier.
+func GetServiceImpact(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	service, nodes, ok := queryServiceGraph(w, r, func(g *graph.Graph, id bson.ObjectID) []graph.Node {
+		return g.Downstream(id, true)
+	})
+	if !ok {
+		return
+	}
+
+	// Services without a tier sort after those with one
+	rank := func(tier int) int {
+		if tier == 0 {
+			return models.MaxTier + 1
+		}
+		return tier
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if rank(nodes[i].Tier) != rank(nodes[j].Tier) {
+			return rank(nodes[i].Tier) < rank(nodes[j].Tier)
+		}
+		return nodes[i].Depth < nodes[j].Depth
+	})
+
+	owners := map[string]int{}
+	tiers := map[int]int{}
+	projects := map[bson.ObjectID]bool{}
+	for _, node := range nodes {
+		if node.Owner != "" {
+			owners[node.Owner]++
+		}
+		if node.Tier > 0 {
+			tiers[node.Tier]++
+		}
+		projects[node.ProjectID] = true
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"service_id": service.ID,
+		"data":       nodes,
+		"summary": map[string]interface{}{
+			"services": len(nodes),
+			"projects": len(projects),
+			"owners":   owners,
+			"tiers":    tiers,
+		},
+	})
+}
+
+// GetProjectDeployOrder orders a project's services so that each comes
+// after the services it depends on, in waves that can be deployed in
+// parallel. Dependencies through services of other projects count too.
+func GetProjectDeployOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	projectObjectID, err := bson.ObjectIDFromHex(mux.Vars(r)["projectId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid project ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var project models.Project
+	if err := config.GetCollection("projects").FindOne(ctx, bson.M{"_id": projectObjectID}).Decode(&project); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "project not found",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	cursor, err := config.GetCollection("services").Find(ctx, bson.M{"project_id": projectObjectID})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch services",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var services []models.Service
+	if err := cursor.All(ctx, &services); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	g, err := tenantGraph(ctx, project.TenantID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to query dependency graph",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	ids := make([]bson.ObjectID, 0, len(services))
+	byID := make(map[bson.ObjectID]models.Service, len(services))
+	for _, service := range services {
+		ids = append(ids, service.ID)
+		byID[service.ID] = service
+	}
+	waves, err := g.Waves(ids)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "project services can't be ordered",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	order := []bson.ObjectID{}
+	data := [][]models.Service{}
+	for _, wave := range waves {
+		services := make([]models.Service, 0, len(wave))
+		for _, id := range wave {
+			order = append(order, id)
+			services = append(services, byID[id])
+		}
+		data = append(data, services)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"project_id": projectObjectID,
+		"order":      order,
+		"waves":      data,
+	})
+}
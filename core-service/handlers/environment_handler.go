@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"context"
+	"core-service/audit"
+	"core-service/config"
+	"core-service/events"
+	"core-service/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// EnsureEnvironmentIndexes keeps environment names unique within a project
+func EnsureEnvironmentIndexes(ctx context.Context) error {
+	_, err := config.GetCollection("environments").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// CreateEnvironment adds an environment to a project
+func CreateEnvironment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	projectID := params["projectId"]
+
+	projectObjectID, err := bson.ObjectIDFromHex(projectID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid project ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	var environment models.Environment
+	if err := json.NewDecoder(r.Body).Decode(&environment); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if err := environment.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid environment",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var project models.Project
+	if err := config.GetCollection("projects").FindOne(ctx, bson.M{"_id": projectObjectID}).Decode(&project); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "project not found",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	environment.ID = bson.ObjectID{}
+	environment.ProjectID = projectObjectID
+	environment.TenantID = project.TenantID
+	environment.CreatedAt = now
+	environment.UpdatedAt = now
+
+	collection := config.GetCollection("environments")
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		result, err := collection.InsertOne(ctx, environment)
+		if err != nil {
+			return err
+		}
+		environment.ID = result.InsertedID.(bson.ObjectID)
+		return events.Enqueue(ctx, events.EnvironmentCreated, "environment", environment.ID, environment.TenantID, environment)
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "environment already exists",
+			"msg":   "the project already has an environment named " + environment.Name,
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to create environment",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "environment", environment.ID.Hex())
+	if !environment.TenantID.IsZero() {
+		audit.SetTenant(r, environment.TenantID.Hex())
+	}
+	audit.SetChange(r, nil, environment)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "environment created successfully",
+		"data":    environment,
+	})
+}
+
+// GetEnvironmentsByProjectID lists a project's environments in order.
+// Supports a name filter.
+func GetEnvironmentsByProjectID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	projectID := params["projectId"]
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10 // Default limit
+	}
+
+	skip := (page - 1) * limit
+
+	projectObjectID, err := bson.ObjectIDFromHex(projectID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid project ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	filter := bson.M{"project_id": projectObjectID}
+	if name := r.URL.Query().Get("name"); name != "" {
+		filter["name"] = name
+	}
+
+	collection := config.GetCollection("environments")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to count environments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64(skip))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "order", Value: 1}, {Key: "name", Value: 1}})
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch environments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var environments []models.Environment
+	if err := cursor.All(ctx, &environments); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if environments == nil {
+		environments = []models.Environment{}
+	}
+
+	totalPages := (int(totalCount) + limit - 1) / limit
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        environments,
+		"page":        page,
+		"limit":       limit,
+		"total_count": totalCount,
+		"total_pages": totalPages,
+	})
+}
+
+// GetEnvironmentByID returns an environment
+func GetEnvironmentByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	environmentID := params["id"]
+
+	environmentObjectID, err := bson.ObjectIDFromHex(environmentID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid environment ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	collection := config.GetCollection("environments")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var environment models.Environment
+	if err := collection.FindOne(ctx, bson.M{"_id": environmentObjectID}).Decode(&environment); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "environment not found",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(environment)
+}
+
+// UpdateEnvironment replaces an environment's settings. The project it
+// belongs to can't be changed.
+func UpdateEnvironment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	environmentID := params["id"]
+
+	environmentObjectID, err := bson.ObjectIDFromHex(environmentID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid environment ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	var update models.Environment
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if err := update.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid environment",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	collection := config.GetCollection("environments")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before, after models.Environment
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := collection.FindOne(ctx, bson.M{"_id": environmentObjectID}).Decode(&before); err != nil {
+			return err
+		}
+		changes := bson.M{"$set": bson.M{
+			"name":       update.Name,
+			"order":      update.Order,
+			"protected":  update.Protected,
+			"updated_at": time.Now(),
+		}}
+		// PUT replaces the deployer config, variables and approval policy;
+		// leaving one out removes it
+		unset := bson.M{}
+		if update.Deployer != nil {
+			changes["$set"].(bson.M)["deployer"] = update.Deployer
+		} else {
+			unset["deployer"] = ""
+		}
+		if len(update.Variables) > 0 {
+			changes["$set"].(bson.M)["variables"] = update.Variables
+		} else {
+			unset["variables"] = ""
+		}
+		if update.ApprovalPolicy != nil {
+			changes["$set"].(bson.M)["approval_policy"] = update.ApprovalPolicy
+		} else {
+			unset["approval_policy"] = ""
+		}
+		if len(unset) > 0 {
+			changes["$unset"] = unset
+		}
+		findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		if err := collection.FindOneAndUpdate(ctx, bson.M{"_id": environmentObjectID}, changes, findOptions).Decode(&after); err != nil {
+			return err
+		}
+		return events.Enqueue(ctx, events.EnvironmentUpdated, "environment", environmentObjectID, after.TenantID, after)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "environment not found",
+		})
+		return
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "environment already exists",
+			"msg":   "the project already has an environment named " + update.Name,
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to update environment",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "environment", environmentID)
+	if !after.TenantID.IsZero() {
+		audit.SetTenant(r, after.TenantID.Hex())
+	}
+	audit.SetChange(r, before, after)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "environment updated successfully",
+		"data":    after,
+	})
+}
+
+// DeleteEnvironment deletes an environment. Protected environments must be
+// unprotected first.
+func DeleteEnvironment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	environmentID := params["id"]
+
+	environmentObjectID, err := bson.ObjectIDFromHex(environmentID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid environment ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	collection := config.GetCollection("environments")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before models.Environment
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := collection.FindOne(ctx, bson.M{"_id": environmentObjectID}).Decode(&before); err != nil {
+			return err
+		}
+		if before.Protected {
+			return errProtected
+		}
+		if _, err := collection.DeleteOne(ctx, bson.M{"_id": environmentObjectID}); err != nil {
+			return err
+		}
+		return events.Enqueue(ctx, events.EnvironmentDeleted, "environment", environmentObjectID, before.TenantID, before)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "environment not found",
+		})
+		return
+	}
+	if errors.Is(err, errProtected) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "environment is protected",
+			"msg":   "unprotect the environment before deleting it",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to delete environment",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "environment", environmentID)
+	if !before.TenantID.IsZero() {
+		audit.SetTenant(r, before.TenantID.Hex())
+	}
+	audit.SetChange(r, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "environment deleted successfully",
+	})
+}
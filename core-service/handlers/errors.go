@@ -0,0 +1,15 @@
+package handlers
+
+import "errors"
+
+// errHasChildren is returned from inside a transaction when a resource can't
+// be deleted because other resources still belong to it
+var errHasChildren = errors.New("resource still has children")
+
+// errProtected is returned from inside a transaction when a resource can't
+// be deleted because it is protected
+var errProtected = errors.New("resource is protected")
+
+// errHasDependents is returned from inside a transaction when a service can't
+// be deleted because other services depend on it
+var errHasDependents = errors.New("service has dependents")
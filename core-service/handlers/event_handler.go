@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"core-service/audit"
+	"core-service/config"
+	"core-service/events"
+	"core-service/models"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// maxFeedLimit caps how many events a single poll returns
+const maxFeedLimit = 500
+
+// GetEvents serves the domain event feed. Consumers poll with the
+// next_cursor of the previous response as since, starting from 0.
+func GetEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+
+	since := int64(0)
+	if value := query.Get("since"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid cursor",
+				"msg":   "since must be a cursor returned by a previous request",
+			})
+			return
+		}
+		since = parsed
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 {
+		limit = 100 // Default limit
+	}
+	if limit > maxFeedLimit {
+		limit = maxFeedLimit
+	}
+
+	filter := bson.M{}
+	if eventTypes := query["type"]; len(eventTypes) > 0 {
+		filter["type"] = bson.M{"$in": eventTypes}
+	}
+	if tenant := query.Get("tenant"); tenant != "" {
+		filter["tenant_id"] = tenant
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	feed, err := events.Feed(ctx, filter, since, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch events",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if feed == nil {
+		feed = []models.Event{}
+	}
+
+	next := since
+	if len(feed) > 0 {
+		next = feed[len(feed)-1].Seq
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        feed,
+		"next_cursor": strconv.FormatInt(next, 10),
+		"has_more":    len(feed) == limit,
+	})
+}
+
+// PublishEvent lets other services add their own domain events, such as
+// deployment lifecycle events, to the feed. A DeploymentSucceeded event also
+// updates the service's last_deployed_at.
+func PublishEvent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Type          string          `json:"type"`
+		AggregateType string          `json:"aggregate_type"`
+		AggregateID   string          `json:"aggregate_id"`
+		TenantID      string          `json:"tenant_id"`
+		Payload       json.RawMessage `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	if !slices.Contains(events.ExternalTypes, request.Type) || request.AggregateType == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "type must be one of " + strings.Join(events.ExternalTypes, ", ") + " and aggregate_type is required",
+		})
+		return
+	}
+
+	aggregateID, err := bson.ObjectIDFromHex(request.AggregateID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid aggregate ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	var tenantID bson.ObjectID
+	if request.TenantID != "" {
+		if tenantID, err = bson.ObjectIDFromHex(request.TenantID); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid tenant ID format",
+				"msg":   err.Error(),
+			})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var deployment deployedService
+	if request.Type == events.DeploymentSucceeded {
+		if err := json.Unmarshal(request.Payload, &deployment); err != nil || deployment.ServiceID.IsZero() {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid request payload",
+				"msg":   "the payload of a DeploymentSucceeded event must be a deployment with a service_id",
+			})
+			return
+		}
+	}
+
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		if request.Type == events.DeploymentSucceeded {
+			if err := recordDeployment(ctx, deployment); err != nil {
+				return err
+			}
+		}
+		return events.Enqueue(ctx, request.Type, request.AggregateType, aggregateID, tenantID, request.Payload)
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to publish event",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.Skip(r)
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "event accepted",
+	})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"core-service/selector"
+	"encoding/json"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// validateMetadata checks the labels and annotations of a request body and
+// writes a 400 response if they are invalid
+func validateMetadata(w http.ResponseWriter, labels, annotations map[string]string) bool {
+	err := selector.ValidateLabels(labels)
+	if err == nil {
+		err = selector.ValidateAnnotations(annotations)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// setMetadata adds the labels and annotations of a PUT to changes. Like the
+// other fields of a PUT, leaving them out removes them.
+func setMetadata(changes bson.M, labels, annotations map[string]string) {
+	unset, _ := changes["$unset"].(bson.M)
+	if unset == nil {
+		unset = bson.M{}
+	}
+	for field, value := range map[string]map[string]string{"labels": labels, "annotations": annotations} {
+		if len(value) > 0 {
+			changes["$set"].(bson.M)[field] = value
+		} else {
+			unset[field] = ""
+		}
+	}
+	if len(unset) > 0 {
+		changes["$unset"] = unset
+	}
+}
+
+// withSelector narrows filter to the documents matching the label selector
+// in the request's selector parameter, and writes a 400 response if it
+// can't be parsed
+func withSelector(w http.ResponseWriter, r *http.Request, filter bson.M) bool {
+	labelFilter, err := selector.Parse(r.URL.Query().Get("selector"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid selector",
+			"msg":   err.Error(),
+		})
+		return false
+	}
+	for key, value := range labelFilter {
+		filter[key] = value
+	}
+	return true
+}
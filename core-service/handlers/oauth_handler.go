@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"core-service/audit"
+	"core-service/config"
+	"core-service/models"
+	"core-service/oauth"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// writeOAuthError writes an RFC 6749 error response
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// authenticateOAuthClient reads client credentials from HTTP Basic auth or
+// the client_id/client_secret form parameters
+func authenticateOAuthClient(ctx context.Context, r *http.Request) (*models.OAuthClient, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+	return oauth.AuthenticateClient(ctx, clientID, secret)
+}
+
+// IssueToken implements the client-credentials grant at /oauth/token
+func IssueToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	if grantType := r.PostForm.Get("grant_type"); grantType != "client_credentials" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "only client_credentials is supported")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := authenticateOAuthClient(ctx, r)
+	if err != nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	tokenString, claims, err := oauth.IssueToken(client, strings.Fields(r.PostForm.Get("scope")))
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+
+	audit.SetResource(r, "access_token", claims.ID)
+	audit.SetAction(r, "issue")
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": tokenString,
+		"token_type":   "Bearer",
+		"expires_in":   int(config.App.OAuth.TokenTTL.Seconds()),
+		"scope":        claims.Scope,
+	})
+}
+
+// IntrospectToken implements RFC 7662 token introspection for authenticated clients
+func IntrospectToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	audit.Skip(r)
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := authenticateOAuthClient(ctx, r); err != nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	claims, err := oauth.ParseToken(r.PostForm.Get("token"))
+	if err != nil {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{"active": false})
+		return
+	}
+
+	if oauth.IsRevoked(claims) {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{"active": false})
+		return
+	}
+
+	response := map[string]interface{}{
+		"active":       true,
+		"token_type":   "Bearer",
+		"scope":        claims.Scope,
+		"client_id":    claims.ClientID,
+		"service_name": claims.ServiceName,
+		"roles":        claims.Roles,
+		"sub":          claims.Subject,
+		"iss":          claims.Issuer,
+		"jti":          claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		response["exp"] = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response["iat"] = claims.IssuedAt.Unix()
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeToken implements RFC 7009 token revocation. Clients may revoke their
+// own tokens; admin clients may revoke any token.
+func RevokeToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := authenticateOAuthClient(ctx, r)
+	if err != nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	// Invalid tokens are not an error for revocation
+	claims, err := oauth.ParseToken(r.PostForm.Get("token"))
+	if err != nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if claims.ClientID != client.ClientID && !slices.Contains(client.Scopes, oauth.ScopeAdmin) {
+		writeOAuthError(w, http.StatusForbidden, "unauthorized_client", "token was not issued to this client")
+		return
+	}
+
+	if err := oauth.RevokeToken(ctx, claims, client.ClientID); err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	audit.SetResource(r, "access_token", claims.ID)
+	audit.SetAction(r, "revoke")
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetJWKS publishes the public keys used to sign access tokens
+func GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(oauth.JWKS())
+}
+
+// RegisterOAuthClient registers a service client and returns its secret once
+func RegisterOAuthClient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var client models.OAuthClient
+	if err := json.NewDecoder(r.Body).Decode(&client); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	if client.Name == "" || len(client.Scopes) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "name and scopes are required",
+		})
+		return
+	}
+
+	clientID, secret, err := oauth.GenerateClientCredentials()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to generate client credentials",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	client.ID = bson.ObjectID{}
+	client.ClientID = clientID
+	client.SecretHash = oauth.HashSecret(secret)
+	client.CreatedAt = now
+	client.UpdatedAt = now
+
+	collection := config.GetCollection("oauth_clients")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := collection.InsertOne(ctx, client); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to register client",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "oauth_client", clientID)
+	audit.SetChange(r, nil, client)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "client registered successfully",
+		"data": map[string]interface{}{
+			"client_id":     clientID,
+			"client_secret": secret,
+			"name":          client.Name,
+			"scopes":        client.Scopes,
+			"roles":         client.Roles,
+		},
+	})
+}
+
+// GetAllOAuthClients lists the registered clients without their secrets
+func GetAllOAuthClients(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	collection := config.GetCollection("oauth_clients")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch clients",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var clients []models.OAuthClient
+	if err := cursor.All(ctx, &clients); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if clients == nil {
+		clients = []models.OAuthClient{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": clients,
+	})
+}
+
+// DeleteOAuthClient removes a registered client. Tokens already issued to it
+// stay valid until they expire unless revoked.
+func DeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	clientID := mux.Vars(r)["clientId"]
+
+	collection := config.GetCollection("oauth_clients")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var existing models.OAuthClient
+	_ = collection.FindOne(ctx, bson.M{"client_id": clientID}).Decode(&existing)
+	audit.SetResource(r, "oauth_client", clientID)
+	audit.SetChange(r, existing, nil)
+
+	result, err := collection.DeleteOne(ctx, bson.M{"client_id": clientID})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to delete client",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if result.DeletedCount == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "client not found",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "client deleted successfully",
+	})
+}
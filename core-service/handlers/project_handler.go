@@ -2,15 +2,19 @@ package handlers
 
 import (
 	"context"
+	"core-service/audit"
 	"core-service/config"
+	"core-service/events"
 	"core-service/models"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
@@ -47,6 +51,9 @@ func GetAllProjectsByTenantID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	filter := bson.M{"tenant_id": tenantIdBson}
+	if !withSelector(w, r, filter) {
+		return
+	}
 
 	totalCount, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
@@ -115,6 +122,9 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if !validateMetadata(w, project.Labels, project.Annotations) {
+		return
+	}
 	now := time.Now()
 	project.CreatedAt = now
 	project.UpdatedAt = now
@@ -133,7 +143,16 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 	collection := config.GetCollection("projects")
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	result, err := collection.InsertOne(ctx, project)
+
+	var result *mongo.InsertOneResult
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if result, err = collection.InsertOne(ctx, project); err != nil {
+			return err
+		}
+		project.ID = result.InsertedID.(bson.ObjectID)
+		return events.Enqueue(ctx, events.ProjectCreated, "project", project.ID, project.TenantID, project)
+	})
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -142,9 +161,178 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	audit.SetResource(r, "project", project.ID.Hex())
+	audit.SetTenant(r, project.TenantID.Hex())
+	audit.SetChange(r, nil, project)
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message": "project created successfully",
 		"data":    result,
 	})
 }
+
+// UpdateProject changes a project's name and description and replaces its
+// labels and annotations
+func UpdateProject(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	projectID := params["projectId"]
+
+	projectObjectID, err := bson.ObjectIDFromHex(projectID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid project ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	var update models.Project
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if update.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "name is required",
+		})
+		return
+	}
+	if !validateMetadata(w, update.Labels, update.Annotations) {
+		return
+	}
+
+	collection := config.GetCollection("projects")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before, after models.Project
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := collection.FindOne(ctx, bson.M{"_id": projectObjectID}).Decode(&before); err != nil {
+			return err
+		}
+		changes := bson.M{"$set": bson.M{
+			"name":        update.Name,
+			"description": update.Description,
+			"updated_at":  time.Now(),
+		}}
+		setMetadata(changes, update.Labels, update.Annotations)
+		findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		if err := collection.FindOneAndUpdate(ctx, bson.M{"_id": projectObjectID}, changes, findOptions).Decode(&after); err != nil {
+			return err
+		}
+		return events.Enqueue(ctx, events.ProjectUpdated, "project", projectObjectID, after.TenantID, after)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "project not found",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to update project",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "project", projectID)
+	audit.SetTenant(r, after.TenantID.Hex())
+	audit.SetChange(r, before, after)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "project updated successfully",
+		"data":    after,
+	})
+}
+
+// DeleteProject deletes a project that has no services or environments left
+func DeleteProject(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	projectID := params["projectId"]
+
+	projectObjectID, err := bson.ObjectIDFromHex(projectID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid project ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	collection := config.GetCollection("projects")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before models.Project
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := collection.FindOne(ctx, bson.M{"_id": projectObjectID}).Decode(&before); err != nil {
+			return err
+		}
+		services, err := config.GetCollection("services").CountDocuments(ctx, bson.M{"project_id": projectObjectID})
+		if err != nil {
+			return err
+		}
+		if services > 0 {
+			return errHasChildren
+		}
+		environments, err := config.GetCollection("environments").CountDocuments(ctx, bson.M{"project_id": projectObjectID})
+		if err != nil {
+			return err
+		}
+		if environments > 0 {
+			return errHasChildren
+		}
+		if _, err := collection.DeleteOne(ctx, bson.M{"_id": projectObjectID}); err != nil {
+			return err
+		}
+		if _, err := config.GetCollection("settings").DeleteOne(ctx, bson.M{"level": models.SettingsLevelProject, "target_id": projectObjectID}); err != nil {
+			return err
+		}
+		return events.Enqueue(ctx, events.ProjectDeleted, "project", projectObjectID, before.TenantID, before)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "project not found",
+		})
+		return
+	}
+	if errors.Is(err, errHasChildren) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "project has services or environments",
+			"msg":   "delete the project's services and environments first",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to delete project",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "project", projectID)
+	audit.SetTenant(r, before.TenantID.Hex())
+	audit.SetChange(r, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "project deleted successfully",
+	})
+}
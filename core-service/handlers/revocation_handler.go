@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"core-service/audit"
+	"core-service/config"
+	"core-service/middleware"
+	"core-service/models"
+	"core-service/oauth"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// CreateRevocation revokes a token by jti, or all tokens of a service issued
+// before a given time
+func CreateRevocation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var revocation models.TokenRevocation
+	if err := json.NewDecoder(r.Body).Decode(&revocation); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	// Only the caller decides who revoked and the list decides expiry
+	revocation.RevokedBy = ""
+	revocation.ExpiresAt = time.Time{}
+	if claims, ok := middleware.ClaimsFromContext(r.Context()); ok {
+		revocation.RevokedBy = claims.Subject
+	}
+	if revocation.JTI != "" {
+		revocation.ExpiresAt = time.Now().Add(config.App.OAuth.MaxTokenLifetime)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := oauth.Revoke(ctx, revocation); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to revoke",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	audit.SetResource(r, "token_revocation", revocation.JTI)
+	audit.SetAction(r, "revoke")
+	audit.SetChange(r, nil, revocation)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "revocation created successfully",
+	})
+}
+
+// GetAllRevocations lists active revocations, newest first
+func GetAllRevocations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10 // Default limit
+	}
+
+	skip := (page - 1) * limit
+
+	collection := config.GetCollection("revoked_tokens")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"expires_at": bson.M{"$gt": time.Now()}}
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to count revocations",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64(skip))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "revoked_at", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch revocations",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var revocations []models.TokenRevocation
+	if err := cursor.All(ctx, &revocations); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if revocations == nil {
+		revocations = []models.TokenRevocation{}
+	}
+
+	totalPages := (int(totalCount) + limit - 1) / limit
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        revocations,
+		"page":        page,
+		"limit":       limit,
+		"total_count": totalCount,
+		"total_pages": totalPages,
+	})
+}
@@ -2,15 +2,19 @@ package handlers
 
 import (
 	"context"
+	"core-service/audit"
 	"core-service/config"
+	"core-service/events"
 	"core-service/models"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
@@ -30,7 +34,41 @@ func CreateService(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if !validateMetadata(w, service.Labels, service.Annotations) {
+		return
+	}
+	if err := service.ValidateCatalog(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid catalog metadata",
+			"msg":   err.Error(),
+		})
+		return
+	}
 
+	if service.HealthCheck != nil {
+		if err := service.HealthCheck.Validate(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid health check",
+				"msg":   err.Error(),
+			})
+			return
+		}
+	}
+	if service.ApprovalPolicy != nil {
+		if err := service.ApprovalPolicy.Validate(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid approval policy",
+				"msg":   err.Error(),
+			})
+			return
+		}
+	}
+
+	// Only deployment events move last_deployed_at
+	service.LastDeployedAt = nil
 	now := time.Now()
 	service.CreatedAt = now
 	service.UpdatedAt = now
@@ -52,7 +90,18 @@ func CreateService(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := collection.InsertOne(ctx, service)
+	// Denormalize the owning tenant so other services can scope by it
+	service.TenantID = projectTenantID(ctx, projectObjectID)
+
+	var result *mongo.InsertOneResult
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if result, err = collection.InsertOne(ctx, service); err != nil {
+			return err
+		}
+		service.ID = result.InsertedID.(bson.ObjectID)
+		return events.Enqueue(ctx, events.ServiceCreated, "service", service.ID, service.TenantID, service)
+	})
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -62,6 +111,12 @@ func CreateService(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	audit.SetResource(r, "service", service.ID.Hex())
+	if !service.TenantID.IsZero() {
+		audit.SetTenant(r, service.TenantID.Hex())
+	}
+	audit.SetChange(r, nil, service)
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message": "service created successfully",
@@ -103,6 +158,9 @@ func GetAllServicesByProjectID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	filter := bson.M{"project_id": projectIdBson}
+	if !withSelector(w, r, filter) {
+		return
+	}
 
 	totalCount, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
@@ -189,3 +247,254 @@ func GetServiceByID(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(service)
 }
+
+// UpdateService replaces a service's name, description, catalog metadata,
+// health check, approval policy, labels and annotations. Fields left out of
+// the request are removed.
+func UpdateService(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	serviceID := params["id"]
+
+	serviceObjectID, err := bson.ObjectIDFromHex(serviceID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid service ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	var update models.Service
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if update.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "name is required",
+		})
+		return
+	}
+	if !validateMetadata(w, update.Labels, update.Annotations) {
+		return
+	}
+	if err := update.ValidateCatalog(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid catalog metadata",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if update.HealthCheck != nil {
+		if err := update.HealthCheck.Validate(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid health check",
+				"msg":   err.Error(),
+			})
+			return
+		}
+	}
+	if update.ApprovalPolicy != nil {
+		if err := update.ApprovalPolicy.Validate(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid approval policy",
+				"msg":   err.Error(),
+			})
+			return
+		}
+	}
+
+	collection := config.GetCollection("services")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before, after models.Service
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := collection.FindOne(ctx, bson.M{"_id": serviceObjectID}).Decode(&before); err != nil {
+			return err
+		}
+		changes := bson.M{"$set": bson.M{
+			"name":        update.Name,
+			"description": update.Description,
+			"updated_at":  time.Now(),
+		}}
+		// PUT replaces the catalog metadata, health check, approval policy,
+		// labels and annotations; leaving one out removes it
+		unset := bson.M{}
+		for field, value := range map[string]string{
+			"owner":            update.Owner,
+			"on_call":          update.OnCall,
+			"repository":       update.Repository,
+			"runtime":          update.Runtime,
+			"image_repository": update.ImageRepository,
+			"lifecycle":        update.Lifecycle,
+		} {
+			if value != "" {
+				changes["$set"].(bson.M)[field] = value
+			} else {
+				unset[field] = ""
+			}
+		}
+		if len(update.Ports) > 0 {
+			changes["$set"].(bson.M)["ports"] = update.Ports
+		} else {
+			unset["ports"] = ""
+		}
+		if update.Tier > 0 {
+			changes["$set"].(bson.M)["tier"] = update.Tier
+		} else {
+			unset["tier"] = ""
+		}
+		if update.HealthCheck != nil {
+			changes["$set"].(bson.M)["health_check"] = update.HealthCheck
+		} else {
+			unset["health_check"] = ""
+		}
+		if update.ApprovalPolicy != nil {
+			changes["$set"].(bson.M)["approval_policy"] = update.ApprovalPolicy
+		} else {
+			unset["approval_policy"] = ""
+		}
+		if len(unset) > 0 {
+			changes["$unset"] = unset
+		}
+		setMetadata(changes, update.Labels, update.Annotations)
+		findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		if err := collection.FindOneAndUpdate(ctx, bson.M{"_id": serviceObjectID}, changes, findOptions).Decode(&after); err != nil {
+			return err
+		}
+		return events.Enqueue(ctx, events.ServiceUpdated, "service", serviceObjectID, after.TenantID, after)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "service not found",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to update service",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "service", serviceID)
+	if !after.TenantID.IsZero() {
+		audit.SetTenant(r, after.TenantID.Hex())
+	}
+	audit.SetChange(r, before, after)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "service updated successfully",
+		"data":    after,
+	})
+}
+
+// DeleteService deletes a service along with its config
+func DeleteService(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	serviceID := params["id"]
+
+	serviceObjectID, err := bson.ObjectIDFromHex(serviceID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid service ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	collection := config.GetCollection("services")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before models.Service
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := collection.FindOne(ctx, bson.M{"_id": serviceObjectID}).Decode(&before); err != nil {
+			return err
+		}
+		dependents, err := config.GetCollection("service_dependencies").CountDocuments(ctx, bson.M{"depends_on_id": serviceObjectID})
+		if err != nil {
+			return err
+		}
+		if dependents > 0 {
+			return errHasDependents
+		}
+		if _, err := collection.DeleteOne(ctx, bson.M{"_id": serviceObjectID}); err != nil {
+			return err
+		}
+		// So do its own dependencies
+		if _, err := config.GetCollection("service_dependencies").DeleteMany(ctx, bson.M{"service_id": serviceObjectID}); err != nil {
+			return err
+		}
+		if _, err := config.GetCollection("settings").DeleteOne(ctx, bson.M{"level": models.SettingsLevelService, "target_id": serviceObjectID}); err != nil {
+			return err
+		}
+		// Its config and secrets go with it
+		if _, err := config.GetCollection("service_configs").DeleteMany(ctx, bson.M{"service_id": serviceObjectID}); err != nil {
+			return err
+		}
+		return events.Enqueue(ctx, events.ServiceDeleted, "service", serviceObjectID, before.TenantID, before)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "service not found",
+		})
+		return
+	}
+	if errors.Is(err, errHasDependents) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "other services depend on this service",
+			"msg":   "remove the dependencies on the service first",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to delete service",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "service", serviceID)
+	if !before.TenantID.IsZero() {
+		audit.SetTenant(r, before.TenantID.Hex())
+	}
+	audit.SetChange(r, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "service deleted successfully",
+	})
+}
+
+// projectTenantID returns the ID of the tenant owning a project, or a zero ID
+// if the project can't be found
+func projectTenantID(ctx context.Context, projectID bson.ObjectID) bson.ObjectID {
+	var project models.Project
+	if err := config.GetCollection("projects").FindOne(ctx, bson.M{"_id": projectID}).Decode(&project); err != nil {
+		return bson.ObjectID{}
+	}
+	return project.TenantID
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"context"
+	"core-service/audit"
+	"core-service/config"
+	"core-service/events"
+	"core-service/middleware"
+	"core-service/models"
+	"core-service/settings"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// settingsPathVars names the path variable holding the ID of the tenant,
+// project or service whose settings a request is about
+var settingsPathVars = map[string]string{
+	models.SettingsLevelTenant:  "tenantId",
+	models.SettingsLevelProject: "projectId",
+	models.SettingsLevelService: "id",
+}
+
+// settingsCollections holds the resources settings are attached to
+var settingsCollections = map[string]string{
+	models.SettingsLevelTenant:  "tenants",
+	models.SettingsLevelProject: "projects",
+	models.SettingsLevelService: "services",
+}
+
+// EnsureSettingsIndexes keeps one settings document per tenant, project and
+// service
+func EnsureSettingsIndexes(ctx context.Context) error {
+	_, err := config.GetCollection("settings").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "level", Value: 1}, {Key: "target_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// settingsTarget parses the ID of the tenant, project or service named in
+// the path and returns it with the tenant it belongs to, writing the error
+// response and returning false if it doesn't exist
+func settingsTarget(ctx context.Context, w http.ResponseWriter, r *http.Request, level string) (bson.ObjectID, bson.ObjectID, bool) {
+	targetID, err := bson.ObjectIDFromHex(mux.Vars(r)[settingsPathVars[level]])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid " + level + " ID format",
+			"msg":   err.Error(),
+		})
+		return targetID, bson.ObjectID{}, false
+	}
+
+	var target struct {
+		TenantID bson.ObjectID `bson:"tenant_id"`
+	}
+	if err := config.GetCollection(settingsCollections[level]).FindOne(ctx, bson.M{"_id": targetID}).Decode(&target); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": level + " not found",
+			"msg":   err.Error(),
+		})
+		return targetID, bson.ObjectID{}, false
+	}
+	if level == models.SettingsLevelTenant {
+		target.TenantID = targetID
+	}
+	return targetID, target.TenantID, true
+}
+
+// findSettings returns the settings document of a tenant, project or service,
+// or an empty one if none was set
+func findSettings(ctx context.Context, level string, targetID bson.ObjectID) (models.Settings, error) {
+	var document models.Settings
+	err := config.GetCollection("settings").FindOne(ctx, bson.M{"level": level, "target_id": targetID}).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Settings{Level: level, TargetID: targetID, Values: map[string]interface{}{}}, nil
+	}
+	if err != nil {
+		return document, err
+	}
+	document.Values, _ = settings.Normalize(document.Values).(map[string]interface{})
+	return document, nil
+}
+
+// GetSettings returns the settings set on a tenant, project or service
+// itself, without what it inherits
+func GetSettings(level string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		targetID, _, ok := settingsTarget(ctx, w, r, level)
+		if !ok {
+			return
+		}
+
+		document, err := findSettings(ctx, level, targetID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "failed to fetch settings",
+				"msg":   err.Error(),
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": document,
+		})
+	}
+}
+
+// PutSettings replaces the settings of a tenant, project or service with the
+// JSON object in the body
+func PutSettings(level string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var values map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid request payload",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		if err := settings.Validate(values); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid settings",
+				"msg":   err.Error(),
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		targetID, tenantID, ok := settingsTarget(ctx, w, r, level)
+		if !ok {
+			return
+		}
+
+		var updatedBy string
+		if claims, ok := middleware.ClaimsFromContext(r.Context()); ok {
+			updatedBy = claims.Subject
+		}
+
+		collection := config.GetCollection("settings")
+		var before, after models.Settings
+		err := config.WithTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			if before, err = findSettings(ctx, level, targetID); err != nil {
+				return err
+			}
+			now := time.Now()
+			filter := bson.M{"level": level, "target_id": targetID}
+			changes := bson.M{
+				"$set": bson.M{
+					"tenant_id":  tenantID,
+					"values":     values,
+					"updated_by": updatedBy,
+					"updated_at": now,
+				},
+				"$setOnInsert": bson.M{"created_at": now},
+			}
+			findOptions := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+			if err := collection.FindOneAndUpdate(ctx, filter, changes, findOptions).Decode(&after); err != nil {
+				return err
+			}
+			after.Values = values
+			return events.Enqueue(ctx, events.SettingsUpdated, level, targetID, tenantID, after)
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "failed to update settings",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		audit.SetResource(r, level+"_settings", targetID.Hex())
+		if !tenantID.IsZero() {
+			audit.SetTenant(r, tenantID.Hex())
+		}
+		audit.SetChange(r, before.Values, after.Values)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "settings updated successfully",
+			"data":    after,
+		})
+	}
+}
+
+// DeleteSettings removes the settings of a tenant, project or service, which
+// then inherits everything
+func DeleteSettings(level string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		targetID, tenantID, ok := settingsTarget(ctx, w, r, level)
+		if !ok {
+			return
+		}
+
+		collection := config.GetCollection("settings")
+		var before models.Settings
+		err := config.WithTransaction(ctx, func(ctx context.Context) error {
+			if err := collection.FindOneAndDelete(ctx, bson.M{"level": level, "target_id": targetID}).Decode(&before); err != nil {
+				return err
+			}
+			before.Values, _ = settings.Normalize(before.Values).(map[string]interface{})
+			return events.Enqueue(ctx, events.SettingsUpdated, level, targetID, tenantID, models.Settings{
+				Level:    level,
+				TargetID: targetID,
+				TenantID: tenantID,
+				Values:   map[string]interface{}{},
+			})
+		})
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "settings not found",
+			})
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "failed to delete settings",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		audit.SetResource(r, level+"_settings", targetID.Hex())
+		if !tenantID.IsZero() {
+			audit.SetTenant(r, tenantID.Hex())
+		}
+		audit.SetChange(r, before.Values, nil)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "settings deleted successfully",
+		})
+	}
+}
+
+// GetEffectiveSettings returns a service's settings merged over those of its
+// project and tenant, with the level each value came from
+func GetEffectiveSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	serviceObjectID, err := bson.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid service ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var service models.Service
+	if err := config.GetCollection("services").FindOne(ctx, bson.M{"_id": serviceObjectID}).Decode(&service); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "service not found",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	var documents []models.Settings
+	for _, level := range []struct {
+		name string
+		id   bson.ObjectID
+	}{
+		{models.SettingsLevelTenant, service.TenantID},
+		{models.SettingsLevelProject, service.ProjectID},
+		{models.SettingsLevelService, service.ID},
+	} {
+		if level.id.IsZero() {
+			continue
+		}
+		document, err := findSettings(ctx, level.name, level.id)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "failed to fetch settings",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		documents = append(documents, document)
+	}
+
+	merged, sources := settings.Merge(documents)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{
+			"service_id": service.ID,
+			"settings":   merged,
+			"sources":    sources,
+		},
+	})
+}
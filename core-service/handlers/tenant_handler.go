@@ -2,15 +2,19 @@ package handlers
 
 import (
 	"context"
+	"core-service/audit"
 	"core-service/config"
+	"core-service/events"
 	"core-service/models"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
@@ -34,7 +38,12 @@ func GetAllTenants(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	totalCount, err := collection.CountDocuments(ctx, bson.M{})
+	filter := bson.M{}
+	if !withSelector(w, r, filter) {
+		return
+	}
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -48,7 +57,7 @@ func GetAllTenants(w http.ResponseWriter, r *http.Request) {
 	findOptions.SetSkip(int64(skip))
 	findOptions.SetLimit(int64(limit))
 
-	cursor, err := collection.Find(ctx, bson.M{}, findOptions)
+	cursor, err := collection.Find(ctx, filter, findOptions)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -97,6 +106,9 @@ func CreateTenant(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if !validateMetadata(w, tenant.Labels, tenant.Annotations) {
+		return
+	}
 	now := time.Now()
 	tenant.CreatedAt = now
 	tenant.UpdatedAt = now
@@ -104,7 +116,16 @@ func CreateTenant(w http.ResponseWriter, r *http.Request) {
 	collection := config.GetCollection("tenants")
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	result, err := collection.InsertOne(ctx, tenant)
+
+	var result *mongo.InsertOneResult
+	err := config.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if result, err = collection.InsertOne(ctx, tenant); err != nil {
+			return err
+		}
+		tenant.ID = result.InsertedID.(bson.ObjectID)
+		return events.Enqueue(ctx, events.TenantCreated, "tenant", tenant.ID, tenant.ID, tenant)
+	})
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -113,6 +134,10 @@ func CreateTenant(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	audit.SetResource(r, "tenant", tenant.ID.Hex())
+	audit.SetTenant(r, tenant.ID.Hex())
+	audit.SetChange(r, nil, tenant)
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message": "tenant created successfully",
@@ -150,3 +175,154 @@ func GetTenantByID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(tenant)
 
 }
+
+// UpdateTenant renames a tenant and replaces its labels and annotations
+func UpdateTenant(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	id := params["id"]
+
+	objID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid tenant ID format",
+		})
+		return
+	}
+
+	var update models.Tenant
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if update.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "name is required",
+		})
+		return
+	}
+	if !validateMetadata(w, update.Labels, update.Annotations) {
+		return
+	}
+
+	collection := config.GetCollection("tenants")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before, after models.Tenant
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&before); err != nil {
+			return err
+		}
+		changes := bson.M{"$set": bson.M{"name": update.Name, "updated_at": time.Now()}}
+		setMetadata(changes, update.Labels, update.Annotations)
+		findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		if err := collection.FindOneAndUpdate(ctx, bson.M{"_id": objID}, changes, findOptions).Decode(&after); err != nil {
+			return err
+		}
+		return events.Enqueue(ctx, events.TenantUpdated, "tenant", objID, objID, after)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "tenant not found",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to update tenant",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "tenant", id)
+	audit.SetTenant(r, id)
+	audit.SetChange(r, before, after)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "tenant updated successfully",
+		"data":    after,
+	})
+}
+
+// DeleteTenant deletes a tenant that has no projects left
+func DeleteTenant(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	id := params["id"]
+
+	objID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid tenant ID format",
+		})
+		return
+	}
+
+	collection := config.GetCollection("tenants")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before models.Tenant
+	err = config.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&before); err != nil {
+			return err
+		}
+		projects, err := config.GetCollection("projects").CountDocuments(ctx, bson.M{"tenant_id": objID})
+		if err != nil {
+			return err
+		}
+		if projects > 0 {
+			return errHasChildren
+		}
+		if _, err := collection.DeleteOne(ctx, bson.M{"_id": objID}); err != nil {
+			return err
+		}
+		if _, err := config.GetCollection("settings").DeleteOne(ctx, bson.M{"level": models.SettingsLevelTenant, "target_id": objID}); err != nil {
+			return err
+		}
+		return events.Enqueue(ctx, events.TenantDeleted, "tenant", objID, objID, before)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "tenant not found",
+		})
+		return
+	}
+	if errors.Is(err, errHasChildren) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "tenant has projects",
+			"msg":   "delete the tenant's projects first",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to delete tenant",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "tenant", id)
+	audit.SetTenant(r, id)
+	audit.SetChange(r, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "tenant deleted successfully",
+	})
+}
@@ -0,0 +1,485 @@
+package handlers
+
+import (
+	"context"
+	"core-service/audit"
+	"core-service/config"
+	"core-service/models"
+	"core-service/webhooks"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// webhookRequest is the body of create and update requests. Active is a
+// pointer so updates can tell "false" from "not given".
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+	Active *bool    `json:"active"`
+}
+
+func validateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	return webhooks.Allowed(rawURL)
+}
+
+// webhookIDs parses the tenant and webhook IDs of a request, writing the
+// error response itself when either is malformed
+func webhookIDs(w http.ResponseWriter, r *http.Request) (bson.ObjectID, bson.ObjectID, bool) {
+	params := mux.Vars(r)
+	tenantID, err := bson.ObjectIDFromHex(params["tenantId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid tenant ID format",
+			"msg":   err.Error(),
+		})
+		return tenantID, bson.ObjectID{}, false
+	}
+	webhookID, err := bson.ObjectIDFromHex(params["webhookId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid webhook ID format",
+			"msg":   err.Error(),
+		})
+		return tenantID, webhookID, false
+	}
+	return tenantID, webhookID, true
+}
+
+// CreateWebhook subscribes a URL to a tenant's events. The signing secret is
+// generated unless given, and only returned in this response.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tenantID, err := bson.ObjectIDFromHex(mux.Vars(r)["tenantId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid tenant ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	var request webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if err := validateWebhookURL(request.URL); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := config.GetCollection("tenants").FindOne(ctx, bson.M{"_id": tenantID}).Err(); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "tenant not found",
+		})
+		return
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		if secret, err = webhooks.GenerateSecret(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "failed to generate secret",
+				"msg":   err.Error(),
+			})
+			return
+		}
+	}
+
+	now := time.Now()
+	subscription := models.WebhookSubscription{
+		TenantID:  tenantID,
+		URL:       request.URL,
+		Events:    request.Events,
+		Secret:    secret,
+		Active:    request.Active == nil || *request.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if subscription.Events == nil {
+		subscription.Events = []string{}
+	}
+
+	result, err := config.GetCollection(webhooks.SubscriptionCollection).InsertOne(ctx, subscription)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to create webhook",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	subscription.ID = result.InsertedID.(bson.ObjectID)
+	audit.SetResource(r, "webhook", subscription.ID.Hex())
+	audit.SetTenant(r, tenantID.Hex())
+	audit.SetChange(r, nil, subscription)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "webhook created successfully",
+		"data": map[string]interface{}{
+			"webhook": subscription,
+			"secret":  secret,
+		},
+	})
+}
+
+// GetWebhooksByTenantID lists a tenant's webhook subscriptions
+func GetWebhooksByTenantID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tenantID, err := bson.ObjectIDFromHex(mux.Vars(r)["tenantId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid tenant ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	collection := config.GetCollection(webhooks.SubscriptionCollection)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"tenant_id": tenantID})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch webhooks",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var subscriptions []models.WebhookSubscription
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if subscriptions == nil {
+		subscriptions = []models.WebhookSubscription{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": subscriptions,
+	})
+}
+
+// UpdateWebhook changes a subscription's URL, event filter, secret or
+// active flag. Omitted fields are left unchanged.
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tenantID, webhookID, ok := webhookIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var request webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	changes := bson.M{"updated_at": time.Now()}
+	if request.URL != "" {
+		if err := validateWebhookURL(request.URL); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid request payload",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		changes["url"] = request.URL
+	}
+	if request.Events != nil {
+		changes["events"] = request.Events
+	}
+	if request.Secret != "" {
+		changes["secret"] = request.Secret
+	}
+	if request.Active != nil {
+		changes["active"] = *request.Active
+	}
+
+	collection := config.GetCollection(webhooks.SubscriptionCollection)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": webhookID, "tenant_id": tenantID}
+	var before, after models.WebhookSubscription
+	err := collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": changes}).Decode(&before)
+	if err == nil {
+		err = collection.FindOne(ctx, filter).Decode(&after)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "webhook not found",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to update webhook",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "webhook", webhookID.Hex())
+	audit.SetTenant(r, tenantID.Hex())
+	audit.SetChange(r, before, after)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "webhook updated successfully",
+		"data":    after,
+	})
+}
+
+// DeleteWebhook removes a subscription. Its pending deliveries become dead
+// letters on their next attempt.
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tenantID, webhookID, ok := webhookIDs(w, r)
+	if !ok {
+		return
+	}
+
+	collection := config.GetCollection(webhooks.SubscriptionCollection)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before models.WebhookSubscription
+	err := collection.FindOneAndDelete(ctx, bson.M{"_id": webhookID, "tenant_id": tenantID}).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "webhook not found",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to delete webhook",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "webhook", webhookID.Hex())
+	audit.SetTenant(r, tenantID.Hex())
+	audit.SetChange(r, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "webhook deleted successfully",
+	})
+}
+
+// listDeliveries writes a page of deliveries matching filter, newest first,
+// optionally narrowed by the status query parameter
+func listDeliveries(w http.ResponseWriter, r *http.Request, filter bson.M) {
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10 // Default limit
+	}
+
+	skip := (page - 1) * limit
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter["status"] = status
+	}
+
+	collection := config.GetCollection(webhooks.DeliveryCollection)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to count deliveries",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64(skip))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch deliveries",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var deliveries []models.WebhookDelivery
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+
+	totalPages := (int(totalCount) + limit - 1) / limit
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        deliveries,
+		"page":        page,
+		"limit":       limit,
+		"total_count": totalCount,
+		"total_pages": totalPages,
+	})
+}
+
+// GetWebhookDeliveries returns the delivery log of a subscription
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tenantID, webhookID, ok := webhookIDs(w, r)
+	if !ok {
+		return
+	}
+	listDeliveries(w, r, bson.M{"tenant_id": tenantID, "subscription_id": webhookID})
+}
+
+// GetDeadLetters lists a tenant's deliveries that failed every attempt
+func GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tenantID, err := bson.ObjectIDFromHex(mux.Vars(r)["tenantId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid tenant ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	listDeliveries(w, r, bson.M{"tenant_id": tenantID, "status": models.DeliveryDead})
+}
+
+// RedeliverWebhook queues a delivery, usually a dead letter, to be sent again
+func RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tenantID, webhookID, ok := webhookIDs(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, err := bson.ObjectIDFromHex(mux.Vars(r)["deliveryId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid delivery ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := config.GetCollection(webhooks.SubscriptionCollection).FindOne(ctx, bson.M{"_id": webhookID, "tenant_id": tenantID}).Err(); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "webhook not found",
+		})
+		return
+	}
+
+	delivery, err := webhooks.Redeliver(ctx, webhookID, deliveryID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "delivery not found",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to redeliver",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetResource(r, "webhook_delivery", deliveryID.Hex())
+	audit.SetTenant(r, tenantID.Hex())
+	audit.SetAction(r, "redeliver")
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "delivery queued",
+		"data":    delivery,
+	})
+}
@@ -1,21 +1,88 @@
 package main
 
 import (
+	"context"
+	"core-service/audit"
 	"core-service/config"
+	"core-service/events"
 	"core-service/handlers"
 	"core-service/middleware"
+	"core-service/models"
+	"core-service/oauth"
+	"core-service/secrets"
+	"core-service/selector"
+	"core-service/tlsutil"
+	"core-service/webhooks"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
+	// Layered configuration: defaults, YAML file, environment, flags
+	if err := config.Load(os.Args[1:]); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
 	// Database connection
 	config.ConnectDB()
 
+	// Pick up rotated secrets from CONFIG_DIR and on SIGHUP
+	config.WatchConfig()
+
+	// Token signing key
+	if err := oauth.LoadSigningKey(); err != nil {
+		log.Fatal("Failed to load OAuth signing key:", err)
+	}
+	// Master key of the service secrets store
+	if err := secrets.LoadMasterKey(); err != nil {
+		log.Fatal("Failed to load secrets master key:", err)
+	}
+	if err := oauth.StartRevocationSync(); err != nil {
+		log.Fatal("Failed to load token revocation list:", err)
+	}
+
+	// Audit log indexes keep the hash chain linear across replicas
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := audit.EnsureIndexes(ctx); err != nil {
+		log.Fatal("Failed to create audit log indexes:", err)
+	}
+	if err := handlers.EnsureEnvironmentIndexes(ctx); err != nil {
+		log.Fatal("Failed to create environment indexes:", err)
+	}
+	if err := handlers.EnsureConfigIndexes(ctx); err != nil {
+		log.Fatal("Failed to create service config indexes:", err)
+	}
+	if err := handlers.EnsureSettingsIndexes(ctx); err != nil {
+		log.Fatal("Failed to create settings indexes:", err)
+	}
+	if err := handlers.EnsureDependencyIndexes(ctx); err != nil {
+		log.Fatal("Failed to create service dependency indexes:", err)
+	}
+	if err := handlers.EnsureCatalogIndexes(ctx); err != nil {
+		log.Fatal("Failed to create service catalog indexes:", err)
+	}
+	if err := selector.EnsureIndexes(ctx, "tenants", "projects", "services"); err != nil {
+		log.Fatal("Failed to create label indexes:", err)
+	}
+	cancel()
+
+	// Relay domain events from the outbox to the event feed
+	if err := events.StartRelay(); err != nil {
+		log.Fatal("Failed to start event relay:", err)
+	}
+
+	// Deliver domain events to webhook subscriptions
+	if err := webhooks.Start(); err != nil {
+		log.Fatal("Failed to start webhook dispatcher:", err)
+	}
+
 	// Create router
 	r := mux.NewRouter()
+	r.Use(middleware.RequestID, audit.Middleware)
 
 	// Public routes (no authentication required)
 	publicRouter := r.PathPrefix("").Subrouter()
@@ -25,14 +92,104 @@ func main() {
 	publicRouter.HandleFunc("/tenants/{tenantId}/projects", handlers.CreateProject).Methods("POST")
 	publicRouter.HandleFunc("/tenants/{tenantId}/projects", handlers.GetAllProjectsByTenantID).Methods("GET")
 	publicRouter.HandleFunc("/projects/{projectId}/services", handlers.GetAllServicesByProjectID).Methods("GET")
-	publicRouter.HandleFunc("/projects/{projectId}/services", handlers.CreateService).Methods("POST")
+	publicRouter.HandleFunc("/projects/{projectId}/environments", handlers.GetEnvironmentsByProjectID).Methods("GET")
+	publicRouter.HandleFunc("/services/{id}/config", handlers.GetServiceConfig).Methods("GET")
+	publicRouter.HandleFunc("/services/{id}/config/versions", handlers.GetServiceConfigVersions).Methods("GET")
+	publicRouter.HandleFunc("/services/{id}/effective-settings", handlers.GetEffectiveSettings).Methods("GET")
+	publicRouter.HandleFunc("/tenants/{tenantId}/settings", handlers.GetSettings(models.SettingsLevelTenant)).Methods("GET")
+	publicRouter.HandleFunc("/tenants/{tenantId}/settings", handlers.PutSettings(models.SettingsLevelTenant)).Methods("PUT")
+	publicRouter.HandleFunc("/tenants/{tenantId}/settings", handlers.DeleteSettings(models.SettingsLevelTenant)).Methods("DELETE")
+	publicRouter.HandleFunc("/projects/{projectId}/settings", handlers.GetSettings(models.SettingsLevelProject)).Methods("GET")
+	publicRouter.HandleFunc("/projects/{projectId}/settings", handlers.PutSettings(models.SettingsLevelProject)).Methods("PUT")
+	publicRouter.HandleFunc("/projects/{projectId}/settings", handlers.DeleteSettings(models.SettingsLevelProject)).Methods("DELETE")
+	publicRouter.HandleFunc("/services/{id}/settings", handlers.GetSettings(models.SettingsLevelService)).Methods("GET")
+	publicRouter.HandleFunc("/services/{id}/settings", handlers.PutSettings(models.SettingsLevelService)).Methods("PUT")
+	publicRouter.HandleFunc("/services/{id}/settings", handlers.DeleteSettings(models.SettingsLevelService)).Methods("DELETE")
+	publicRouter.HandleFunc("/services/{id}/dependencies", handlers.GetServiceDependencies).Methods("GET")
+	publicRouter.HandleFunc("/services/{id}/dependencies", handlers.CreateServiceDependency).Methods("POST")
+	publicRouter.HandleFunc("/services/{id}/dependencies/{dependsOnId}", handlers.DeleteServiceDependency).Methods("DELETE")
+	publicRouter.HandleFunc("/services/{id}/upstream", handlers.GetServiceUpstream).Methods("GET")
+	publicRouter.HandleFunc("/services/{id}/downstream", handlers.GetServiceDownstream).Methods("GET")
+	publicRouter.HandleFunc("/services/{id}/impact", handlers.GetServiceImpact).Methods("GET")
+	publicRouter.HandleFunc("/projects/{projectId}/deploy-order", handlers.GetProjectDeployOrder).Methods("GET")
+	publicRouter.HandleFunc("/catalog/services", handlers.GetCatalogServices).Methods("GET")
+
+	// OAuth2 token endpoints (clients authenticate with their own credentials)
+	publicRouter.HandleFunc("/oauth/token", handlers.IssueToken).Methods("POST")
+	publicRouter.HandleFunc("/oauth/introspect", handlers.IntrospectToken).Methods("POST")
+	publicRouter.HandleFunc("/oauth/revoke", handlers.RevokeToken).Methods("POST")
+	publicRouter.HandleFunc("/.well-known/jwks.json", handlers.GetJWKS).Methods("GET")
 
 	// Internal routes (require API key authentication)
 	internalRouter := r.PathPrefix("").Subrouter()
 	internalRouter.Use(middleware.AuthMiddleware)
-	internalRouter.HandleFunc("/services/{id}", handlers.GetServiceByID).Methods("GET")
+	internalRouter.Handle("/services/{id}", middleware.RequireScope(oauth.ScopeServicesRead)(http.HandlerFunc(handlers.GetServiceByID))).Methods("GET")
+	internalRouter.Handle("/tenants/{id}", middleware.RequireScope(oauth.ScopeTenantsWrite)(http.HandlerFunc(handlers.UpdateTenant))).Methods("PUT")
+	internalRouter.Handle("/tenants/{id}", middleware.RequireScope(oauth.ScopeTenantsWrite)(http.HandlerFunc(handlers.DeleteTenant))).Methods("DELETE")
+	internalRouter.Handle("/projects/{projectId}", middleware.RequireScope(oauth.ScopeTenantsWrite)(http.HandlerFunc(handlers.UpdateProject))).Methods("PUT")
+	internalRouter.Handle("/projects/{projectId}", middleware.RequireScope(oauth.ScopeTenantsWrite)(http.HandlerFunc(handlers.DeleteProject))).Methods("DELETE")
+	internalRouter.Handle("/projects/{projectId}/services", middleware.RequireScope(oauth.ScopeServicesWrite)(http.HandlerFunc(handlers.CreateService))).Methods("POST")
+	internalRouter.Handle("/services/{id}", middleware.RequireScope(oauth.ScopeServicesWrite)(http.HandlerFunc(handlers.UpdateService))).Methods("PUT")
+	internalRouter.Handle("/services/{id}", middleware.RequireScope(oauth.ScopeServicesWrite)(http.HandlerFunc(handlers.DeleteService))).Methods("DELETE")
+	internalRouter.Handle("/services/{id}/config/resolved", middleware.RequireScope(oauth.ScopeConfigRead)(http.HandlerFunc(handlers.ResolveServiceConfig))).Methods("GET")
+	internalRouter.Handle("/services/{id}/config", middleware.RequireScope(oauth.ScopeConfigWrite)(http.HandlerFunc(handlers.UpdateServiceConfig))).Methods("PATCH")
+	internalRouter.Handle("/environments/{id}", middleware.RequireScope(oauth.ScopeServicesRead)(http.HandlerFunc(handlers.GetEnvironmentByID))).Methods("GET")
+	internalRouter.Handle("/projects/{projectId}/environments", middleware.RequireScope(oauth.ScopeEnvironmentsWrite)(http.HandlerFunc(handlers.CreateEnvironment))).Methods("POST")
+	internalRouter.Handle("/environments/{id}", middleware.RequireScope(oauth.ScopeEnvironmentsWrite)(http.HandlerFunc(handlers.UpdateEnvironment))).Methods("PUT")
+	internalRouter.Handle("/environments/{id}", middleware.RequireScope(oauth.ScopeEnvironmentsWrite)(http.HandlerFunc(handlers.DeleteEnvironment))).Methods("DELETE")
+	internalRouter.Handle("/tenants/{tenantId}/webhooks", middleware.RequireScope(oauth.ScopeWebhooksWrite)(http.HandlerFunc(handlers.CreateWebhook))).Methods("POST")
+	internalRouter.Handle("/tenants/{tenantId}/webhooks", middleware.RequireScope(oauth.ScopeWebhooksRead)(http.HandlerFunc(handlers.GetWebhooksByTenantID))).Methods("GET")
+	internalRouter.Handle("/tenants/{tenantId}/webhooks/{webhookId}", middleware.RequireScope(oauth.ScopeWebhooksWrite)(http.HandlerFunc(handlers.UpdateWebhook))).Methods("PUT")
+	internalRouter.Handle("/tenants/{tenantId}/webhooks/{webhookId}", middleware.RequireScope(oauth.ScopeWebhooksWrite)(http.HandlerFunc(handlers.DeleteWebhook))).Methods("DELETE")
+	internalRouter.Handle("/tenants/{tenantId}/webhooks/{webhookId}/deliveries", middleware.RequireScope(oauth.ScopeWebhooksRead)(http.HandlerFunc(handlers.GetWebhookDeliveries))).Methods("GET")
+	internalRouter.Handle("/tenants/{tenantId}/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", middleware.RequireScope(oauth.ScopeWebhooksWrite)(http.HandlerFunc(handlers.RedeliverWebhook))).Methods("POST")
+	internalRouter.Handle("/tenants/{tenantId}/dead-letters", middleware.RequireScope(oauth.ScopeWebhooksRead)(http.HandlerFunc(handlers.GetDeadLetters))).Methods("GET")
+	internalRouter.Handle("/events", middleware.RequireScope(oauth.ScopeEventsRead)(http.HandlerFunc(handlers.GetEvents))).Methods("GET")
+	internalRouter.Handle("/events", middleware.RequireScope(oauth.ScopeEventsWrite)(http.HandlerFunc(handlers.PublishEvent))).Methods("POST")
+
+	// Audit log (requires a token with the audit:read scope)
+	auditRouter := r.PathPrefix("/audit").Subrouter()
+	auditRouter.Use(middleware.AuthMiddleware, middleware.RequireScope(oauth.ScopeAuditRead))
+	auditRouter.HandleFunc("", handlers.GetAuditEntries).Methods("GET")
+	auditRouter.HandleFunc("/export", handlers.ExportAuditEntries).Methods("GET")
+	auditRouter.HandleFunc("/verify", handlers.VerifyAuditLog).Methods("GET")
+
+	// OAuth client management and token revocation (requires an admin token)
+	adminRouter := r.PathPrefix("").Subrouter()
+	adminRouter.Use(middleware.AuthMiddleware, middleware.RequireScope(oauth.ScopeAdmin))
+	if config.App.OAuth.EnforceOneTimeTokens {
+		adminRouter.Use(middleware.OneTimeToken)
+	}
+	adminRouter.HandleFunc("/oauth/clients", handlers.RegisterOAuthClient).Methods("POST")
+	adminRouter.HandleFunc("/oauth/clients", handlers.GetAllOAuthClients).Methods("GET")
+	adminRouter.HandleFunc("/oauth/clients/{clientId}", handlers.DeleteOAuthClient).Methods("DELETE")
+	adminRouter.HandleFunc("/admin/revocations", handlers.CreateRevocation).Methods("POST")
+	adminRouter.HandleFunc("/admin/revocations", handlers.GetAllRevocations).Methods("GET")
 
 	// Start server
-	log.Println("Server starting on :8081")
-	log.Fatal(http.ListenAndServe(":8081", r))
+	server := &http.Server{
+		Addr:         config.App.Addr(),
+		Handler:      r,
+		ReadTimeout:  config.App.Server.ReadTimeout,
+		WriteTimeout: config.App.Server.WriteTimeout,
+		IdleTimeout:  config.App.Server.IdleTimeout,
+	}
+	if !config.App.TLSEnabled() {
+		log.Printf("Server starting on %s", server.Addr)
+		log.Fatal(server.ListenAndServe())
+	}
+
+	clientAuth, err := tlsutil.ParseClientAuth(config.App.TLS.ClientAuth)
+	if err != nil {
+		log.Fatal("Invalid TLS_CLIENT_AUTH:", err)
+	}
+	reloader, err := tlsutil.NewCertReloader(config.App.TLS.CertFile, config.App.TLS.KeyFile, config.App.TLS.CAFile)
+	if err != nil {
+		log.Fatal("Failed to load TLS certificate:", err)
+	}
+	reloader.Watch(config.App.TLS.ReloadInterval)
+	server.TLSConfig = reloader.ServerConfig(clientAuth)
+
+	log.Printf("Server starting with TLS on %s", server.Addr)
+	log.Fatal(server.ListenAndServeTLS("", ""))
 }
@@ -1,84 +1,171 @@
 package middleware
 
 import (
+	"context"
+	"core-service/config"
+	"core-service/oauth"
+	"core-service/tlsutil"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-type Claims struct {
-	ServiceName string `json:"service_name"`
-	jwt.RegisteredClaims
+type contextKey string
+
+const claimsContextKey contextKey = "claims"
+
+// ClaimsFromContext returns the claims of the authenticated caller, if any
+func ClaimsFromContext(ctx context.Context) (*oauth.Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*oauth.Claims)
+	return claims, ok
 }
 
-// AuthMiddleware validates JWT tokens for internal service communication
+// AuthMiddleware authenticates internal service calls by JWT, by verified
+// client-certificate SAN, or both, depending on AUTH_MODE
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
+
+		san, hasSAN := tlsutil.VerifiedPeerSAN(r.TLS, config.App.Auth.AllowedClientSANs)
+		if !hasSAN && (config.App.Auth.Mode == "san" || config.App.Auth.Mode == "jwt_and_san") {
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error": "missing authorization header",
-				"msg":   "Authorization header with Bearer token is required",
+				"error": "client certificate required",
+				"msg":   "a verified client certificate with an allowed SAN is required",
 			})
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error": "invalid authorization format",
-				"msg":   "Authorization header must be in format: Bearer <token>",
-			})
-			return
+		var claims *oauth.Claims
+		if config.App.Auth.Mode == "san" || (config.App.Auth.Mode == "jwt_or_san" && hasSAN && r.Header.Get("Authorization") == "") {
+			claims = &oauth.Claims{
+				ServiceName: san,
+				Scope:       oauth.LegacyScope,
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject: san,
+				},
+			}
+		} else {
+			var ok bool
+			if claims, ok = authenticateToken(w, r); !ok {
+				return
+			}
 		}
 
-		tokenString := parts[1]
+		// Caller is authenticated, proceed to next handler
+		ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+// authenticateToken validates the Bearer token of a request, writing the
+// error response itself when validation fails
+func authenticateToken(w http.ResponseWriter, r *http.Request) (*oauth.Claims, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "missing authorization header",
+			"msg":   "Authorization header with Bearer token is required",
+		})
+		return nil, false
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid authorization format",
+			"msg":   "Authorization header must be in format: Bearer <token>",
+		})
+		return nil, false
+	}
 
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
+	tokenString := parts[1]
+
+	claims, err := oauth.ParseToken(tokenString)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid token",
+			"msg":   err.Error(),
+		})
+		return nil, false
+	}
+
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(time.Now()) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "token expired",
+			"msg":   "please obtain a new token",
+		})
+		return nil, false
+	}
+
+	if oauth.IsRevoked(claims) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "token revoked",
+			"msg":   "please obtain a new token",
+		})
+		return nil, false
+	}
+
+	return claims, true
+}
+
+// RequireScope rejects callers whose token was not granted the given scope.
+// It must run after AuthMiddleware.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok || !claims.HasScope(scope) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error": "insufficient scope",
+					"msg":   "token requires scope " + scope,
+				})
+				return
 			}
-			secretEnv := os.Getenv("JWT_SECRET")
-			return []byte(secretEnv), nil
+			next.ServeHTTP(w, r)
 		})
+	}
+}
 
-		if err != nil {
+// OneTimeToken rejects tokens that were already used on a route guarded by
+// it, preventing replay of captured tokens. It must run after AuthMiddleware.
+func OneTimeToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error": "invalid token",
-				"msg":   err.Error(),
+				"error": "missing token",
+				"msg":   "a one-time token is required",
 			})
 			return
 		}
 
-		if !token.Valid {
-			w.WriteHeader(http.StatusForbidden)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error": "token validation failed",
-				"msg":   "token is not valid",
-			})
-			return
-		}
-
-		if claims.ExpiresAt != nil && claims.ExpiresAt.Before(time.Now()) {
-			w.WriteHeader(http.StatusUnauthorized)
+		if err := oauth.MarkTokenUsed(r.Context(), claims); err != nil {
+			status := http.StatusUnauthorized
+			if !errors.Is(err, oauth.ErrTokenReused) && claims.ID != "" {
+				status = http.StatusInternalServerError
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
 			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error": "token expired",
-				"msg":   "please obtain a new token",
+				"error": "token rejected",
+				"msg":   err.Error(),
 			})
 			return
 		}
-
-		// Token is valid, proceed to next handler
 		next.ServeHTTP(w, r)
 	})
 }
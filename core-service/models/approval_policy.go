@@ -0,0 +1,25 @@
+package models
+
+import "errors"
+
+// ApprovalPolicy makes deployments of a service wait for sign-off before
+// they start. RequiredApprovals distinct callers other than the one who
+// triggered the deployment must approve it; with AllowedRoles set, only
+// callers whose token carries one of the roles count. An approval request
+// expires after ExpirySeconds, or never if it is zero.
+type ApprovalPolicy struct {
+	RequiredApprovals int      `json:"required_approvals" bson:"required_approvals"`
+	AllowedRoles      []string `json:"allowed_roles,omitempty" bson:"allowed_roles,omitempty"`
+	ExpirySeconds     int      `json:"expiry_seconds,omitempty" bson:"expiry_seconds,omitempty"`
+}
+
+// Validate checks the policy for settings that can't be satisfied
+func (p *ApprovalPolicy) Validate() error {
+	if p.RequiredApprovals < 1 {
+		return errors.New("approval_policy.required_approvals must be at least 1")
+	}
+	if p.ExpirySeconds < 0 {
+		return errors.New("approval_policy.expiry_seconds must not be negative")
+	}
+	return nil
+}
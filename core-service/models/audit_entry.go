@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AuditEntry records one mutating API call. Entries form a hash chain: each
+// hash covers the entry's fields and the previous entry's hash, so editing or
+// deleting an entry breaks every hash after it.
+type AuditEntry struct {
+	ID           bson.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Seq          int64         `json:"seq" bson:"seq"`
+	Timestamp    time.Time     `json:"timestamp" bson:"timestamp"`
+	Actor        string        `json:"actor" bson:"actor"`
+	ActorType    string        `json:"actor_type" bson:"actor_type"`
+	TenantID     string        `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	ResourceType string        `json:"resource_type" bson:"resource_type"`
+	ResourceID   string        `json:"resource_id,omitempty" bson:"resource_id,omitempty"`
+	Action       string        `json:"action" bson:"action"`
+	Before       string        `json:"before,omitempty" bson:"before,omitempty"`
+	After        string        `json:"after,omitempty" bson:"after,omitempty"`
+	Diff         []FieldChange `json:"diff,omitempty" bson:"diff,omitempty"`
+	RequestID    string        `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	SourceIP     string        `json:"source_ip,omitempty" bson:"source_ip,omitempty"`
+	Method       string        `json:"method" bson:"method"`
+	Path         string        `json:"path" bson:"path"`
+	StatusCode   int           `json:"status_code" bson:"status_code"`
+	Outcome      string        `json:"outcome" bson:"outcome"`
+	PrevHash     string        `json:"prev_hash" bson:"prev_hash"`
+	Hash         string        `json:"hash" bson:"hash"`
+}
+
+// FieldChange is one top-level field that differs between the before and
+// after state. From and To hold JSON-encoded values.
+type FieldChange struct {
+	Field string `json:"field" bson:"field"`
+	From  string `json:"from,omitempty" bson:"from,omitempty"`
+	To    string `json:"to,omitempty" bson:"to,omitempty"`
+}
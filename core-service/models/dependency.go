@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Dependency records that a service calls another service of the same
+// tenant, in its own project or another one
+type Dependency struct {
+	ID          bson.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	ServiceID   bson.ObjectID `json:"service_id" bson:"service_id"`
+	DependsOnID bson.ObjectID `json:"depends_on_id" bson:"depends_on_id"`
+	TenantID    bson.ObjectID `json:"tenant_id,omitempty" bson:"tenant_id"`
+	Description string        `json:"description,omitempty" bson:"description,omitempty"`
+	CreatedAt   time.Time     `json:"created_at,omitempty" bson:"created_at,omitempty"`
+}
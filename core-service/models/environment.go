@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var environmentNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Environment is a stage of a project, such as dev, staging or prod, that
+// services are deployed to. Order sorts a project's environments in the
+// order versions move through them. Deployments to a protected environment
+// need approval: by ApprovalPolicy if set, else by the service's policy,
+// else by one other caller.
+type Environment struct {
+	ID             bson.ObjectID     `json:"id,omitempty" bson:"_id,omitempty"`
+	ProjectID      bson.ObjectID     `json:"project_id,omitempty" bson:"project_id,omitempty"`
+	TenantID       bson.ObjectID     `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Name           string            `json:"name,omitempty" bson:"name,omitempty"`
+	Order          int               `json:"order" bson:"order"`
+	Protected      bool              `json:"protected" bson:"protected"`
+	Deployer       *DeployerConfig   `json:"deployer,omitempty" bson:"deployer,omitempty"`
+	Variables      map[string]string `json:"variables,omitempty" bson:"variables,omitempty"`
+	ApprovalPolicy *ApprovalPolicy   `json:"approval_policy,omitempty" bson:"approval_policy,omitempty"`
+	CreatedAt      time.Time         `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt      time.Time         `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+// DeployerConfig tells the deploy target where an environment lives. URL
+// overrides the deploy target deployment-service talks to; Parameters are
+// passed on with every deployment.
+type DeployerConfig struct {
+	URL        string            `json:"url,omitempty" bson:"url,omitempty"`
+	Namespace  string            `json:"namespace,omitempty" bson:"namespace,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty" bson:"parameters,omitempty"`
+}
+
+// Validate checks the environment for settings that can't be used
+func (e *Environment) Validate() error {
+	if !environmentNamePattern.MatchString(e.Name) {
+		return errors.New("name must be lowercase letters, digits and dashes")
+	}
+	if e.Order < 0 {
+		return errors.New("order must not be negative")
+	}
+	if e.ApprovalPolicy != nil {
+		return e.ApprovalPolicy.Validate()
+	}
+	return nil
+}
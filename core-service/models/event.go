@@ -0,0 +1,23 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Event is a domain event. It is written to the outbox in the same
+// transaction as the change it describes, then copied to the event feed by
+// the relay, which assigns its position in the feed.
+type Event struct {
+	ID            bson.ObjectID   `json:"id" bson:"_id,omitempty"`
+	Seq           int64           `json:"seq,omitempty" bson:"seq,omitempty"`
+	Type          string          `json:"type" bson:"type"`
+	AggregateType string          `json:"aggregate_type" bson:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id" bson:"aggregate_id"`
+	TenantID      string          `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Payload       json.RawMessage `json:"payload,omitempty" bson:"payload,omitempty"`
+	OccurredAt    time.Time       `json:"occurred_at" bson:"occurred_at"`
+	PublishedAt   *time.Time      `json:"-" bson:"published_at,omitempty"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// HealthCheck describes how deployment-service verifies a service after a
+// deployment succeeds. Probes run every IntervalSeconds; verification passes
+// once all of them succeed SuccessThreshold times in a row, and fails if that
+// doesn't happen within WindowSeconds.
+type HealthCheck struct {
+	Probes           []HealthProbe `json:"probes" bson:"probes"`
+	SuccessThreshold int           `json:"success_threshold,omitempty" bson:"success_threshold,omitempty"`
+	IntervalSeconds  int           `json:"interval_seconds,omitempty" bson:"interval_seconds,omitempty"`
+	WindowSeconds    int           `json:"window_seconds,omitempty" bson:"window_seconds,omitempty"`
+	AutoRollback     bool          `json:"auto_rollback,omitempty" bson:"auto_rollback,omitempty"`
+}
+
+// HealthProbe is a single check. HTTP probes GET URL and expect
+// ExpectedStatus (default 200) and, if set, a body matching the BodyMatch
+// regular expression. TCP probes connect to Address.
+type HealthProbe struct {
+	Type           string `json:"type" bson:"type"`
+	URL            string `json:"url,omitempty" bson:"url,omitempty"`
+	ExpectedStatus int    `json:"expected_status,omitempty" bson:"expected_status,omitempty"`
+	BodyMatch      string `json:"body_match,omitempty" bson:"body_match,omitempty"`
+	Address        string `json:"address,omitempty" bson:"address,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty" bson:"timeout_seconds,omitempty"`
+}
+
+// Validate checks the health check for settings deployment-service can't run
+func (h *HealthCheck) Validate() error {
+	if len(h.Probes) == 0 {
+		return errors.New("health_check.probes must not be empty")
+	}
+	if h.SuccessThreshold < 0 || h.IntervalSeconds < 0 || h.WindowSeconds < 0 {
+		return errors.New("health_check thresholds must not be negative")
+	}
+	for i, p := range h.Probes {
+		switch p.Type {
+		case "http":
+			if p.URL == "" {
+				return fmt.Errorf("health_check.probes[%d]: url is required for http probes", i)
+			}
+			if p.BodyMatch != "" {
+				if _, err := regexp.Compile(p.BodyMatch); err != nil {
+					return fmt.Errorf("health_check.probes[%d]: invalid body_match: %w", i, err)
+				}
+			}
+		case "tcp":
+			if p.Address == "" {
+				return fmt.Errorf("health_check.probes[%d]: address is required for tcp probes", i)
+			}
+		default:
+			return fmt.Errorf("health_check.probes[%d]: unknown type %q", i, p.Type)
+		}
+		if p.TimeoutSeconds < 0 {
+			return fmt.Errorf("health_check.probes[%d]: timeout_seconds must not be negative", i)
+		}
+	}
+	return nil
+}
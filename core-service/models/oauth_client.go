@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// OAuthClient is a service registered with the token issuer. Only a hash of
+// the client secret is stored; the secret itself is returned once on registration.
+// Roles are issued in the client's tokens, for example to decide who may
+// approve a deployment.
+type OAuthClient struct {
+	ID         bson.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	ClientID   string        `json:"client_id,omitempty" bson:"client_id,omitempty"`
+	Name       string        `json:"name,omitempty" bson:"name,omitempty"`
+	SecretHash string        `json:"-" bson:"secret_hash,omitempty"`
+	Scopes     []string      `json:"scopes,omitempty" bson:"scopes,omitempty"`
+	Roles      []string      `json:"roles,omitempty" bson:"roles,omitempty"`
+	CreatedAt  time.Time     `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt  time.Time     `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
@@ -1,16 +1,143 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
+// Lifecycle stages of a service
+const (
+	LifecycleExperimental = "experimental"
+	LifecycleProduction   = "production"
+	LifecycleDeprecated   = "deprecated"
+)
+
+// Criticality tiers run from 1, the most critical, to MaxTier
+const MaxTier = 4
+
+var (
+	teamPattern            = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	runtimePattern         = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+_-]{0,63}$`)
+	scpRepositoryPattern   = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[A-Za-z0-9._/~-]+$`)
+	imageRepositoryPattern = regexp.MustCompile(`^([A-Za-z0-9.-]+(:[0-9]+)?/)?[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*(/[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*)*$`)
+	portProtocols          = []string{"tcp", "udp", "http", "https", "grpc"}
+)
+
+// Service is a deployable unit of a project. Besides what deployment-service
+// needs to deploy it, it carries catalog metadata: who owns it, where its
+// source and images live, what it exposes and how critical it is.
+// LastDeployedAt is kept up to date from deployment events.
 type Service struct {
-	ID          bson.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	ProjectID   bson.ObjectID `json:"project_id,omitempty" bson:"project_id,omitempty"`
-	Name        string        `json:"name,omitempty" bson:"name,omitempty"`
-	Description string        `json:"description,omitempty" bson:"description,omitempty"`
-	CreatedAt   time.Time     `json:"created_at,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt   time.Time     `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	ID              bson.ObjectID     `json:"id,omitempty" bson:"_id,omitempty"`
+	ProjectID       bson.ObjectID     `json:"project_id,omitempty" bson:"project_id,omitempty"`
+	TenantID        bson.ObjectID     `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Name            string            `json:"name,omitempty" bson:"name,omitempty"`
+	Description     string            `json:"description,omitempty" bson:"description,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty" bson:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty" bson:"annotations,omitempty"`
+	Owner           string            `json:"owner,omitempty" bson:"owner,omitempty"`
+	OnCall          string            `json:"on_call,omitempty" bson:"on_call,omitempty"`
+	Repository      string            `json:"repository,omitempty" bson:"repository,omitempty"`
+	Runtime         string            `json:"runtime,omitempty" bson:"runtime,omitempty"`
+	ImageRepository string            `json:"image_repository,omitempty" bson:"image_repository,omitempty"`
+	Ports           []ServicePort     `json:"ports,omitempty" bson:"ports,omitempty"`
+	Lifecycle       string            `json:"lifecycle,omitempty" bson:"lifecycle,omitempty"`
+	Tier            int               `json:"tier,omitempty" bson:"tier,omitempty"`
+	HealthCheck     *HealthCheck      `json:"health_check,omitempty" bson:"health_check,omitempty"`
+	ApprovalPolicy  *ApprovalPolicy   `json:"approval_policy,omitempty" bson:"approval_policy,omitempty"`
+	LastDeployedAt  *time.Time        `json:"last_deployed_at,omitempty" bson:"last_deployed_at,omitempty"`
+	CreatedAt       time.Time         `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt       time.Time         `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+// ServicePort is a port the service exposes. Protocol defaults to tcp.
+type ServicePort struct {
+	Name     string `json:"name,omitempty" bson:"name,omitempty"`
+	Port     int    `json:"port" bson:"port"`
+	Protocol string `json:"protocol,omitempty" bson:"protocol,omitempty"`
+}
+
+// ValidateCatalog checks the service's catalog metadata. All of it is
+// optional.
+func (s *Service) ValidateCatalog() error {
+	if s.Owner != "" && !teamPattern.MatchString(s.Owner) {
+		return errors.New("owner must be a team name of lowercase letters, digits and dashes")
+	}
+	if s.OnCall != "" && !isContact(s.OnCall) {
+		return errors.New("on_call must be an email address or an http(s) URL")
+	}
+	if s.Repository != "" && !isRepository(s.Repository) {
+		return errors.New("repository must be an http(s), ssh or git URL")
+	}
+	if s.Runtime != "" && !runtimePattern.MatchString(s.Runtime) {
+		return errors.New("runtime must be a name such as go1.22 or node20")
+	}
+	if s.ImageRepository != "" && !imageRepositoryPattern.MatchString(s.ImageRepository) {
+		return errors.New("image_repository must be an image name without tag or digest")
+	}
+	if s.Lifecycle != "" && s.Lifecycle != LifecycleExperimental && s.Lifecycle != LifecycleProduction && s.Lifecycle != LifecycleDeprecated {
+		return fmt.Errorf("lifecycle must be %s, %s or %s", LifecycleExperimental, LifecycleProduction, LifecycleDeprecated)
+	}
+	if s.Tier < 0 || s.Tier > MaxTier {
+		return fmt.Errorf("tier must be between 1 and %d", MaxTier)
+	}
+
+	names := map[string]bool{}
+	ports := map[string]bool{}
+	for i, port := range s.Ports {
+		if port.Port < 1 || port.Port > 65535 {
+			return fmt.Errorf("ports[%d]: port must be between 1 and 65535", i)
+		}
+		if port.Protocol == "" {
+			s.Ports[i].Protocol = "tcp"
+		} else if !slices.Contains(portProtocols, port.Protocol) {
+			return fmt.Errorf("ports[%d]: unknown protocol %q", i, port.Protocol)
+		}
+		if port.Name != "" {
+			if !teamPattern.MatchString(port.Name) {
+				return fmt.Errorf("ports[%d]: name must be lowercase letters, digits and dashes", i)
+			}
+			if names[port.Name] {
+				return fmt.Errorf("ports[%d]: duplicate name %s", i, port.Name)
+			}
+			names[port.Name] = true
+		}
+		key := fmt.Sprintf("%d/%s", port.Port, s.Ports[i].Protocol)
+		if ports[key] {
+			return fmt.Errorf("ports[%d]: duplicate port %s", i, key)
+		}
+		ports[key] = true
+	}
+	return nil
+}
+
+func isContact(contact string) bool {
+	if address, err := mail.ParseAddress(contact); err == nil && address.Address == contact {
+		return true
+	}
+	u, err := url.Parse(contact)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func isRepository(repository string) bool {
+	if scpRepositoryPattern.MatchString(repository) {
+		return true
+	}
+	u, err := url.Parse(repository)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "ssh", "git":
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var configKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// ServiceConfig is one version of a service's configuration. Every change
+// stores a new version holding the full configuration, so a deployment can
+// pin the version it was made with. Default applies to every environment;
+// Environments, keyed by environment ID, add to or override it for one
+// environment.
+type ServiceConfig struct {
+	ID           bson.ObjectID          `json:"id,omitempty" bson:"_id,omitempty"`
+	ServiceID    bson.ObjectID          `json:"service_id" bson:"service_id"`
+	TenantID     bson.ObjectID          `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Version      int                    `json:"version" bson:"version"`
+	Default      ConfigScope            `json:"default" bson:"default"`
+	Environments map[string]ConfigScope `json:"environments,omitempty" bson:"environments,omitempty"`
+	Comment      string                 `json:"comment,omitempty" bson:"comment,omitempty"`
+	CreatedBy    string                 `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	CreatedAt    time.Time              `json:"created_at" bson:"created_at"`
+}
+
+// ConfigScope holds plain values and encrypted secrets. Secrets are never
+// returned as JSON; see Redacted.
+type ConfigScope struct {
+	Values  map[string]string         `json:"values,omitempty" bson:"values,omitempty"`
+	Secrets map[string]EncryptedValue `json:"-" bson:"secrets,omitempty"`
+}
+
+// EncryptedValue is a secret sealed with a data key, which is sealed with
+// the master key identified by KeyID. Version is the config version that
+// last set the secret. Bound secrets are sealed together with their service,
+// environment, key and version, and only open there; secrets stored before
+// that are not.
+type EncryptedValue struct {
+	Ciphertext []byte `bson:"ciphertext"`
+	Nonce      []byte `bson:"nonce"`
+	DataKey    []byte `bson:"data_key"`
+	KeyNonce   []byte `bson:"key_nonce"`
+	KeyID      string `bson:"key_id"`
+	Version    int    `bson:"version"`
+	Bound      bool   `bson:"bound,omitempty"`
+}
+
+// ConfigChange changes the values and secrets of one scope of a service's
+// configuration: every environment if EnvironmentID is empty, else that
+// environment. A null value removes the key.
+type ConfigChange struct {
+	EnvironmentID string             `json:"environment_id,omitempty"`
+	Values        map[string]*string `json:"values,omitempty"`
+	Secrets       map[string]*string `json:"secrets,omitempty"`
+	Comment       string             `json:"comment,omitempty"`
+	BaseVersion   *int               `json:"base_version,omitempty"`
+}
+
+// Validate checks the change for keys that can't be used
+func (c ConfigChange) Validate() error {
+	if len(c.Values) == 0 && len(c.Secrets) == 0 {
+		return errors.New("values or secrets are required")
+	}
+	if c.EnvironmentID != "" {
+		if _, err := bson.ObjectIDFromHex(c.EnvironmentID); err != nil {
+			return errors.New("environment_id must be an environment ID")
+		}
+	}
+	for key := range c.Values {
+		if !configKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid key %q", key)
+		}
+		if _, ok := c.Secrets[key]; ok {
+			return fmt.Errorf("key %q can't be both a value and a secret", key)
+		}
+	}
+	for key := range c.Secrets {
+		if !configKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return nil
+}
+
+// Clone returns a copy of the scope that can be changed without changing s
+func (s ConfigScope) Clone() ConfigScope {
+	clone := ConfigScope{}
+	if len(s.Values) > 0 {
+		clone.Values = make(map[string]string, len(s.Values))
+		for key, value := range s.Values {
+			clone.Values[key] = value
+		}
+	}
+	if len(s.Secrets) > 0 {
+		clone.Secrets = make(map[string]EncryptedValue, len(s.Secrets))
+		for key, secret := range s.Secrets {
+			clone.Secrets[key] = secret
+		}
+	}
+	return clone
+}
+
+// Redacted returns the scope with each secret replaced by the version that
+// last set it, which is safe to show and to audit
+func (s ConfigScope) Redacted() map[string]interface{} {
+	secrets := make(map[string]int, len(s.Secrets))
+	for key, secret := range s.Secrets {
+		secrets[key] = secret.Version
+	}
+	values := s.Values
+	if values == nil {
+		values = map[string]string{}
+	}
+	return map[string]interface{}{"values": values, "secrets": secrets}
+}
+
+// Redacted returns the configuration with secrets replaced by the version
+// that last set them
+func (c ServiceConfig) Redacted() map[string]interface{} {
+	environments := make(map[string]interface{}, len(c.Environments))
+	for id, scope := range c.Environments {
+		environments[id] = scope.Redacted()
+	}
+	return map[string]interface{}{
+		"service_id":   c.ServiceID,
+		"version":      c.Version,
+		"default":      c.Default.Redacted(),
+		"environments": environments,
+		"comment":      c.Comment,
+		"created_by":   c.CreatedBy,
+		"created_at":   c.CreatedAt,
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Levels settings can be set at, from the most general to the most specific
+const (
+	SettingsLevelTenant  = "tenant"
+	SettingsLevelProject = "project"
+	SettingsLevelService = "service"
+)
+
+// Settings is the settings document of one tenant, project or service. Values
+// is a JSON object that is deep-merged over the settings of the levels above:
+// objects are merged key by key, anything else replaces the inherited value,
+// and null removes it.
+type Settings struct {
+	ID        bson.ObjectID          `json:"id,omitempty" bson:"_id,omitempty"`
+	Level     string                 `json:"level" bson:"level"`
+	TargetID  bson.ObjectID          `json:"target_id" bson:"target_id"`
+	TenantID  bson.ObjectID          `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Values    map[string]interface{} `json:"values" bson:"values"`
+	UpdatedBy string                 `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+	CreatedAt time.Time              `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt time.Time              `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+// SettingSource says where an effective setting came from
+type SettingSource struct {
+	Level    string        `json:"level"`
+	TargetID bson.ObjectID `json:"target_id"`
+}
@@ -7,8 +7,10 @@ import (
 )
 
 type Tenant struct {
-	ID        bson.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Name      string        `json:"name,omitempty" bson:"name,omitempty"`
-	CreatedAt time.Time     `json:"created_at,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt time.Time     `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	ID          bson.ObjectID     `json:"id,omitempty" bson:"_id,omitempty"`
+	Name        string            `json:"name,omitempty" bson:"name,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" bson:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty" bson:"annotations,omitempty"`
+	CreatedAt   time.Time         `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt   time.Time         `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
 }
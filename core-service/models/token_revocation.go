@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// TokenRevocation revokes either a single token by its jti, or every token
+// issued to a service before a point in time
+type TokenRevocation struct {
+	ID           bson.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	JTI          string        `json:"jti,omitempty" bson:"jti,omitempty"`
+	ClientID     string        `json:"client_id,omitempty" bson:"client_id,omitempty"`
+	ServiceName  string        `json:"service_name,omitempty" bson:"service_name,omitempty"`
+	IssuedBefore time.Time     `json:"issued_before,omitempty" bson:"issued_before,omitempty"`
+	Reason       string        `json:"reason,omitempty" bson:"reason,omitempty"`
+	RevokedBy    string        `json:"revoked_by,omitempty" bson:"revoked_by,omitempty"`
+	ExpiresAt    time.Time     `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	RevokedAt    time.Time     `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
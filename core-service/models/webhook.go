@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// WebhookSubscription delivers a tenant's domain events to a URL. An empty
+// Events filter matches every event type. The secret signs deliveries and is
+// only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID        bson.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	TenantID  bson.ObjectID `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	URL       string        `json:"url" bson:"url"`
+	Events    []string      `json:"events" bson:"events"`
+	Secret    string        `json:"-" bson:"secret"`
+	Active    bool          `json:"active" bson:"active"`
+	CreatedAt time.Time     `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt time.Time     `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryDead marks a dead letter: every attempt failed
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one event to be delivered to one subscription, with the
+// log of every attempt
+type WebhookDelivery struct {
+	ID             bson.ObjectID     `json:"id,omitempty" bson:"_id,omitempty"`
+	SubscriptionID bson.ObjectID     `json:"subscription_id" bson:"subscription_id"`
+	TenantID       bson.ObjectID     `json:"tenant_id" bson:"tenant_id"`
+	EventID        bson.ObjectID     `json:"event_id" bson:"event_id"`
+	EventType      string            `json:"event_type" bson:"event_type"`
+	Body           json.RawMessage   `json:"body" bson:"body"`
+	Status         DeliveryStatus    `json:"status" bson:"status"`
+	Attempts       int               `json:"attempts" bson:"attempts"`
+	NextAttemptAt  time.Time         `json:"next_attempt_at" bson:"next_attempt_at"`
+	LockedUntil    time.Time         `json:"-" bson:"locked_until"`
+	Log            []DeliveryAttempt `json:"log" bson:"log"`
+	CreatedAt      time.Time         `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at" bson:"updated_at"`
+}
+
+// DeliveryAttempt records the outcome of one delivery attempt
+type DeliveryAttempt struct {
+	At         time.Time `json:"at" bson:"at"`
+	StatusCode int       `json:"status_code,omitempty" bson:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms" bson:"duration_ms"`
+}
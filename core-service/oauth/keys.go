@@ -0,0 +1,117 @@
+package oauth
+
+import (
+	"core-service/config"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+)
+
+var signingKey *rsa.PrivateKey
+var keyID string
+
+// LoadSigningKey loads the RSA key used to sign access tokens from
+// OAUTH_SIGNING_KEY_FILE. Without a key file an ephemeral key is generated,
+// which only works while core-service runs as a single replica.
+func LoadSigningKey() error {
+	var key *rsa.PrivateKey
+
+	if config.App.OAuth.SigningKeyFile == "" {
+		generated, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return fmt.Errorf("failed to generate signing key: %v", err)
+		}
+		log.Println("⚠️ OAUTH_SIGNING_KEY_FILE not set, using an ephemeral signing key")
+		key = generated
+	} else {
+		data, err := os.ReadFile(config.App.OAuth.SigningKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read signing key: %v", err)
+		}
+		parsed, err := parseRSAPrivateKey(data)
+		if err != nil {
+			return err
+		}
+		key = parsed
+	}
+
+	kid, err := thumbprint(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	signingKey = key
+	keyID = kid
+	log.Printf("✓ OAuth signing key loaded (kid %s)", keyID)
+	return nil
+}
+
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key must be an RSA key")
+	}
+	return key, nil
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint used as the key ID
+func thumbprint(pub *rsa.PublicKey) (string, error) {
+	// Members must be in lexicographic order with no whitespace
+	canonical, err := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{
+		E:   encodeExponent(pub.E),
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func encodeExponent(e int) string {
+	return base64.RawURLEncoding.EncodeToString(big.NewInt(int64(e)).Bytes())
+}
+
+// JWKS returns the JSON Web Key Set containing the public signing key
+func JWKS() map[string]interface{} {
+	pub := signingKey.PublicKey
+	return map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": "RS256",
+				"kid": keyID,
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   encodeExponent(pub.E),
+			},
+		},
+	}
+}
@@ -0,0 +1,198 @@
+package oauth
+
+import (
+	"context"
+	"core-service/config"
+	"core-service/models"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ErrTokenReused is returned when a one-time token is presented again
+var ErrTokenReused = errors.New("token has already been used")
+
+// revocationCache mirrors the revoked_tokens collection so checks don't hit
+// Mongo on every request. Each replica refreshes it periodically.
+type revocationCache struct {
+	mu    sync.RWMutex
+	jtis  map[string]bool
+	rules []models.TokenRevocation
+}
+
+var revocations = &revocationCache{jtis: map[string]bool{}}
+
+func (c *revocationCache) add(revocation models.TokenRevocation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if revocation.JTI != "" {
+		c.jtis[revocation.JTI] = true
+	} else {
+		c.rules = append(c.rules, revocation)
+	}
+}
+
+func (c *revocationCache) replace(all []models.TokenRevocation) {
+	jtis := map[string]bool{}
+	var rules []models.TokenRevocation
+	for _, revocation := range all {
+		if revocation.JTI != "" {
+			jtis[revocation.JTI] = true
+		} else {
+			rules = append(rules, revocation)
+		}
+	}
+
+	c.mu.Lock()
+	c.jtis = jtis
+	c.rules = rules
+	c.mu.Unlock()
+}
+
+// StartRevocationSync creates the revocation indexes, loads the revocation
+// list and keeps it refreshed in the background
+func StartRevocationSync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Expired entries are removed by Mongo once the tokens they cover are dead
+	ttlIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	if _, err := config.GetCollection("revoked_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		ttlIndex,
+		{Keys: bson.D{{Key: "jti", Value: 1}}},
+	}); err != nil {
+		return err
+	}
+	if _, err := config.GetCollection("used_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		ttlIndex,
+		{Keys: bson.D{{Key: "jti", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}); err != nil {
+		return err
+	}
+
+	if err := refreshRevocations(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.App.OAuth.RevocationRefreshInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := refreshRevocations(ctx); err != nil {
+				log.Printf("Failed to refresh token revocation list: %v", err)
+			}
+			cancel()
+		}
+	}()
+	return nil
+}
+
+func refreshRevocations(ctx context.Context) error {
+	collection := config.GetCollection("revoked_tokens")
+	cursor, err := collection.Find(ctx, bson.M{"expires_at": bson.M{"$gt": time.Now()}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var all []models.TokenRevocation
+	if err := cursor.All(ctx, &all); err != nil {
+		return err
+	}
+	revocations.replace(all)
+	return nil
+}
+
+// Revoke stores a revocation and applies it to this replica immediately.
+// Other replicas pick it up on their next refresh.
+func Revoke(ctx context.Context, revocation models.TokenRevocation) error {
+	if revocation.JTI == "" && (revocation.ServiceName == "" || revocation.IssuedBefore.IsZero()) {
+		return errors.New("either jti or service_name with issued_before is required")
+	}
+
+	revocation.ID = bson.ObjectID{}
+	revocation.RevokedAt = time.Now()
+	if revocation.ExpiresAt.IsZero() {
+		// A rule only matters while tokens issued before it can still be alive
+		revocation.ExpiresAt = revocation.IssuedBefore.Add(config.App.OAuth.MaxTokenLifetime)
+	}
+
+	collection := config.GetCollection("revoked_tokens")
+	if _, err := collection.InsertOne(ctx, revocation); err != nil {
+		return err
+	}
+	revocations.add(revocation)
+	return nil
+}
+
+// RevokeToken revokes a single token by its jti until it expires
+func RevokeToken(ctx context.Context, claims *Claims, revokedBy string) error {
+	if claims.ID == "" {
+		return nil
+	}
+
+	revocation := models.TokenRevocation{
+		JTI:       claims.ID,
+		ClientID:  claims.ClientID,
+		RevokedBy: revokedBy,
+		ExpiresAt: time.Now().Add(config.App.OAuth.MaxTokenLifetime),
+	}
+	if claims.ExpiresAt != nil {
+		revocation.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return Revoke(ctx, revocation)
+}
+
+// IsRevoked reports whether the token has been revoked by jti or by a
+// service-wide rule
+func IsRevoked(claims *Claims) bool {
+	revocations.mu.RLock()
+	defer revocations.mu.RUnlock()
+
+	if claims.ID != "" && revocations.jtis[claims.ID] {
+		return true
+	}
+	for _, rule := range revocations.rules {
+		if rule.ServiceName != claims.ServiceName {
+			continue
+		}
+		// Tokens without an issue time can't prove they are newer than the rule
+		if claims.IssuedAt == nil || claims.IssuedAt.Before(rule.IssuedBefore) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkTokenUsed records the use of a one-time token, returning ErrTokenReused
+// if it was presented before
+func MarkTokenUsed(ctx context.Context, claims *Claims) error {
+	if claims.ID == "" {
+		return errors.New("token has no jti")
+	}
+
+	expiresAt := time.Now().Add(config.App.OAuth.MaxTokenLifetime)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	collection := config.GetCollection("used_tokens")
+	_, err := collection.InsertOne(ctx, bson.M{
+		"jti":        claims.ID,
+		"expires_at": expiresAt,
+		"used_at":    time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrTokenReused
+	}
+	return err
+}
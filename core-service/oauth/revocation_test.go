@@ -0,0 +1,169 @@
+package oauth
+
+import (
+	"context"
+	"core-service/config"
+	"core-service/models"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// useTestDB connects to the MongoDB at TEST_MONGO_URI with a database of its
+// own, dropped afterwards. Tests that need MongoDB are skipped without it.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI not set")
+	}
+	previous := config.App.Mongo
+	config.App.Mongo.URI = uri
+	config.App.Mongo.Database = "core_test_" + bson.NewObjectID().Hex()
+	config.ConnectDB()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		config.MongoClient.Database(config.App.Mongo.Database).Drop(ctx)
+		config.MongoClient.Disconnect(ctx)
+		config.MongoClient = nil
+		config.App.Mongo = previous
+	})
+}
+
+// useRevocations replaces the revocation cache for the test
+func useRevocations(t *testing.T) {
+	previous := revocations
+	revocations = &revocationCache{jtis: map[string]bool{}}
+	t.Cleanup(func() { revocations = previous })
+}
+
+func TestIsRevoked(t *testing.T) {
+	useRevocations(t)
+	cutoff := time.Now().Add(-time.Hour)
+	revocations.add(models.TokenRevocation{JTI: "revoked-jti"})
+	revocations.add(models.TokenRevocation{ServiceName: "deployment-service", IssuedBefore: cutoff})
+
+	issued := func(at time.Time) *jwt.NumericDate {
+		return jwt.NewNumericDate(at)
+	}
+	tests := []struct {
+		name   string
+		claims Claims
+		want   bool
+	}{
+		{"revoked jti", Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "revoked-jti", IssuedAt: issued(time.Now())}}, true},
+		{"other jti", Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "other-jti", IssuedAt: issued(time.Now())}}, false},
+		{"issued before the rule", Claims{ServiceName: "deployment-service", RegisteredClaims: jwt.RegisteredClaims{IssuedAt: issued(cutoff.Add(-time.Minute))}}, true},
+		{"issued after the rule", Claims{ServiceName: "deployment-service", RegisteredClaims: jwt.RegisteredClaims{IssuedAt: issued(cutoff.Add(time.Minute))}}, false},
+		{"no issue time", Claims{ServiceName: "deployment-service"}, true},
+		{"other service without an issue time", Claims{ServiceName: "billing"}, false},
+		{"other service issued before the rule", Claims{ServiceName: "billing", RegisteredClaims: jwt.RegisteredClaims{IssuedAt: issued(cutoff.Add(-time.Minute))}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRevoked(&tt.claims); got != tt.want {
+				t.Fatalf("IsRevoked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevokeCacheReplace(t *testing.T) {
+	useRevocations(t)
+	revocations.add(models.TokenRevocation{JTI: "stale"})
+	revocations.replace([]models.TokenRevocation{
+		{JTI: "fresh"},
+		{ServiceName: "billing", IssuedBefore: time.Now()},
+	})
+
+	if IsRevoked(&Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "stale", IssuedAt: jwt.NewNumericDate(time.Now())}}) {
+		t.Fatal("a revocation dropped by the refresh is still applied")
+	}
+	if !IsRevoked(&Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "fresh"}}) {
+		t.Fatal("a refreshed jti revocation is not applied")
+	}
+	if !IsRevoked(&Claims{ServiceName: "billing"}) {
+		t.Fatal("a refreshed service rule is not applied")
+	}
+}
+
+func TestRevokeRequiresJTIOrRule(t *testing.T) {
+	for _, revocation := range []models.TokenRevocation{
+		{},
+		{ServiceName: "billing"},
+		{IssuedBefore: time.Now()},
+	} {
+		if err := Revoke(context.Background(), revocation); err == nil {
+			t.Fatalf("Revoke(%+v) succeeded, want an error", revocation)
+		}
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	useTestDB(t)
+	useRevocations(t)
+	ctx := context.Background()
+
+	claims := &Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "jti-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+	if err := RevokeToken(ctx, claims, "admin"); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	cutoff := time.Now()
+	if err := Revoke(ctx, models.TokenRevocation{ServiceName: "billing", IssuedBefore: cutoff}); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	// A fresh cache, as on another replica, sees both after a refresh
+	revocations = &revocationCache{jtis: map[string]bool{}}
+	if err := refreshRevocations(ctx); err != nil {
+		t.Fatalf("refreshRevocations: %v", err)
+	}
+	if !IsRevoked(claims) {
+		t.Fatal("the revoked jti is not revoked after a refresh")
+	}
+	if !IsRevoked(&Claims{ServiceName: "billing", RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(cutoff.Add(-time.Minute))}}) {
+		t.Fatal("the service rule is not applied after a refresh")
+	}
+
+	var stored models.TokenRevocation
+	if err := config.GetCollection("revoked_tokens").FindOne(ctx, bson.M{"service_name": "billing"}).Decode(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if want := cutoff.Add(config.App.OAuth.MaxTokenLifetime); stored.ExpiresAt.Sub(want).Abs() > time.Second {
+		t.Fatalf("rule expires at %s, want %s", stored.ExpiresAt, want)
+	}
+}
+
+func TestMarkTokenUsed(t *testing.T) {
+	if err := MarkTokenUsed(context.Background(), &Claims{}); err == nil {
+		t.Fatal("MarkTokenUsed accepted a token without a jti")
+	}
+
+	useTestDB(t)
+	ctx := context.Background()
+	// As created by StartRevocationSync, without its refresh loop
+	if _, err := config.GetCollection("used_tokens").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "jti", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	claims := &Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "one-time", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+	if err := MarkTokenUsed(ctx, claims); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := MarkTokenUsed(ctx, claims); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("second use: error = %v, want ErrTokenReused", err)
+	}
+	if err := MarkTokenUsed(ctx, &Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "another"}}); err != nil {
+		t.Fatalf("another token: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package oauth
+
+import (
+	"core-service/config"
+	"core-service/models"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// ScopeServicesRead allows reading service definitions
+	ScopeServicesRead = "services:read"
+	// ScopeAdmin allows managing OAuth clients
+	ScopeAdmin = "oauth:admin"
+	// ScopeAuditRead allows reading and exporting the audit log
+	ScopeAuditRead = "audit:read"
+	// ScopeEventsRead allows reading the domain event feed
+	ScopeEventsRead = "events:read"
+	// ScopeEventsWrite allows services to publish their own domain events
+	ScopeEventsWrite = "events:write"
+	// ScopeConfigRead allows reading service configuration with its
+	// secrets decrypted
+	ScopeConfigRead = "config:read"
+	// ScopeConfigWrite allows changing service configuration and secrets
+	ScopeConfigWrite = "config:write"
+	// ScopeEnvironmentsWrite allows creating, changing and deleting
+	// environments, including their approval gates and deploy targets
+	ScopeEnvironmentsWrite = "environments:write"
+	// ScopeServicesWrite allows creating, changing and deleting services,
+	// including their approval policies and health checks
+	ScopeServicesWrite = "services:write"
+	// ScopeTenantsWrite allows changing and deleting tenants and projects
+	ScopeTenantsWrite = "tenants:write"
+	// ScopeWebhooksRead allows listing webhooks and reading their deliveries
+	ScopeWebhooksRead = "webhooks:read"
+	// ScopeWebhooksWrite allows managing webhooks and redelivering events
+	ScopeWebhooksWrite = "webhooks:write"
+
+	// LegacyScope is granted to tokens signed with the shared JWT secret,
+	// which predate scoped tokens and are only used by deployment-service
+	LegacyScope = ScopeServicesRead + " " + ScopeEventsWrite + " " + ScopeConfigRead
+)
+
+// Claims represents the JWT claims of both issued access tokens and
+// legacy shared-secret service tokens
+type Claims struct {
+	ServiceName string   `json:"service_name"`
+	ClientID    string   `json:"client_id,omitempty"`
+	Scope       string   `json:"scope,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Scopes returns the space-separated scope claim as a slice
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// HasScope reports whether the token was granted the given scope
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes(), scope)
+}
+
+// IssueToken signs a short-lived access token for a client. The requested
+// scopes must be a subset of the client's allowed scopes; an empty request
+// grants all of them.
+func IssueToken(client *models.OAuthClient, requested []string) (string, *Claims, error) {
+	scopes := client.Scopes
+	if len(requested) > 0 {
+		for _, scope := range requested {
+			if !slices.Contains(client.Scopes, scope) {
+				return "", nil, fmt.Errorf("scope %q is not allowed for this client", scope)
+			}
+		}
+		scopes = requested
+	}
+
+	jti, err := newTokenID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		ServiceName: client.Name,
+		ClientID:    client.ClientID,
+		Scope:       strings.Join(scopes, " "),
+		Roles:       client.Roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   client.ClientID,
+			Issuer:    config.App.OAuth.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.App.OAuth.TokenTTL)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	tokenString, err := token.SignedString(signingKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenString, claims, nil
+}
+
+// ParseToken verifies an access token issued by this service (RS256) or a
+// legacy service token signed with the shared JWT secret (HS256)
+func ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA:
+			if kid, _ := token.Header["kid"].(string); kid != keyID {
+				return nil, errors.New("unknown signing key")
+			}
+			return &signingKey.PublicKey, nil
+		case *jwt.SigningMethodHMAC:
+			// Accept the previous secret during its rotation grace period
+			keys := jwt.VerificationKeySet{}
+			for _, secret := range config.JWTSecrets() {
+				keys.Keys = append(keys.Keys, secret)
+			}
+			return keys, nil
+		default:
+			return nil, jwt.ErrSignatureInvalid
+		}
+	}, jwt.WithValidMethods([]string{"RS256", "HS256"}))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("token is not valid")
+	}
+
+	if token.Method.Alg() == "RS256" {
+		if claims.Issuer != config.App.OAuth.Issuer {
+			return nil, errors.New("token has an unexpected issuer")
+		}
+	} else {
+		// Anyone with the shared secret can sign any claims, so legacy
+		// tokens never get more than the legacy scope
+		claims.Scope = LegacyScope
+	}
+
+	return claims, nil
+}
+
+func newTokenID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
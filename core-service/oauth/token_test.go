@@ -0,0 +1,239 @@
+package oauth
+
+import (
+	"context"
+	"core-service/config"
+	"core-service/models"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testJWTSecret = "legacy-shared-secret"
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET", testJWTSecret)
+	os.Setenv("OAUTH_ADMIN_CLIENT_SECRET", "admin-secret")
+	if err := config.Reload(); err != nil {
+		log.Fatal(err)
+	}
+	if err := LoadSigningKey(); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+// sign signs claims the way a caller might, right or wrong
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func testClaims(scope string, expires time.Time) *Claims {
+	return &Claims{
+		ServiceName: "deployment-service",
+		ClientID:    "client",
+		Scope:       scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti",
+			Subject:   "client",
+			Issuer:    config.App.OAuth.Issuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := time.Now().Add(time.Hour)
+	expired := time.Now().Add(-time.Minute)
+	wrongIssuer := testClaims(ScopeAuditRead, valid)
+	wrongIssuer.Issuer = "someone-else"
+
+	tests := []struct {
+		name      string
+		token     string
+		wantErr   bool
+		wantScope string
+	}{
+		{
+			name:      "RS256",
+			token:     sign(t, jwt.SigningMethodRS256, signingKey, keyID, testClaims(ScopeAuditRead+" "+ScopeConfigWrite, valid)),
+			wantScope: ScopeAuditRead + " " + ScopeConfigWrite,
+		},
+		{
+			name:    "RS256 expired",
+			token:   sign(t, jwt.SigningMethodRS256, signingKey, keyID, testClaims(ScopeAuditRead, expired)),
+			wantErr: true,
+		},
+		{
+			name:    "RS256 with an unknown kid",
+			token:   sign(t, jwt.SigningMethodRS256, signingKey, "other-key", testClaims(ScopeAuditRead, valid)),
+			wantErr: true,
+		},
+		{
+			name:    "RS256 without a kid",
+			token:   sign(t, jwt.SigningMethodRS256, signingKey, "", testClaims(ScopeAuditRead, valid)),
+			wantErr: true,
+		},
+		{
+			name:    "RS256 signed by another key",
+			token:   sign(t, jwt.SigningMethodRS256, otherKey, keyID, testClaims(ScopeAuditRead, valid)),
+			wantErr: true,
+		},
+		{
+			name:    "RS256 from another issuer",
+			token:   sign(t, jwt.SigningMethodRS256, signingKey, keyID, wrongIssuer),
+			wantErr: true,
+		},
+		{
+			name:      "HS256 gets the legacy scope",
+			token:     sign(t, jwt.SigningMethodHS256, []byte(testJWTSecret), "", testClaims("", valid)),
+			wantScope: LegacyScope,
+		},
+		{
+			name:      "HS256 scope claim is ignored",
+			token:     sign(t, jwt.SigningMethodHS256, []byte(testJWTSecret), "", testClaims(ScopeAdmin+" "+ScopeAuditRead, valid)),
+			wantScope: LegacyScope,
+		},
+		{
+			name:    "HS256 with the wrong secret",
+			token:   sign(t, jwt.SigningMethodHS256, []byte("guessed"), "", testClaims("", valid)),
+			wantErr: true,
+		},
+		{
+			name:    "HS256 expired",
+			token:   sign(t, jwt.SigningMethodHS256, []byte(testJWTSecret), "", testClaims("", expired)),
+			wantErr: true,
+		},
+		{
+			name:    "HS512 is not accepted",
+			token:   sign(t, jwt.SigningMethodHS512, []byte(testJWTSecret), "", testClaims("", valid)),
+			wantErr: true,
+		},
+		{
+			name:    "unsigned",
+			token:   sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", testClaims(ScopeAdmin, valid)),
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			token:   "not.a.token",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseToken(tt.token)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseToken succeeded with scope %q, want an error", claims.Scope)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseToken: %v", err)
+			}
+			if claims.Scope != tt.wantScope {
+				t.Fatalf("scope = %q, want %q", claims.Scope, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestIssueToken(t *testing.T) {
+	client := &models.OAuthClient{
+		ClientID: "client",
+		Name:     "deployment-service",
+		Scopes:   []string{ScopeServicesRead, ScopeEventsWrite},
+		Roles:    []string{"release-manager"},
+	}
+
+	tests := []struct {
+		name      string
+		requested []string
+		wantScope string
+		wantErr   bool
+	}{
+		{name: "all scopes by default", wantScope: ScopeServicesRead + " " + ScopeEventsWrite},
+		{name: "narrowed", requested: []string{ScopeEventsWrite}, wantScope: ScopeEventsWrite},
+		{name: "scope the client doesn't hold", requested: []string{ScopeAdmin}, wantErr: true},
+		{name: "one scope too many", requested: []string{ScopeServicesRead, ScopeAuditRead}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, issued, err := IssueToken(client, tt.requested)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("IssueToken granted %q, want an error", issued.Scope)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("IssueToken: %v", err)
+			}
+			if issued.Scope != tt.wantScope {
+				t.Fatalf("issued scope = %q, want %q", issued.Scope, tt.wantScope)
+			}
+
+			parsed, err := ParseToken(token)
+			if err != nil {
+				t.Fatalf("ParseToken of an issued token: %v", err)
+			}
+			if parsed.Scope != tt.wantScope || parsed.Subject != client.ClientID || parsed.ID == "" {
+				t.Fatalf("parsed claims = %+v, want scope %q for %s with a jti", parsed, tt.wantScope, client.ClientID)
+			}
+			if len(parsed.Roles) != 1 || parsed.Roles[0] != "release-manager" {
+				t.Fatalf("roles = %v, want the client's", parsed.Roles)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	keys := JWKS()["keys"].([]map[string]interface{})
+	if len(keys) != 1 || keys[0]["kid"] != keyID || keys[0]["alg"] != "RS256" {
+		t.Fatalf("JWKS = %v, want the signing key as %s", keys, keyID)
+	}
+}
+
+func TestAuthenticateAdminClient(t *testing.T) {
+	previous := config.App.OAuth.AdminClientID
+	config.App.OAuth.AdminClientID = "admin"
+	t.Cleanup(func() { config.App.OAuth.AdminClientID = previous })
+
+	client, err := AuthenticateClient(context.Background(), "admin", "admin-secret")
+	if err != nil {
+		t.Fatalf("AuthenticateClient: %v", err)
+	}
+	if client.ClientID != "admin" || len(client.Scopes) == 0 {
+		t.Fatalf("client = %+v, want the admin client", client)
+	}
+
+	for _, secret := range []string{"", "wrong", "admin-secret "} {
+		if _, err := AuthenticateClient(context.Background(), "admin", secret); !errors.Is(err, ErrInvalidClient) {
+			t.Fatalf("AuthenticateClient with secret %q: error = %v, want ErrInvalidClient", secret, err)
+		}
+	}
+	if _, err := AuthenticateClient(context.Background(), "", "admin-secret"); !errors.Is(err, ErrInvalidClient) {
+		t.Fatalf("AuthenticateClient without a client ID: error = %v, want ErrInvalidClient", err)
+	}
+}
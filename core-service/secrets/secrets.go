@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"bytes"
+	"core-service/config"
+	"core-service/models"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Secrets are encrypted with envelope encryption: every value gets a fresh
+// 256-bit data key that encrypts it with AES-GCM, and the data key is stored
+// next to it, encrypted with the master key. Only the master key has to be
+// kept outside the database.
+
+var ErrNotConfigured = errors.New("secrets store is not configured, set SECRETS_MASTER_KEY_FILE")
+
+var masterKey []byte
+var masterKeyID string
+
+// LoadMasterKey reads the master key from SECRETS_MASTER_KEY_FILE. The file
+// holds 32 bytes, raw or hex or base64 encoded. Without a key file secrets
+// can't be stored or read, but everything else works.
+func LoadMasterKey() error {
+	if config.App.Secrets.MasterKeyFile == "" {
+		log.Println("⚠️ SECRETS_MASTER_KEY_FILE not set, service secrets are disabled")
+		return nil
+	}
+
+	data, err := os.ReadFile(config.App.Secrets.MasterKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read master key: %v", err)
+	}
+	key, err := parseKey(data)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(key)
+	masterKey = key
+	masterKeyID = hex.EncodeToString(sum[:4])
+	log.Printf("✓ Secrets master key loaded (id %s)", masterKeyID)
+	return nil
+}
+
+func parseKey(data []byte) ([]byte, error) {
+	if len(data) == 32 {
+		return data, nil
+	}
+	text := string(bytes.TrimSpace(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("master key must be 32 bytes, raw or hex or base64 encoded")
+}
+
+// Binding is where a secret is stored: its service, environment (empty for
+// the defaults), key and the config version that set it. It is sealed along
+// with the secret as additional data, so a ciphertext copied to another
+// service, environment, key or version fails to decrypt.
+type Binding struct {
+	ServiceID     string
+	EnvironmentID string
+	Key           string
+	Version       int
+}
+
+func (b Binding) additionalData() []byte {
+	return []byte(fmt.Sprintf("%q %q %q %d", b.ServiceID, b.EnvironmentID, b.Key, b.Version))
+}
+
+// Encrypt seals a secret value under a new data key, bound to where it is
+// stored
+func Encrypt(plaintext string, binding Binding) (models.EncryptedValue, error) {
+	if masterKey == nil {
+		return models.EncryptedValue{}, ErrNotConfigured
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return models.EncryptedValue{}, err
+	}
+	additionalData := binding.additionalData()
+	ciphertext, nonce, err := seal(dataKey, []byte(plaintext), additionalData)
+	if err != nil {
+		return models.EncryptedValue{}, err
+	}
+	encryptedKey, keyNonce, err := seal(masterKey, dataKey, additionalData)
+	if err != nil {
+		return models.EncryptedValue{}, err
+	}
+
+	return models.EncryptedValue{
+		Ciphertext: ciphertext,
+		Nonce:      nonce,
+		DataKey:    encryptedKey,
+		KeyNonce:   keyNonce,
+		KeyID:      masterKeyID,
+		Version:    binding.Version,
+		Bound:      true,
+	}, nil
+}
+
+// Decrypt opens a secret value sealed by Encrypt with the same binding. The
+// binding's version is taken from the value. Secrets stored before they were
+// bound open anywhere until they are set again.
+func Decrypt(value models.EncryptedValue, binding Binding) (string, error) {
+	if masterKey == nil {
+		return "", ErrNotConfigured
+	}
+	if value.KeyID != masterKeyID {
+		return "", fmt.Errorf("secret was encrypted with master key %s, not %s", value.KeyID, masterKeyID)
+	}
+
+	var additionalData []byte
+	if value.Bound {
+		binding.Version = value.Version
+		additionalData = binding.additionalData()
+	}
+	dataKey, err := open(masterKey, value.DataKey, value.KeyNonce, additionalData)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt data key: %v", err)
+	}
+	plaintext, err := open(dataKey, value.Ciphertext, value.Nonce, additionalData)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+func seal(key, plaintext, additionalData []byte) ([]byte, []byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return gcm.Seal(nil, nonce, plaintext, additionalData), nonce, nil
+}
+
+func open(key, ciphertext, nonce, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,187 @@
+package secrets
+
+import (
+	"bytes"
+	"core-service/models"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func useMasterKey(t *testing.T, key []byte, id string) {
+	previousKey, previousID := masterKey, masterKeyID
+	masterKey, masterKeyID = key, id
+	t.Cleanup(func() { masterKey, masterKeyID = previousKey, previousID })
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	useMasterKey(t, bytes.Repeat([]byte{7}, 32), "test")
+	binding := Binding{ServiceID: "svc-1", EnvironmentID: "env-1", Key: "DB_PASSWORD", Version: 3}
+
+	sealed, err := Encrypt("s3cret", binding)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !sealed.Bound || sealed.Version != 3 || sealed.KeyID != "test" {
+		t.Fatalf("sealed value = %+v, want bound to version 3 under key test", sealed)
+	}
+	if bytes.Contains(sealed.Ciphertext, []byte("s3cret")) {
+		t.Fatal("ciphertext contains the plaintext")
+	}
+
+	// The version comes from the stored value
+	opened, err := Decrypt(sealed, Binding{ServiceID: "svc-1", EnvironmentID: "env-1", Key: "DB_PASSWORD"})
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if opened != "s3cret" {
+		t.Fatalf("Decrypt = %q, want s3cret", opened)
+	}
+
+	again, err := Encrypt("s3cret", binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(again.Ciphertext, sealed.Ciphertext) || bytes.Equal(again.DataKey, sealed.DataKey) {
+		t.Fatal("the same secret sealed twice gives the same ciphertext or data key")
+	}
+}
+
+func TestDecryptElsewhere(t *testing.T) {
+	useMasterKey(t, bytes.Repeat([]byte{7}, 32), "test")
+	sealed, err := Encrypt("s3cret", Binding{ServiceID: "svc-1", EnvironmentID: "env-1", Key: "DB_PASSWORD", Version: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherVersion := sealed
+	otherVersion.Version = 4
+
+	tests := []struct {
+		name    string
+		value   models.EncryptedValue
+		binding Binding
+	}{
+		{"other service", sealed, Binding{ServiceID: "svc-2", EnvironmentID: "env-1", Key: "DB_PASSWORD"}},
+		{"other environment", sealed, Binding{ServiceID: "svc-1", EnvironmentID: "env-2", Key: "DB_PASSWORD"}},
+		{"defaults", sealed, Binding{ServiceID: "svc-1", Key: "DB_PASSWORD"}},
+		{"other key", sealed, Binding{ServiceID: "svc-1", EnvironmentID: "env-1", Key: "API_TOKEN"}},
+		{"other version", otherVersion, Binding{ServiceID: "svc-1", EnvironmentID: "env-1", Key: "DB_PASSWORD"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if opened, err := Decrypt(tt.value, tt.binding); err == nil {
+				t.Fatalf("Decrypt = %q, want an error", opened)
+			}
+		})
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	useMasterKey(t, bytes.Repeat([]byte{7}, 32), "test")
+	binding := Binding{ServiceID: "svc-1", Key: "DB_PASSWORD", Version: 1}
+	sealed, err := Encrypt("s3cret", binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flip := func(b []byte) []byte {
+		flipped := bytes.Clone(b)
+		flipped[0] ^= 1
+		return flipped
+	}
+	tests := []struct {
+		name   string
+		tamper func(v *models.EncryptedValue)
+	}{
+		{"ciphertext", func(v *models.EncryptedValue) { v.Ciphertext = flip(v.Ciphertext) }},
+		{"truncated ciphertext", func(v *models.EncryptedValue) { v.Ciphertext = v.Ciphertext[:len(v.Ciphertext)-1] }},
+		{"nonce", func(v *models.EncryptedValue) { v.Nonce = flip(v.Nonce) }},
+		{"data key", func(v *models.EncryptedValue) { v.DataKey = flip(v.DataKey) }},
+		{"key nonce", func(v *models.EncryptedValue) { v.KeyNonce = flip(v.KeyNonce) }},
+		{"unbound", func(v *models.EncryptedValue) { v.Bound = false }},
+		{"master key ID", func(v *models.EncryptedValue) { v.KeyID = "other" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := sealed
+			tt.tamper(&value)
+			if opened, err := Decrypt(value, binding); err == nil {
+				t.Fatalf("Decrypt = %q, want an error", opened)
+			}
+		})
+	}
+}
+
+func TestDecryptUnbound(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	useMasterKey(t, key, "test")
+
+	// As stored before secrets were bound
+	dataKey := bytes.Repeat([]byte{9}, 32)
+	ciphertext, nonce, err := seal(dataKey, []byte("legacy"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedKey, keyNonce, err := seal(key, dataKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := models.EncryptedValue{Ciphertext: ciphertext, Nonce: nonce, DataKey: encryptedKey, KeyNonce: keyNonce, KeyID: "test", Version: 1}
+
+	opened, err := Decrypt(value, Binding{ServiceID: "svc-1", Key: "DB_PASSWORD"})
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if opened != "legacy" {
+		t.Fatalf("Decrypt = %q, want legacy", opened)
+	}
+
+	value.Bound = true
+	if _, err := Decrypt(value, Binding{ServiceID: "svc-1", Key: "DB_PASSWORD"}); err == nil {
+		t.Fatal("an unbound secret marked as bound decrypted")
+	}
+}
+
+func TestNotConfigured(t *testing.T) {
+	useMasterKey(t, nil, "")
+	if _, err := Encrypt("s3cret", Binding{}); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("Encrypt error = %v, want ErrNotConfigured", err)
+	}
+	if _, err := Decrypt(models.EncryptedValue{}, Binding{}); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("Decrypt error = %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0xab}, 32)
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "raw", data: key},
+		{name: "hex", data: []byte(hex.EncodeToString(key) + "\n")},
+		{name: "base64", data: []byte(base64.StdEncoding.EncodeToString(key))},
+		{name: "short", data: key[:16], wantErr: true},
+		{name: "short hex", data: []byte(hex.EncodeToString(key[:24])), wantErr: true},
+		{name: "garbage", data: []byte("not a key"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parseKey(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("parseKey succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseKey: %v", err)
+			}
+			if !bytes.Equal(parsed, key) {
+				t.Fatalf("parseKey = %x, want %x", parsed, key)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package selector
+
+import (
+	"context"
+	"core-service/config"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Label keys are Kubernetes-style names with an optional prefix, except that
+// neither may contain dots, since keys are stored as document fields
+var (
+	labelKeyPattern   = regexp.MustCompile(`^([a-z0-9]([-a-z0-9]{0,251}[a-z0-9])?/)?[A-Za-z0-9]([-A-Za-z0-9_]{0,61}[A-Za-z0-9])?$`)
+	labelValuePattern = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?)?$`)
+)
+
+// maxAnnotationsSize bounds the total size of a resource's annotations
+const maxAnnotationsSize = 256 * 1024
+
+// ValidateLabels checks label keys and values
+func ValidateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid label key %q", key)
+		}
+		if !labelValuePattern.MatchString(value) {
+			return fmt.Errorf("invalid value %q of label %s", value, key)
+		}
+	}
+	return nil
+}
+
+// ValidateAnnotations checks annotation keys, which follow the rules of label
+// keys, and the total size of the annotations. Values are free-form.
+func ValidateAnnotations(annotations map[string]string) error {
+	size := 0
+	for key, value := range annotations {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid annotation key %q", key)
+		}
+		size += len(key) + len(value)
+	}
+	if size > maxAnnotationsSize {
+		return fmt.Errorf("annotations must not exceed %d bytes", maxAnnotationsSize)
+	}
+	return nil
+}
+
+// EnsureIndexes creates a wildcard index on the labels of each collection,
+// which serves selectors on any label key
+func EnsureIndexes(ctx context.Context, collections ...string) error {
+	for _, collection := range collections {
+		_, err := config.GetCollection(collection).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "labels.$**", Value: 1}},
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %v", collection, err)
+		}
+	}
+	return nil
+}
+
+// Parse turns a Kubernetes-style label selector into a MongoDB filter on the
+// labels field. Requirements are separated by commas and all must match:
+//
+//	key=value, key==value  the label is set to value
+//	key!=value             the label is not set to value, or not set at all
+//	key in (a,b)           the label is set to one of the values
+//	key notin (a,b)        the label is not set to any of the values
+//	key                    the label is set
+//	!key                   the label is not set
+//
+// An empty selector matches everything.
+func Parse(selector string) (bson.M, error) {
+	requirements, err := split(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var clauses []bson.M
+	for _, requirement := range requirements {
+		clause, err := parseRequirement(requirement)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, clause)
+	}
+
+	switch len(clauses) {
+	case 0:
+		return bson.M{}, nil
+	case 1:
+		return clauses[0], nil
+	default:
+		return bson.M{"$and": clauses}, nil
+	}
+}
+
+// split separates the requirements of a selector at the commas outside
+// parentheses
+func split(selector string) ([]string, error) {
+	var requirements []string
+	depth, start := 0, 0
+	for i, r := range selector {
+		switch r {
+		case '(':
+			depth++
+			if depth > 1 {
+				return nil, fmt.Errorf("nested parentheses in selector %q", selector)
+			}
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in selector %q", selector)
+			}
+		case ',':
+			if depth == 0 {
+				requirements = append(requirements, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in selector %q", selector)
+	}
+	requirements = append(requirements, selector[start:])
+
+	var trimmed []string
+	for _, requirement := range requirements {
+		if requirement = strings.TrimSpace(requirement); requirement != "" {
+			trimmed = append(trimmed, requirement)
+		}
+	}
+	return trimmed, nil
+}
+
+func parseRequirement(requirement string) (bson.M, error) {
+	if key, ok := strings.CutPrefix(requirement, "!"); ok && !strings.ContainsAny(key, "=!()") {
+		key = strings.TrimSpace(key)
+		if err := validateKey(key); err != nil {
+			return nil, err
+		}
+		return bson.M{field(key): bson.M{"$exists": false}}, nil
+	}
+
+	for _, operator := range []string{"!=", "==", "="} {
+		key, value, ok := strings.Cut(requirement, operator)
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if err := validateKey(key); err != nil {
+			return nil, err
+		}
+		if !labelValuePattern.MatchString(value) {
+			return nil, fmt.Errorf("invalid label value %q in selector", value)
+		}
+		if operator == "!=" {
+			return bson.M{field(key): bson.M{"$ne": value}}, nil
+		}
+		return bson.M{field(key): value}, nil
+	}
+
+	fields := strings.Fields(requirement)
+	if len(fields) >= 2 && (fields[1] == "in" || strings.HasPrefix(fields[1], "in(") ||
+		fields[1] == "notin" || strings.HasPrefix(fields[1], "notin(")) {
+		key := fields[0]
+		rest := strings.TrimSpace(strings.TrimPrefix(requirement, key))
+		operator := "$in"
+		if strings.HasPrefix(rest, "notin") {
+			operator = "$nin"
+			rest = strings.TrimPrefix(rest, "notin")
+		} else {
+			rest = strings.TrimPrefix(rest, "in")
+		}
+		if err := validateKey(key); err != nil {
+			return nil, err
+		}
+		values, err := parseSet(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{field(key): bson.M{operator: values}}, nil
+	}
+
+	key := strings.TrimSpace(requirement)
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	return bson.M{field(key): bson.M{"$exists": true}}, nil
+}
+
+// parseSet parses the parenthesized values of an in or notin requirement
+func parseSet(set string) ([]string, error) {
+	inner, opened := strings.CutPrefix(set, "(")
+	inner, closed := strings.CutSuffix(inner, ")")
+	if !opened || !closed {
+		return nil, fmt.Errorf("expected a parenthesized list of values, got %q", set)
+	}
+	values := []string{}
+	for _, value := range strings.Split(inner, ",") {
+		value = strings.TrimSpace(value)
+		if !labelValuePattern.MatchString(value) {
+			return nil, fmt.Errorf("invalid label value %q in selector", value)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func validateKey(key string) error {
+	if !labelKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid label key %q in selector", key)
+	}
+	return nil
+}
+
+func field(key string) string {
+	return "labels." + key
+}
@@ -0,0 +1,118 @@
+package selector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     bson.M
+	}{
+		{"", bson.M{}},
+		{"  ", bson.M{}},
+		{"team=payments", bson.M{"labels.team": "payments"}},
+		{"team==payments", bson.M{"labels.team": "payments"}},
+		{" team = payments ", bson.M{"labels.team": "payments"}},
+		{"tier!=frontend", bson.M{"labels.tier": bson.M{"$ne": "frontend"}}},
+		{"team=", bson.M{"labels.team": ""}},
+		{"team==", bson.M{"labels.team": ""}},
+		{"example-org/team=payments", bson.M{"labels.example-org/team": "payments"}},
+		{"env in (prod,staging)", bson.M{"labels.env": bson.M{"$in": []string{"prod", "staging"}}}},
+		{"env in(prod)", bson.M{"labels.env": bson.M{"$in": []string{"prod"}}}},
+		{"env notin ( dev , test )", bson.M{"labels.env": bson.M{"$nin": []string{"dev", "test"}}}},
+		{"env notin(dev)", bson.M{"labels.env": bson.M{"$nin": []string{"dev"}}}},
+		{"canary", bson.M{"labels.canary": bson.M{"$exists": true}}},
+		{"!canary", bson.M{"labels.canary": bson.M{"$exists": false}}},
+		{"! canary", bson.M{"labels.canary": bson.M{"$exists": false}}},
+		{"team=payments,env in (prod,staging),!canary", bson.M{"$and": []bson.M{
+			{"labels.team": "payments"},
+			{"labels.env": bson.M{"$in": []string{"prod", "staging"}}},
+			{"labels.canary": bson.M{"$exists": false}},
+		}}},
+		{"team=payments,", bson.M{"labels.team": "payments"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			got, err := Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.selector, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Parse(%q) = %v, want %v", tt.selector, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"env in (prod",
+		"env in prod)",
+		"env in prod",
+		"env in ()x",
+		"env in ((prod))",
+		"env notin prod,staging)",
+		"env in (prod,sta ging)",
+		"(team=payments",
+		"team=payments)",
+		"team=pay ments",
+		"team=-payments",
+		"=payments",
+		"team.name=payments",
+		"!",
+		"-team",
+		"example.com/team=payments",
+	}
+	for _, selector := range tests {
+		t.Run(selector, func(t *testing.T) {
+			if got, err := Parse(selector); err == nil {
+				t.Fatalf("Parse(%q) = %v, want an error", selector, got)
+			}
+		})
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"simple", map[string]string{"team": "payments", "tier": "1"}, false},
+		{"prefixed key", map[string]string{"example-org/team": "payments"}, false},
+		{"empty value", map[string]string{"canary": ""}, false},
+		{"value with dots", map[string]string{"version": "1.2.3"}, false},
+		{"dotted key", map[string]string{"app.kubernetes.io/name": "api"}, true},
+		{"empty key", map[string]string{"": "x"}, true},
+		{"long name", map[string]string{strings.Repeat("a", 64): "x"}, true},
+		{"long value", map[string]string{"team": strings.Repeat("a", 64)}, true},
+		{"value ending in dash", map[string]string{"team": "payments-"}, true},
+		{"value with space", map[string]string{"team": "pay ments"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLabels(tt.labels)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateLabels(%v) error = %v, wantErr %v", tt.labels, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAnnotations(t *testing.T) {
+	if err := ValidateAnnotations(map[string]string{"description": "free-form text, with spaces."}); err != nil {
+		t.Fatalf("free-form value: %v", err)
+	}
+	if err := ValidateAnnotations(map[string]string{"bad key": "x"}); err == nil {
+		t.Fatal("expected an error for an invalid key")
+	}
+	if err := ValidateAnnotations(map[string]string{"big": strings.Repeat("x", maxAnnotationsSize)}); err == nil {
+		t.Fatal("expected an error for annotations over the size limit")
+	}
+}
@@ -0,0 +1,142 @@
+package settings
+
+import (
+	"core-service/models"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Validate checks settings values for keys MongoDB can't store and for
+// well-known settings of the wrong type
+func Validate(values map[string]interface{}) error {
+	if err := validateKeys(values, ""); err != nil {
+		return err
+	}
+
+	if replicas, ok := values["default_replicas"]; ok && replicas != nil {
+		number, isNumber := replicas.(float64)
+		if !isNumber || number < 0 || number != math.Trunc(number) {
+			return errors.New("default_replicas must be a non-negative integer")
+		}
+	}
+	for _, key := range []string{"allowed_deployers", "notification_targets"} {
+		if list, ok := values[key]; ok && list != nil {
+			if _, isList := list.([]interface{}); !isList {
+				return fmt.Errorf("%s must be a list", key)
+			}
+		}
+	}
+	if labels, ok := values["labels"]; ok && labels != nil {
+		object, isObject := labels.(map[string]interface{})
+		if !isObject {
+			return errors.New("labels must be an object")
+		}
+		for key, value := range object {
+			if _, isString := value.(string); !isString && value != nil {
+				return fmt.Errorf("labels.%s must be a string", key)
+			}
+		}
+	}
+	return nil
+}
+
+func validateKeys(values map[string]interface{}, prefix string) error {
+	for key, value := range values {
+		if key == "" || strings.HasPrefix(key, "$") || strings.Contains(key, ".") {
+			return fmt.Errorf("invalid key %q: keys must not be empty, start with $ or contain dots", prefix+key)
+		}
+		if object, ok := value.(map[string]interface{}); ok {
+			if err := validateKeys(object, prefix+key+"."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Normalize turns the documents and arrays of settings read from MongoDB
+// into the maps and slices they were stored from
+func Normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		object := make(map[string]interface{}, len(v))
+		for _, element := range v {
+			object[element.Key] = Normalize(element.Value)
+		}
+		return object
+	case bson.M:
+		object := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			object[key] = Normalize(element)
+		}
+		return object
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			object[key] = Normalize(element)
+		}
+		return object
+	case bson.A:
+		list := make([]interface{}, len(v))
+		for i, element := range v {
+			list[i] = Normalize(element)
+		}
+		return list
+	default:
+		return value
+	}
+}
+
+// Merge deep-merges settings documents, most general first, and returns the
+// result along with the source of every value in it, keyed by its dotted
+// path. Objects are merged key by key; any other value replaces what was
+// inherited, and null removes it.
+func Merge(documents []models.Settings) (map[string]interface{}, map[string]models.SettingSource) {
+	merged := map[string]interface{}{}
+	sources := map[string]models.SettingSource{}
+	for _, document := range documents {
+		source := models.SettingSource{Level: document.Level, TargetID: document.TargetID}
+		values, _ := Normalize(document.Values).(map[string]interface{})
+		mergeInto(merged, values, "", source, sources)
+	}
+	return merged, sources
+}
+
+func mergeInto(dst, src map[string]interface{}, prefix string, source models.SettingSource, sources map[string]models.SettingSource) {
+	for key, value := range src {
+		path := prefix + key
+		if value == nil {
+			delete(dst, key)
+			clearSources(sources, path)
+			continue
+		}
+
+		object, isObject := value.(map[string]interface{})
+		existing, hasObject := dst[key].(map[string]interface{})
+		if isObject {
+			if !hasObject {
+				existing = map[string]interface{}{}
+				clearSources(sources, path)
+				dst[key] = existing
+			}
+			mergeInto(existing, object, path+".", source, sources)
+			continue
+		}
+
+		dst[key] = value
+		clearSources(sources, path)
+		sources[path] = source
+	}
+}
+
+// clearSources forgets the sources of path and everything below it
+func clearSources(sources map[string]models.SettingSource, path string) {
+	maps.DeleteFunc(sources, func(key string, _ models.SettingSource) bool {
+		return key == path || strings.HasPrefix(key, path+".")
+	})
+}
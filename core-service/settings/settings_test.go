@@ -0,0 +1,196 @@
+package settings
+
+import (
+	"core-service/models"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestMerge(t *testing.T) {
+	tenant := models.SettingSource{Level: models.SettingsLevelTenant, TargetID: bson.NewObjectID()}
+	project := models.SettingSource{Level: models.SettingsLevelProject, TargetID: bson.NewObjectID()}
+	service := models.SettingSource{Level: models.SettingsLevelService, TargetID: bson.NewObjectID()}
+	document := func(source models.SettingSource, values map[string]interface{}) models.Settings {
+		return models.Settings{Level: source.Level, TargetID: source.TargetID, Values: values}
+	}
+
+	tests := []struct {
+		name        string
+		documents   []models.Settings
+		want        map[string]interface{}
+		wantSources map[string]models.SettingSource
+	}{
+		{
+			name:        "nothing set",
+			want:        map[string]interface{}{},
+			wantSources: map[string]models.SettingSource{},
+		},
+		{
+			name: "scalars replace",
+			documents: []models.Settings{
+				document(tenant, map[string]interface{}{"default_replicas": 2.0, "region": "eu"}),
+				document(service, map[string]interface{}{"default_replicas": 5.0}),
+			},
+			want:        map[string]interface{}{"default_replicas": 5.0, "region": "eu"},
+			wantSources: map[string]models.SettingSource{"default_replicas": service, "region": tenant},
+		},
+		{
+			name: "lists replace rather than append",
+			documents: []models.Settings{
+				document(tenant, map[string]interface{}{"allowed_deployers": []interface{}{"alice", "bob"}}),
+				document(project, map[string]interface{}{"allowed_deployers": []interface{}{"carol"}}),
+			},
+			want:        map[string]interface{}{"allowed_deployers": []interface{}{"carol"}},
+			wantSources: map[string]models.SettingSource{"allowed_deployers": project},
+		},
+		{
+			name: "objects merge key by key",
+			documents: []models.Settings{
+				document(tenant, map[string]interface{}{"labels": map[string]interface{}{"team": "payments", "tier": "critical"}}),
+				document(project, map[string]interface{}{"labels": map[string]interface{}{"tier": "batch"}}),
+				document(service, map[string]interface{}{"labels": map[string]interface{}{"owner": "dave"}}),
+			},
+			want: map[string]interface{}{"labels": map[string]interface{}{"team": "payments", "tier": "batch", "owner": "dave"}},
+			wantSources: map[string]models.SettingSource{
+				"labels.team":  tenant,
+				"labels.tier":  project,
+				"labels.owner": service,
+			},
+		},
+		{
+			name: "null removes a value",
+			documents: []models.Settings{
+				document(tenant, map[string]interface{}{"notification_targets": []interface{}{"#ops"}, "region": "eu"}),
+				document(service, map[string]interface{}{"notification_targets": nil}),
+			},
+			want:        map[string]interface{}{"region": "eu"},
+			wantSources: map[string]models.SettingSource{"region": tenant},
+		},
+		{
+			name: "null removes one key of an object",
+			documents: []models.Settings{
+				document(tenant, map[string]interface{}{"labels": map[string]interface{}{"team": "payments", "tier": "critical"}}),
+				document(project, map[string]interface{}{"labels": map[string]interface{}{"tier": nil}}),
+			},
+			want:        map[string]interface{}{"labels": map[string]interface{}{"team": "payments"}},
+			wantSources: map[string]models.SettingSource{"labels.team": tenant},
+		},
+		{
+			name: "null removes a whole object",
+			documents: []models.Settings{
+				document(tenant, map[string]interface{}{"labels": map[string]interface{}{"team": "payments"}}),
+				document(service, map[string]interface{}{"labels": nil}),
+			},
+			want:        map[string]interface{}{},
+			wantSources: map[string]models.SettingSource{},
+		},
+		{
+			name: "a removed value can be set again lower down",
+			documents: []models.Settings{
+				document(tenant, map[string]interface{}{"region": "eu"}),
+				document(project, map[string]interface{}{"region": nil}),
+				document(service, map[string]interface{}{"region": "us"}),
+			},
+			want:        map[string]interface{}{"region": "us"},
+			wantSources: map[string]models.SettingSource{"region": service},
+		},
+		{
+			name: "a scalar replaces an object",
+			documents: []models.Settings{
+				document(tenant, map[string]interface{}{"labels": map[string]interface{}{"team": "payments"}}),
+				document(service, map[string]interface{}{"labels": "none"}),
+			},
+			want:        map[string]interface{}{"labels": "none"},
+			wantSources: map[string]models.SettingSource{"labels": service},
+		},
+		{
+			name: "an object replaces a scalar",
+			documents: []models.Settings{
+				document(tenant, map[string]interface{}{"labels": "none"}),
+				document(service, map[string]interface{}{"labels": map[string]interface{}{"team": "payments"}}),
+			},
+			want:        map[string]interface{}{"labels": map[string]interface{}{"team": "payments"}},
+			wantSources: map[string]models.SettingSource{"labels.team": service},
+		},
+		{
+			name: "documents as read from MongoDB",
+			documents: []models.Settings{
+				document(tenant, map[string]interface{}{
+					"labels":            bson.D{{Key: "team", Value: "payments"}},
+					"allowed_deployers": bson.A{"alice"},
+				}),
+				document(project, map[string]interface{}{"labels": bson.M{"tier": "critical"}}),
+			},
+			want: map[string]interface{}{
+				"labels":            map[string]interface{}{"team": "payments", "tier": "critical"},
+				"allowed_deployers": []interface{}{"alice"},
+			},
+			wantSources: map[string]models.SettingSource{
+				"labels.team":       tenant,
+				"labels.tier":       project,
+				"allowed_deployers": tenant,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, sources := Merge(tt.documents)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("merged = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(sources, tt.wantSources) {
+				t.Fatalf("sources = %v, want %v", sources, tt.wantSources)
+			}
+		})
+	}
+}
+
+func TestMergeLeavesDocumentsAlone(t *testing.T) {
+	labels := map[string]interface{}{"team": "payments"}
+	Merge([]models.Settings{
+		{Level: models.SettingsLevelTenant, Values: map[string]interface{}{"labels": labels}},
+		{Level: models.SettingsLevelService, Values: map[string]interface{}{"labels": map[string]interface{}{"tier": "critical"}}},
+	})
+	if len(labels) != 1 {
+		t.Fatalf("tenant labels = %v, want them unchanged by the merge", labels)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]interface{}
+		wantErr bool
+	}{
+		{name: "well-known settings", values: map[string]interface{}{
+			"default_replicas":     3.0,
+			"allowed_deployers":    []interface{}{"alice"},
+			"notification_targets": []interface{}{},
+			"labels":               map[string]interface{}{"team": "payments", "tier": nil},
+		}},
+		{name: "nulls", values: map[string]interface{}{"default_replicas": nil, "labels": nil}},
+		{name: "other settings", values: map[string]interface{}{"custom": map[string]interface{}{"nested": true}}},
+		{name: "fractional replicas", values: map[string]interface{}{"default_replicas": 1.5}, wantErr: true},
+		{name: "negative replicas", values: map[string]interface{}{"default_replicas": -1.0}, wantErr: true},
+		{name: "replicas as a string", values: map[string]interface{}{"default_replicas": "3"}, wantErr: true},
+		{name: "deployers not a list", values: map[string]interface{}{"allowed_deployers": "alice"}, wantErr: true},
+		{name: "labels not an object", values: map[string]interface{}{"labels": []interface{}{"team"}}, wantErr: true},
+		{name: "label not a string", values: map[string]interface{}{"labels": map[string]interface{}{"tier": 1.0}}, wantErr: true},
+		{name: "operator key", values: map[string]interface{}{"$set": 1.0}, wantErr: true},
+		{name: "dotted key", values: map[string]interface{}{"a.b": 1.0}, wantErr: true},
+		{name: "nested empty key", values: map[string]interface{}{"custom": map[string]interface{}{"": 1.0}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.values)
+			if tt.wantErr && err == nil {
+				t.Fatal("Validate succeeded, want an error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"core-service/config"
+	"core-service/events"
+	"core-service/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	// fanOutBatch is how many feed events are matched against subscriptions per pass
+	fanOutBatch = 100
+	// maxConcurrentDeliveries bounds parallel requests from one replica
+	maxConcurrentDeliveries = 10
+	// maxResponseLog is how much of a failed response body is kept in the log
+	maxResponseLog = 512
+)
+
+var httpClient = &http.Client{}
+
+// Start creates the webhook indexes and starts the dispatcher, which turns
+// feed events into deliveries and sends the deliveries that are due
+func Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := ensureIndexes(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.App.Webhooks.PollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := fanOut(context.Background()); err != nil {
+				log.Printf("Webhook fan-out failed: %v", err)
+			}
+			if err := deliverDue(context.Background()); err != nil {
+				log.Printf("Webhook delivery failed: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// fanOut creates a delivery for every subscription matching events published
+// since the last pass. The cursor is shared by all replicas and deliveries
+// are unique per subscription and event, so overlapping passes are harmless.
+func fanOut(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	state := config.GetCollection(stateCollection)
+	var cursor struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := state.FindOne(ctx, bson.M{"_id": "fan_out"}).Decode(&cursor)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	feed, err := events.Feed(ctx, bson.M{"tenant_id": bson.M{"$exists": true}}, cursor.Seq, fanOutBatch)
+	if err != nil || len(feed) == 0 {
+		return err
+	}
+
+	subscriptions := config.GetCollection(SubscriptionCollection)
+	deliveries := config.GetCollection(DeliveryCollection)
+	for _, event := range feed {
+		tenantID, err := bson.ObjectIDFromHex(event.TenantID)
+		if err != nil {
+			continue
+		}
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		findCursor, err := subscriptions.Find(ctx, bson.M{"tenant_id": tenantID, "active": true})
+		if err != nil {
+			return err
+		}
+		var matching []models.WebhookSubscription
+		if err := findCursor.All(ctx, &matching); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, subscription := range matching {
+			if !Matches(subscription, event.Type) {
+				continue
+			}
+			delivery := models.WebhookDelivery{
+				SubscriptionID: subscription.ID,
+				TenantID:       tenantID,
+				EventID:        event.ID,
+				EventType:      event.Type,
+				Body:           body,
+				Status:         models.DeliveryPending,
+				NextAttemptAt:  now,
+				Log:            []models.DeliveryAttempt{},
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			}
+			if _, err := deliveries.InsertOne(ctx, delivery); err != nil && !mongo.IsDuplicateKeyError(err) {
+				return err
+			}
+		}
+	}
+
+	last := feed[len(feed)-1].Seq
+	_, err = state.UpdateOne(ctx, bson.M{"_id": "fan_out"}, bson.M{"$max": bson.M{"seq": last}}, options.UpdateOne().SetUpsert(true))
+	return err
+}
+
+// deliverDue claims due deliveries and sends them in parallel. A claim locks
+// the delivery for longer than a request can take, so a replica that dies
+// mid-delivery only delays it.
+func deliverDue(ctx context.Context) error {
+	collection := config.GetCollection(DeliveryCollection)
+	lease := config.App.Webhooks.Timeout + 30*time.Second
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxConcurrentDeliveries)
+	defer wg.Wait()
+
+	for {
+		now := time.Now()
+		var delivery models.WebhookDelivery
+		err := collection.FindOneAndUpdate(ctx,
+			bson.M{
+				"status":          models.DeliveryPending,
+				"next_attempt_at": bson.M{"$lte": now},
+				"locked_until":    bson.M{"$lte": now},
+			},
+			bson.M{"$set": bson.M{"locked_until": now.Add(lease)}},
+			options.FindOneAndUpdate().
+				SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+				SetReturnDocument(options.After),
+		).Decode(&delivery)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := attempt(delivery); err != nil {
+				log.Printf("Failed to record webhook delivery %s: %v", delivery.ID.Hex(), err)
+			}
+		}()
+	}
+}
+
+// attempt sends a delivery once and records the outcome, scheduling a retry
+// or moving it to the dead-letter list
+func attempt(delivery models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.App.Webhooks.Timeout+10*time.Second)
+	defer cancel()
+
+	var subscription models.WebhookSubscription
+	err := config.GetCollection(SubscriptionCollection).FindOne(ctx, bson.M{"_id": delivery.SubscriptionID}).Decode(&subscription)
+
+	started := time.Now()
+	record := models.DeliveryAttempt{At: started}
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		record.Error = "subscription no longer exists"
+	case err != nil:
+		return err
+	case !subscription.Active:
+		record.Error = "subscription is inactive"
+	case Allowed(subscription.URL) != nil:
+		record.Error = ErrURLNotAllowed.Error()
+	default:
+		record.StatusCode, err = send(subscription, delivery)
+		if err != nil {
+			record.Error = err.Error()
+		}
+	}
+	record.DurationMS = time.Since(started).Milliseconds()
+
+	attempts := delivery.Attempts + 1
+	update := bson.M{
+		"attempts":     attempts,
+		"locked_until": time.Time{},
+		"updated_at":   time.Now(),
+	}
+	switch {
+	case record.Error == "":
+		update["status"] = models.DeliverySucceeded
+	case attempts >= config.App.Webhooks.MaxAttempts || subscription.ID.IsZero() || !subscription.Active || Allowed(subscription.URL) != nil:
+		update["status"] = models.DeliveryDead
+	default:
+		update["next_attempt_at"] = time.Now().Add(Backoff(attempts))
+	}
+
+	_, err = config.GetCollection(DeliveryCollection).UpdateOne(ctx,
+		bson.M{"_id": delivery.ID},
+		bson.M{"$set": update, "$push": bson.M{"log": record}},
+	)
+	return err
+}
+
+// send POSTs the delivery body to the subscription URL and returns the
+// response status. Any non-2xx status is an error.
+func send(subscription models.WebhookSubscription, delivery models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.App.Webhooks.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "core-service-webhooks")
+	req.Header.Set("X-Webhook-ID", delivery.ID.Hex())
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, time.Now(), delivery.Body))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseLog))
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return resp.StatusCode, nil
+}
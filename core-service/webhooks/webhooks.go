@@ -0,0 +1,129 @@
+package webhooks
+
+import (
+	"context"
+	"core-service/config"
+	"core-service/models"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	SubscriptionCollection = "webhook_subscriptions"
+	DeliveryCollection     = "webhook_deliveries"
+	stateCollection        = "webhook_state"
+)
+
+// SignatureHeader carries the delivery signature in the form
+// "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">"
+const SignatureHeader = "X-Webhook-Signature"
+
+// GenerateSecret returns a random signing secret
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %v", err)
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// Sign computes the signature header value for a body sent at timestamp.
+// Receivers recompute it with their copy of the secret and should reject
+// timestamps too far in the past to prevent replays.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := timestamp.Unix()
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", t)
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", t, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// ErrURLNotAllowed is returned for webhook URLs outside WEBHOOK_ALLOWED_URLS
+var ErrURLNotAllowed = errors.New("url is not under one of the allowed webhook URLs")
+
+// Allowed checks that a webhook URL is under one of the allowed URLs: same
+// scheme and host, and a path at or below the allowed one
+func Allowed(rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	for _, prefix := range config.App.Webhooks.AllowedURLs {
+		allowed, err := url.Parse(prefix)
+		if err != nil {
+			continue
+		}
+		if target.Scheme != allowed.Scheme || !strings.EqualFold(target.Host, allowed.Host) || target.User != nil {
+			continue
+		}
+		base := strings.TrimSuffix(allowed.EscapedPath(), "/")
+		path := target.EscapedPath()
+		if base == "" || path == base || strings.HasPrefix(path, base+"/") {
+			return nil
+		}
+	}
+	return ErrURLNotAllowed
+}
+
+// Matches reports whether a subscription wants an event type
+func Matches(subscription models.WebhookSubscription, eventType string) bool {
+	return len(subscription.Events) == 0 || slices.Contains(subscription.Events, eventType)
+}
+
+// Backoff returns the delay before retrying a delivery that failed attempts
+// times: InitialBackoff doubled per attempt, capped at MaxBackoff
+func Backoff(attempts int) time.Duration {
+	delay := config.App.Webhooks.InitialBackoff
+	for i := 1; i < attempts && delay < config.App.Webhooks.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, config.App.Webhooks.MaxBackoff)
+}
+
+// Redeliver moves a delivery back to pending so it is attempted again
+// immediately, keeping its log. It returns mongo.ErrNoDocuments if the
+// delivery doesn't belong to the subscription.
+func Redeliver(ctx context.Context, subscriptionID, deliveryID bson.ObjectID) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	now := time.Now()
+	err := config.GetCollection(DeliveryCollection).FindOneAndUpdate(ctx,
+		bson.M{"_id": deliveryID, "subscription_id": subscriptionID},
+		bson.M{"$set": bson.M{
+			"status":          models.DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+			"locked_until":    time.Time{},
+			"updated_at":      now,
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&delivery)
+	return delivery, err
+}
+
+func ensureIndexes(ctx context.Context) error {
+	_, err := config.GetCollection(SubscriptionCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "active", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = config.GetCollection(DeliveryCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// Fan-out runs on every replica; this keeps one delivery per event
+		{Keys: bson.D{{Key: "subscription_id", Value: 1}, {Key: "event_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
@@ -0,0 +1,42 @@
+package webhooks
+
+import (
+	"core-service/config"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	previous := config.App.Webhooks.AllowedURLs
+	config.App.Webhooks.AllowedURLs = []string{"https://hooks.example.com/acme/", "http://receiver.internal:8080"}
+	t.Cleanup(func() { config.App.Webhooks.AllowedURLs = previous })
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://hooks.example.com/acme", true},
+		{"https://hooks.example.com/acme/deploys", true},
+		{"https://HOOKS.example.com/acme/deploys", true},
+		{"http://receiver.internal:8080/anything", true},
+		{"http://receiver.internal:8080", true},
+		{"https://hooks.example.com/acmecorp", false},
+		{"https://hooks.example.com/other", false},
+		{"http://hooks.example.com/acme", false},
+		{"https://hooks.example.com.evil.test/acme", false},
+		{"https://user@hooks.example.com/acme", false},
+		{"http://receiver.internal/anything", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := Allowed(tt.url) == nil; got != tt.want {
+				t.Fatalf("Allowed(%q) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+
+	config.App.Webhooks.AllowedURLs = nil
+	if Allowed("https://hooks.example.com/acme") == nil {
+		t.Fatal("expected no URL to be allowed without an allowlist")
+	}
+}
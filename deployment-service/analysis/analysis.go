@@ -0,0 +1,97 @@
+package analysis
+
+import (
+	"context"
+	"deployment-service/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Defaults for analysis settings a canary leaves out
+const (
+	DefaultInterval        = time.Minute
+	DefaultMaxInconclusive = 3
+)
+
+// Run queries every metric of an analysis for the canary and, where needed,
+// the baseline, and judges the canary. A failed query makes the analysis
+// inconclusive unless another metric already failed it.
+func Run(ctx context.Context, deployment models.Deployment, spec models.AnalysisSpec, weight int) models.AnalysisRun {
+	run := models.AnalysisRun{
+		Weight:       weight,
+		Outcome:      models.AnalysisPassed,
+		Measurements: []models.Measurement{},
+		At:           time.Now(),
+	}
+
+	var errs []string
+	for _, metric := range spec.Metrics {
+		measurement, err := measure(ctx, deployment, metric)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", metric.Name, err))
+			if run.Outcome == models.AnalysisPassed {
+				run.Outcome = models.AnalysisInconclusive
+			}
+			continue
+		}
+		run.Measurements = append(run.Measurements, measurement)
+		if !measurement.Passed {
+			run.Outcome = models.AnalysisFailed
+		}
+	}
+	run.Error = strings.Join(errs, "; ")
+	return run
+}
+
+func measure(ctx context.Context, deployment models.Deployment, metric models.AnalysisMetric) (models.Measurement, error) {
+	measurement := models.Measurement{Metric: metric.Name, Passed: true}
+
+	canary, err := Default.Query(ctx, render(metric.Query, deployment, "canary"))
+	if err != nil {
+		return measurement, err
+	}
+	measurement.Canary = canary
+	if metric.Max != nil && canary > *metric.Max {
+		measurement.Passed = false
+	}
+
+	if metric.MaxIncrease != nil {
+		baseline, err := Default.Query(ctx, render(metric.Query, deployment, "baseline"))
+		if err != nil {
+			return measurement, err
+		}
+		measurement.Baseline = &baseline
+		if canary > baseline*(1+*metric.MaxIncrease) {
+			measurement.Passed = false
+		}
+	}
+	return measurement, nil
+}
+
+func render(query string, deployment models.Deployment, track string) string {
+	return strings.NewReplacer(
+		"{{track}}", track,
+		"{{deployment_id}}", deployment.ID.Hex(),
+		"{{service_id}}", deployment.ServiceID.Hex(),
+	).Replace(query)
+}
+
+// Describe summarizes the failed or missing measurements of a run
+func Describe(run models.AnalysisRun) string {
+	var parts []string
+	for _, m := range run.Measurements {
+		if m.Passed {
+			continue
+		}
+		if m.Baseline != nil {
+			parts = append(parts, fmt.Sprintf("%s canary %g vs baseline %g", m.Metric, m.Canary, *m.Baseline))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s canary %g", m.Metric, m.Canary))
+		}
+	}
+	if run.Error != "" {
+		parts = append(parts, run.Error)
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,176 @@
+package analysis
+
+import (
+	"context"
+	"deployment-service/models"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// fakeProvider answers queries from fixed values. Queries it doesn't know
+// return ErrNoData.
+type fakeProvider struct {
+	values  map[string]float64
+	errs    map[string]error
+	queries []string
+}
+
+func (f *fakeProvider) Query(ctx context.Context, query string) (float64, error) {
+	f.queries = append(f.queries, query)
+	if err, ok := f.errs[query]; ok {
+		return 0, err
+	}
+	value, ok := f.values[query]
+	if !ok {
+		return 0, ErrNoData
+	}
+	return value, nil
+}
+
+func useProvider(t *testing.T, p MetricsProvider) {
+	t.Helper()
+	previous := Default
+	Default = p
+	t.Cleanup(func() { Default = previous })
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+func TestMeasure(t *testing.T) {
+	tests := []struct {
+		name         string
+		metric       models.AnalysisMetric
+		canary       float64
+		baseline     float64
+		wantPassed   bool
+		wantBaseline bool
+	}{
+		{"under max", models.AnalysisMetric{Max: ptr(0.05)}, 0.01, 0, true, false},
+		{"at max", models.AnalysisMetric{Max: ptr(0.05)}, 0.05, 0, true, false},
+		{"over max", models.AnalysisMetric{Max: ptr(0.05)}, 0.06, 0, false, false},
+		{"no thresholds", models.AnalysisMetric{}, 1000, 0, true, false},
+		{"within increase", models.AnalysisMetric{MaxIncrease: ptr(0.5)}, 140, 100, true, true},
+		{"at increase", models.AnalysisMetric{MaxIncrease: ptr(0.5)}, 150, 100, true, true},
+		{"over increase", models.AnalysisMetric{MaxIncrease: ptr(0.5)}, 151, 100, false, true},
+		{"below baseline", models.AnalysisMetric{MaxIncrease: ptr(0)}, 90, 100, true, true},
+		{"under max but over increase", models.AnalysisMetric{Max: ptr(200), MaxIncrease: ptr(0.1)}, 150, 100, false, true},
+		{"over max but within increase", models.AnalysisMetric{Max: ptr(100), MaxIncrease: ptr(0.5)}, 120, 100, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, &fakeProvider{values: map[string]float64{
+				"latency{track=canary}":   tt.canary,
+				"latency{track=baseline}": tt.baseline,
+			}})
+			tt.metric.Name = "latency"
+			tt.metric.Query = "latency{track={{track}}}"
+
+			m, err := measure(context.Background(), models.Deployment{}, tt.metric)
+			if err != nil {
+				t.Fatalf("measure: %v", err)
+			}
+			if m.Metric != "latency" || m.Canary != tt.canary {
+				t.Fatalf("measurement = %+v, want metric latency with canary %g", m, tt.canary)
+			}
+			if m.Passed != tt.wantPassed {
+				t.Fatalf("passed = %v, want %v", m.Passed, tt.wantPassed)
+			}
+			if (m.Baseline != nil) != tt.wantBaseline {
+				t.Fatalf("baseline = %v, want one: %v", m.Baseline, tt.wantBaseline)
+			}
+			if tt.wantBaseline && *m.Baseline != tt.baseline {
+				t.Fatalf("baseline = %g, want %g", *m.Baseline, tt.baseline)
+			}
+		})
+	}
+}
+
+func TestMeasureRendersQuery(t *testing.T) {
+	deployment := models.Deployment{ID: bson.NewObjectID(), ServiceID: bson.NewObjectID()}
+	fake := &fakeProvider{values: map[string]float64{}}
+	useProvider(t, fake)
+
+	metric := models.AnalysisMetric{
+		Name:        "errors",
+		Query:       "errors{track={{track}},deployment={{deployment_id}},service={{service_id}}}",
+		MaxIncrease: ptr(0.1),
+	}
+	fake.values["errors{track=canary,deployment="+deployment.ID.Hex()+",service="+deployment.ServiceID.Hex()+"}"] = 1
+	fake.values["errors{track=baseline,deployment="+deployment.ID.Hex()+",service="+deployment.ServiceID.Hex()+"}"] = 1
+
+	if _, err := measure(context.Background(), deployment, metric); err != nil {
+		t.Fatalf("measure: %v (queries %q)", err, fake.queries)
+	}
+	if len(fake.queries) != 2 {
+		t.Fatalf("queries = %q, want the canary and the baseline", fake.queries)
+	}
+}
+
+func TestRun(t *testing.T) {
+	failing := errors.New("connection refused")
+	useProvider(t, &fakeProvider{
+		values: map[string]float64{
+			"good": 0.01,
+			"bad":  0.5,
+		},
+		errs: map[string]error{"broken": failing},
+	})
+	good := models.AnalysisMetric{Name: "good", Query: "good", Max: ptr(0.05)}
+	bad := models.AnalysisMetric{Name: "bad", Query: "bad", Max: ptr(0.05)}
+	broken := models.AnalysisMetric{Name: "broken", Query: "broken", Max: ptr(0.05)}
+	missing := models.AnalysisMetric{Name: "missing", Query: "missing", Max: ptr(0.05)}
+
+	tests := []struct {
+		name             string
+		metrics          []models.AnalysisMetric
+		wantOutcome      string
+		wantMeasurements int
+		wantError        string
+	}{
+		{"all pass", []models.AnalysisMetric{good}, models.AnalysisPassed, 1, ""},
+		{"one fails", []models.AnalysisMetric{good, bad}, models.AnalysisFailed, 2, ""},
+		{"query error", []models.AnalysisMetric{good, broken}, models.AnalysisInconclusive, 1, "broken: connection refused"},
+		{"no data", []models.AnalysisMetric{missing}, models.AnalysisInconclusive, 0, "missing: " + ErrNoData.Error()},
+		{"failure wins over error before it", []models.AnalysisMetric{broken, bad}, models.AnalysisFailed, 1, "broken: connection refused"},
+		{"failure wins over error after it", []models.AnalysisMetric{bad, broken}, models.AnalysisFailed, 1, "broken: connection refused"},
+		{"errors are joined", []models.AnalysisMetric{broken, missing}, models.AnalysisInconclusive, 0, "broken: connection refused; missing: " + ErrNoData.Error()},
+		{"no metrics", nil, models.AnalysisPassed, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := Run(context.Background(), models.Deployment{}, models.AnalysisSpec{Metrics: tt.metrics}, 25)
+			if run.Outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %q, want %q", run.Outcome, tt.wantOutcome)
+			}
+			if len(run.Measurements) != tt.wantMeasurements {
+				t.Fatalf("measurements = %+v, want %d", run.Measurements, tt.wantMeasurements)
+			}
+			if run.Error != tt.wantError {
+				t.Fatalf("error = %q, want %q", run.Error, tt.wantError)
+			}
+			if run.Weight != 25 {
+				t.Fatalf("weight = %d, want 25", run.Weight)
+			}
+		})
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	run := models.AnalysisRun{
+		Measurements: []models.Measurement{
+			{Metric: "ok", Canary: 1, Passed: true},
+			{Metric: "errors", Canary: 0.5},
+			{Metric: "latency", Canary: 300, Baseline: ptr(200)},
+		},
+		Error: "broken: connection refused",
+	}
+	got := Describe(run)
+	want := "errors canary 0.5; latency canary 300 vs baseline 200; broken: connection refused"
+	if got != want {
+		t.Fatalf("Describe = %q, want %q", got, want)
+	}
+}
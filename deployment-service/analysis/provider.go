@@ -0,0 +1,111 @@
+package analysis
+
+import (
+	"context"
+	"deployment-service/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricsProvider runs a metric query and returns its current value
+type MetricsProvider interface {
+	// Query returns the single value of a query, or ErrNoData if it has
+	// none.
+	Query(ctx context.Context, query string) (float64, error)
+}
+
+var ErrNoData = errors.New("query returned no data")
+
+// Default is the metrics provider used for canary analysis
+var Default MetricsProvider = &PrometheusProvider{}
+
+// PrometheusProvider queries the Prometheus HTTP API at PROMETHEUS_URL, or
+// anything compatible with its instant query endpoint
+type PrometheusProvider struct{}
+
+// prometheusResponse is the part of a /api/v1/query response that is used.
+// Vector results carry their value per series, scalars directly.
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+func (p *PrometheusProvider) Query(ctx context.Context, query string) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.App.Analysis.QueryTimeout)
+	defer cancel()
+
+	endpoint := strings.TrimSuffix(config.App.Analysis.PrometheusURL, "/") + "/api/v1/query?" + url.Values{
+		"query": {query},
+		"time":  {strconv.FormatInt(time.Now().Unix(), 10)},
+	}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query prometheus: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response prometheusResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&response); err != nil {
+		return 0, fmt.Errorf("prometheus returned status %d and an unreadable body: %v", resp.StatusCode, err)
+	}
+	if response.Status != "success" {
+		return 0, fmt.Errorf("prometheus query failed: %s", response.Error)
+	}
+
+	var sample []interface{}
+	switch response.Data.ResultType {
+	case "scalar":
+		if err := json.Unmarshal(response.Data.Result, &sample); err != nil {
+			return 0, fmt.Errorf("failed to decode scalar result: %v", err)
+		}
+	case "vector":
+		var series []struct {
+			Value []interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(response.Data.Result, &series); err != nil {
+			return 0, fmt.Errorf("failed to decode vector result: %v", err)
+		}
+		if len(series) == 0 {
+			return 0, ErrNoData
+		}
+		if len(series) > 1 {
+			return 0, fmt.Errorf("query returned %d series, expected one", len(series))
+		}
+		sample = series[0].Value
+	default:
+		return 0, fmt.Errorf("unsupported result type %q", response.Data.ResultType)
+	}
+
+	if len(sample) != 2 {
+		return 0, errors.New("malformed sample")
+	}
+	raw, ok := sample[1].(string)
+	if !ok {
+		return 0, errors.New("malformed sample value")
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed sample value %q", raw)
+	}
+	if math.IsNaN(value) {
+		return 0, ErrNoData
+	}
+	return value, nil
+}
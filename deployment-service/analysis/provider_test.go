@@ -0,0 +1,147 @@
+package analysis
+
+import (
+	"context"
+	"deployment-service/config"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// prometheus serves body for every instant query and checks that the query
+// is passed through
+func prometheus(t *testing.T, status int, body string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			t.Errorf("path = %q, want /api/v1/query", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("query"); got != "up" {
+			t.Errorf("query = %q, want up", got)
+		}
+		if r.URL.Query().Get("time") == "" {
+			t.Error("query has no time")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	previous := config.App.Analysis
+	config.App.Analysis.PrometheusURL = server.URL + "/"
+	config.App.Analysis.QueryTimeout = 5 * time.Second
+	t.Cleanup(func() { config.App.Analysis = previous })
+}
+
+func TestPrometheusProviderQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    float64
+		wantErr string
+	}{
+		{
+			name: "scalar",
+			body: `{"status":"success","data":{"resultType":"scalar","result":[1700000000.5,"0.25"]}}`,
+			want: 0.25,
+		},
+		{
+			name: "vector with one series",
+			body: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"track":"canary"},"value":[1700000000,"42"]}]}}`,
+			want: 42,
+		},
+		{
+			name: "scientific notation",
+			body: `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"1.5e-3"]}}`,
+			want: 0.0015,
+		},
+		{
+			name:    "empty vector",
+			body:    `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			wantErr: ErrNoData.Error(),
+		},
+		{
+			name:    "NaN scalar",
+			body:    `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"NaN"]}}`,
+			wantErr: ErrNoData.Error(),
+		},
+		{
+			name:    "NaN in vector",
+			body:    `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"NaN"]}]}}`,
+			wantErr: ErrNoData.Error(),
+		},
+		{
+			name:    "multiple series",
+			body:    `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"pod":"a"},"value":[1700000000,"1"]},{"metric":{"pod":"b"},"value":[1700000000,"2"]}]}}`,
+			wantErr: "query returned 2 series, expected one",
+		},
+		{
+			name:    "matrix",
+			body:    `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+			wantErr: `unsupported result type "matrix"`,
+		},
+		{
+			name:    "query error",
+			status:  http.StatusBadRequest,
+			body:    `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+			wantErr: "prometheus query failed: parse error",
+		},
+		{
+			name:    "unreadable body",
+			status:  http.StatusBadGateway,
+			body:    `<html>bad gateway</html>`,
+			wantErr: "prometheus returned status 502 and an unreadable body",
+		},
+		{
+			name:    "sample without a value",
+			body:    `{"status":"success","data":{"resultType":"scalar","result":[1700000000]}}`,
+			wantErr: "malformed sample",
+		},
+		{
+			name:    "numeric sample value",
+			body:    `{"status":"success","data":{"resultType":"scalar","result":[1700000000,1]}}`,
+			wantErr: "malformed sample value",
+		},
+		{
+			name:    "unparsable sample value",
+			body:    `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"fast"]}}`,
+			wantErr: `malformed sample value "fast"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := tt.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			prometheus(t, status, tt.body)
+
+			got, err := (&PrometheusProvider{}).Query(context.Background(), "up")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Query = %g, %v, want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Query = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrometheusProviderNoData(t *testing.T) {
+	prometheus(t, http.StatusOK, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+
+	if _, err := (&PrometheusProvider{}).Query(context.Background(), "up"); !errors.Is(err, ErrNoData) {
+		t.Fatalf("Query error = %v, want ErrNoData", err)
+	}
+}
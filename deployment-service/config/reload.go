@@ -0,0 +1,186 @@
+package config
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Secrets and the core-service endpoints are kept out of Config and read
+// from App.Reload.ConfigDir, a directory of files named after settings as
+// mounted from a Kubernetes Secret or ConfigMap volume, falling back to
+// environment variables. They are re-read periodically and on SIGHUP. After a
+// rotation the previous secret is still accepted for
+// App.Reload.SecretGracePeriod, so tokens already in flight keep working.
+
+// rotatingSecret keeps the current value of a secret and, for a grace period
+// after rotation, the previous one
+type rotatingSecret struct {
+	mu            sync.RWMutex
+	current       []byte
+	previous      []byte
+	previousUntil time.Time
+}
+
+// set replaces the secret and reports whether an existing value was rotated
+func (s *rotatingSecret) set(value []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if string(value) == string(s.current) {
+		return false
+	}
+	rotated := len(s.current) > 0
+	if rotated {
+		s.previous = s.current
+		s.previousUntil = time.Now().Add(App.Reload.SecretGracePeriod)
+	}
+	s.current = value
+	return rotated
+}
+
+func (s *rotatingSecret) value() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// accepted returns the current secret followed by the previous one while it
+// is still within its grace period
+func (s *rotatingSecret) accepted() [][]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var values [][]byte
+	if len(s.current) > 0 {
+		values = append(values, s.current)
+	}
+	if len(s.previous) > 0 && time.Now().Before(s.previousUntil) {
+		values = append(values, s.previous)
+	}
+	return values
+}
+
+// reloadable holds the settings that can change while the service runs
+type reloadable struct {
+	coreServiceURL    string
+	oauthTokenURL     string
+	oauthClientID     string
+	oauthClientSecret string
+}
+
+var jwtSecret rotatingSecret
+var current reloadable
+var currentMu sync.RWMutex
+var reloadMu sync.Mutex
+
+// JWTSecret returns the shared secret used to sign service tokens
+func JWTSecret() []byte {
+	return jwtSecret.value()
+}
+
+// JWTSecrets returns the shared secrets accepted when verifying service tokens
+func JWTSecrets() [][]byte {
+	return jwtSecret.accepted()
+}
+
+// CoreServiceURL returns the base URL of core-service
+func CoreServiceURL() string {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current.coreServiceURL
+}
+
+// OAuthTokenURL returns the core-service token endpoint
+func OAuthTokenURL() string {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current.oauthTokenURL
+}
+
+// OAuthClientCredentials returns the OAuth2 client ID and secret. An empty ID
+// means tokens are self-signed with the shared JWT secret instead.
+func OAuthClientCredentials() (string, string) {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current.oauthClientID, current.oauthClientSecret
+}
+
+// lookup reads a setting from the config directory, falling back to the environment
+func lookup(key string) string {
+	if App.Reload.ConfigDir != "" {
+		if data, err := os.ReadFile(filepath.Join(App.Reload.ConfigDir, key)); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return os.Getenv(key)
+}
+
+// Reload re-reads the reloadable settings. A missing JWT_SECRET keeps the
+// previous values so a half-written volume can't break outgoing calls.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	secret := lookup("JWT_SECRET")
+	if secret == "" {
+		return errors.New("JWT_SECRET environment variable is required for deployment service")
+	}
+
+	next := reloadable{
+		coreServiceURL:    lookup("CORE_SERVICE_URL"),
+		oauthTokenURL:     lookup("OAUTH_TOKEN_URL"),
+		oauthClientID:     lookup("OAUTH_CLIENT_ID"),
+		oauthClientSecret: lookup("OAUTH_CLIENT_SECRET"),
+	}
+	if next.coreServiceURL == "" {
+		next.coreServiceURL = "http://localhost:8081"
+	}
+	if next.oauthTokenURL == "" {
+		next.oauthTokenURL = next.coreServiceURL + "/oauth/token"
+	}
+
+	if jwtSecret.set([]byte(secret)) {
+		log.Printf("JWT secret rotated, previous secret accepted for %s", App.Reload.SecretGracePeriod)
+	}
+
+	currentMu.Lock()
+	previous := current
+	current = next
+	currentMu.Unlock()
+
+	if previous.coreServiceURL != "" && previous != next {
+		log.Printf("Configuration reloaded (Core Service URL: %s)", next.coreServiceURL)
+	}
+	return nil
+}
+
+// WatchConfig reloads settings on SIGHUP and, when a config directory is set,
+// every App.Reload.Interval
+func WatchConfig() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if App.Reload.ConfigDir != "" {
+		ticker := time.NewTicker(App.Reload.Interval)
+		tick = ticker.C
+	}
+
+	go func() {
+		for {
+			select {
+			case <-hup:
+				log.Println("SIGHUP received, reloading configuration")
+			case <-tick:
+			}
+			if err := Reload(); err != nil {
+				log.Printf("Configuration reload failed, keeping previous values: %v", err)
+			}
+		}
+	}()
+}
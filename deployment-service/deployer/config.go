@@ -0,0 +1,61 @@
+package deployer
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/models"
+	"deployment-service/utils"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Config is a service's configuration as it applies to a deployment, with
+// secrets decrypted
+type Config struct {
+	Version int               `json:"version"`
+	Values  map[string]string `json:"values"`
+	Secrets map[string]string `json:"secrets"`
+}
+
+// resolveConfig fetches the config version a deployment pinned from
+// core-service, resolved for the deployment's environment
+func resolveConfig(ctx context.Context, deployment models.Deployment) (Config, error) {
+	query := url.Values{"version": {strconv.Itoa(deployment.ConfigVersion)}}
+	if deployment.Environment != nil {
+		query.Set("environment_id", deployment.Environment.ID.Hex())
+	}
+	endpoint := fmt.Sprintf("%s/services/%s/config/resolved?%s", config.CoreServiceURL(), deployment.ServiceID.Hex(), query.Encode())
+
+	token, err := utils.GetServiceToken()
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to generate auth token: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	resp, err := utils.NewCoreServiceClient(10 * time.Second).Do(req)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to connect to core service at %s: %v", config.CoreServiceURL(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return Config{}, fmt.Errorf("core service returned status %d for config version %d: %s", resp.StatusCode, deployment.ConfigVersion, string(body))
+	}
+
+	var resolved Config
+	if err := json.NewDecoder(resp.Body).Decode(&resolved); err != nil {
+		return Config{}, fmt.Errorf("failed to decode config: %v", err)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,200 @@
+package deployer
+
+import (
+	"bytes"
+	"context"
+	"deployment-service/config"
+	"deployment-service/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Deployer hands deployments to the deploy target and asks it about them
+type Deployer interface {
+	// Deploy asks the deploy target to start a deployment. It returns once
+	// the target accepted it; the outcome is reported by callback.
+	Deploy(ctx context.Context, deployment models.Deployment) error
+	// Status asks the deploy target how a deployment is doing. It returns
+	// ErrUnknown if the target has no record of it.
+	Status(ctx context.Context, deployment models.Deployment) (Result, error)
+	// Step asks the deploy target to carry out one step of a canary or
+	// blue-green rollout, or to abort it and send all traffic back to the
+	// old version.
+	Step(ctx context.Context, deployment models.Deployment, step models.StrategyStep) error
+}
+
+// Result is the deploy target's view of a deployment
+type Result struct {
+	Status  models.DeploymentStatus `json:"status"`
+	Message string                  `json:"message"`
+}
+
+var ErrUnknown = errors.New("deploy target has no record of the deployment")
+
+// Default is the deployer used by the service
+var Default Deployer = &HTTPDeployer{}
+
+// HTTPDeployer talks to a deploy target over HTTP at DEPLOYER_URL, or at
+// the deployer URL of the deployment's environment if it has one and it is
+// one of DEPLOYER_ALLOWED_URLS. Deploy
+// POSTs the deployment to that URL along with the config it pinned, Status
+// GETs {url}/{deployment_id} and Step POSTs the step to
+// {url}/{deployment_id}/steps.
+type HTTPDeployer struct{}
+
+// url returns the deploy target URL for a deployment. Environments can be
+// changed by more callers than the service's configuration, so an
+// environment's URL that isn't allowed is refused rather than sent the
+// deployment's secrets.
+func (d *HTTPDeployer) url(deployment models.Deployment) (string, error) {
+	if deployment.Environment == nil || deployment.Environment.Deployer == nil || deployment.Environment.Deployer.URL == "" {
+		return config.App.Deployments.DeployerURL, nil
+	}
+	target := deployment.Environment.Deployer.URL
+	if target != config.App.Deployments.DeployerURL && !slices.Contains(config.App.Deployments.DeployerAllowedURLs, target) {
+		return "", fmt.Errorf("deployer url %s of environment %s is not in DEPLOYER_ALLOWED_URLS", target, deployment.Environment.Name)
+	}
+	return target, nil
+}
+
+func (d *HTTPDeployer) Deploy(ctx context.Context, deployment models.Deployment) error {
+	target, err := d.url(deployment)
+	if err != nil {
+		return err
+	}
+
+	// The deploy target reports progress and the outcome to the callback
+	// URL, signing each callback with the secret
+	payload := map[string]interface{}{
+		"deployment_id":   deployment.ID.Hex(),
+		"service_id":      deployment.ServiceID.Hex(),
+		"spec":            deployment.Spec,
+		"status":          deployment.Status,
+		"timestamp":       time.Now().Unix(),
+		"callback_url":    fmt.Sprintf("%s/deployments/%s/callback", config.App.Deployments.CallbackBaseURL, deployment.ID.Hex()),
+		"callback_secret": deployment.CallbackSecret,
+		"deadline":        deployment.DeadlineAt.Unix(),
+	}
+	if deployment.Environment != nil {
+		payload["environment"] = deployment.Environment
+	}
+	// The config the deployment pinned, so a rollback restores it as well
+	if deployment.ConfigVersion > 0 {
+		resolved, err := resolveConfig(ctx, deployment)
+		if err != nil {
+			return err
+		}
+		payload["config"] = resolved
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Create client with timeout
+	client := &http.Client{
+		Timeout: 15 * time.Second,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call external API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Accept both 200 and 201 status codes
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("external API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+func (d *HTTPDeployer) Status(ctx context.Context, deployment models.Deployment) (Result, error) {
+	target, err := d.url(deployment)
+	if err != nil {
+		return Result{}, err
+	}
+	url := strings.TrimSuffix(target, "/") + "/" + deployment.ID.Hex()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	client := &http.Client{
+		Timeout: 15 * time.Second,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to call external API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Result{}, ErrUnknown
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return Result{}, fmt.Errorf("external API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result Result
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Result{}, fmt.Errorf("failed to decode status: %v", err)
+	}
+	switch result.Status {
+	case models.StatusPending, models.StatusRunning, models.StatusSucceeded, models.StatusFailed:
+		return result, nil
+	default:
+		return Result{}, ErrUnknown
+	}
+}
+
+func (d *HTTPDeployer) Step(ctx context.Context, deployment models.Deployment, step models.StrategyStep) error {
+	jsonData, err := json.Marshal(step)
+	if err != nil {
+		return fmt.Errorf("failed to marshal step: %v", err)
+	}
+
+	target, err := d.url(deployment)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimSuffix(target, "/") + "/" + deployment.ID.Hex() + "/steps"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{
+		Timeout: 15 * time.Second,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call external API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("external API returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package deployments
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/models"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	ErrNotAwaitingApproval = errors.New("deployment is not awaiting approval")
+	ErrApprovalExpired     = errors.New("approval request has expired")
+	ErrSelfApproval        = errors.New("the caller who triggered a deployment can't approve it")
+	ErrRoleNotAllowed      = errors.New("caller has none of the roles allowed to approve")
+	ErrAlreadyApproved     = errors.New("caller already approved the deployment")
+)
+
+// Approve records an approval of a deployment that is AwaitingApproval. Once
+// the policy's required approvals are in, the deployment is Ready.
+func Approve(ctx context.Context, deployment models.Deployment, approval models.Approval) (models.Deployment, error) {
+	if err := checkApprover(deployment, approval); err != nil {
+		return deployment, err
+	}
+	if slices.ContainsFunc(deployment.Approval.Approvals, func(a models.Approval) bool { return a.Actor == approval.Actor }) {
+		return deployment, ErrAlreadyApproved
+	}
+
+	approval.At = time.Now()
+	collection := config.GetCollection("deployments")
+	var approved models.Deployment
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":                      deployment.ID,
+			"status":                   models.StatusAwaitingApproval,
+			"approval.approvals.actor": bson.M{"$ne": approval.Actor},
+		},
+		bson.M{
+			"$push": bson.M{"approval.approvals": approval},
+			"$set":  bson.M{"updated_at": approval.At},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&approved)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return deployment, ErrAlreadyApproved
+	}
+	if err != nil {
+		return deployment, err
+	}
+
+	if len(approved.Approval.Approvals) < approved.Approval.Policy.RequiredApprovals {
+		return approved, nil
+	}
+
+	var approvers []string
+	for _, a := range approved.Approval.Approvals {
+		approvers = append(approvers, a.Actor)
+	}
+	ready, err := Ready(ctx, approved, "approval", "approved by "+strings.Join(approvers, ", "))
+	if errors.Is(err, ErrInvalidTransition) {
+		// A concurrent approval completed it first
+		return approved, nil
+	}
+	return ready, err
+}
+
+// Reject marks a deployment that is AwaitingApproval as Failed. The same
+// callers who may approve may reject.
+func Reject(ctx context.Context, deployment models.Deployment, rejection models.Approval) (models.Deployment, error) {
+	if err := checkApprover(deployment, rejection); err != nil {
+		return deployment, err
+	}
+
+	rejection.At = time.Now()
+	reason := "rejected by " + rejection.Actor
+	if rejection.Comment != "" {
+		reason += ": " + rejection.Comment
+	}
+	rejected, err := Transition(ctx, deployment.ID, Update{
+		Status:        models.StatusFailed,
+		Source:        "approval",
+		FailureReason: reason,
+	})
+	if errors.Is(err, ErrInvalidTransition) {
+		return deployment, ErrNotAwaitingApproval
+	}
+	if err != nil {
+		return rejected, err
+	}
+
+	rejected.Approval.Rejection = &rejection
+	_, err = config.GetCollection("deployments").UpdateOne(ctx,
+		bson.M{"_id": rejected.ID},
+		bson.M{"$set": bson.M{"approval.rejection": rejection}},
+	)
+	return rejected, err
+}
+
+// ExpireApproval fails a deployment whose approval request expired
+func ExpireApproval(ctx context.Context, deployment models.Deployment, source string) (models.Deployment, error) {
+	return Transition(ctx, deployment.ID, Update{
+		Status: models.StatusFailed,
+		Source: source,
+		FailureReason: fmt.Sprintf("approval expired with %d of %d approvals",
+			len(deployment.Approval.Approvals), deployment.Approval.Policy.RequiredApprovals),
+	})
+}
+
+// checkApprover enforces the approval policy on the caller
+func checkApprover(deployment models.Deployment, approver models.Approval) error {
+	if deployment.Status != models.StatusAwaitingApproval || deployment.Approval == nil {
+		return ErrNotAwaitingApproval
+	}
+	if !deployment.Approval.ExpiresAt.IsZero() && time.Now().After(deployment.Approval.ExpiresAt) {
+		return ErrApprovalExpired
+	}
+	if approver.Actor == deployment.TriggeredBy {
+		return ErrSelfApproval
+	}
+	allowed := deployment.Approval.Policy.AllowedRoles
+	if len(allowed) > 0 && !slices.ContainsFunc(approver.Roles, func(role string) bool { return slices.Contains(allowed, role) }) {
+		return ErrRoleNotAllowed
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package deployments
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/models"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// useTestDB connects to the MongoDB at TEST_MONGO_URI with a database of its
+// own, dropped afterwards. Tests that need MongoDB are skipped without it.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI not set")
+	}
+	previous := config.App.Mongo
+	config.App.Mongo.URI = uri
+	config.App.Mongo.Database = "deployment_test_" + bson.NewObjectID().Hex()
+	config.ConnectDB()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		config.MongoClient.Database(config.App.Mongo.Database).Drop(ctx)
+		config.MongoClient.Disconnect(ctx)
+		config.MongoClient = nil
+		config.App.Mongo = previous
+	})
+}
+
+func awaitingApproval(policy models.ApprovalPolicy) models.Deployment {
+	return models.Deployment{
+		ID:          bson.NewObjectID(),
+		ServiceID:   bson.NewObjectID(),
+		Status:      models.StatusAwaitingApproval,
+		TriggeredBy: "alice",
+		Approval:    &models.ApprovalState{Policy: policy, Approvals: []models.Approval{}},
+	}
+}
+
+func TestApproveRefusesCaller(t *testing.T) {
+	policy := models.ApprovalPolicy{RequiredApprovals: 1, AllowedRoles: []string{"release-manager"}}
+	manager := models.Approval{Actor: "bob", Roles: []string{"developer", "release-manager"}}
+
+	expired := awaitingApproval(policy)
+	expired.Approval.ExpiresAt = time.Now().Add(-time.Minute)
+	approved := awaitingApproval(policy)
+	approved.Approval.Approvals = []models.Approval{{Actor: "bob"}}
+	running := awaitingApproval(policy)
+	running.Status = models.StatusRunning
+	noPolicy := awaitingApproval(policy)
+	noPolicy.Approval = nil
+
+	tests := []struct {
+		name       string
+		deployment models.Deployment
+		approval   models.Approval
+		want       error
+	}{
+		{"self-approval", awaitingApproval(policy), models.Approval{Actor: "alice", Roles: []string{"release-manager"}}, ErrSelfApproval},
+		{"role outside the allowed roles", awaitingApproval(policy), models.Approval{Actor: "bob", Roles: []string{"developer"}}, ErrRoleNotAllowed},
+		{"no roles", awaitingApproval(policy), models.Approval{Actor: "bob"}, ErrRoleNotAllowed},
+		{"expired", expired, manager, ErrApprovalExpired},
+		{"duplicate approver", approved, manager, ErrAlreadyApproved},
+		{"not awaiting approval", running, manager, ErrNotAwaitingApproval},
+		{"without approval state", noPolicy, manager, ErrNotAwaitingApproval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Approve(context.Background(), tt.deployment, tt.approval); !errors.Is(err, tt.want) {
+				t.Fatalf("Approve error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRejectRefusesCaller(t *testing.T) {
+	policy := models.ApprovalPolicy{RequiredApprovals: 1, AllowedRoles: []string{"release-manager"}}
+	expired := awaitingApproval(policy)
+	expired.Approval.ExpiresAt = time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name       string
+		deployment models.Deployment
+		rejection  models.Approval
+		want       error
+	}{
+		{"self-rejection", awaitingApproval(policy), models.Approval{Actor: "alice", Roles: []string{"release-manager"}}, ErrSelfApproval},
+		{"role outside the allowed roles", awaitingApproval(policy), models.Approval{Actor: "bob", Roles: []string{"developer"}}, ErrRoleNotAllowed},
+		{"expired", expired, models.Approval{Actor: "bob", Roles: []string{"release-manager"}}, ErrApprovalExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Reject(context.Background(), tt.deployment, tt.rejection); !errors.Is(err, tt.want) {
+				t.Fatalf("Reject error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// createAwaitingApproval stores a deployment awaiting approval. It is
+// scheduled for later so approving it doesn't start it.
+func createAwaitingApproval(t *testing.T, policy models.ApprovalPolicy) models.Deployment {
+	t.Helper()
+	later := time.Now().Add(time.Hour)
+	deployment, err := Create(context.Background(), models.Deployment{
+		ServiceID:   bson.NewObjectID(),
+		TriggeredBy: "alice",
+		ScheduledAt: &later,
+		Approval:    &models.ApprovalState{Policy: policy},
+	}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Status != models.StatusAwaitingApproval {
+		t.Fatalf("created deployment is %s, want AwaitingApproval", deployment.Status)
+	}
+	return deployment
+}
+
+func TestApproveRequiredApprovals(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+	deployment := createAwaitingApproval(t, models.ApprovalPolicy{RequiredApprovals: 2})
+
+	first, err := Approve(ctx, deployment, models.Approval{Actor: "bob"})
+	if err != nil {
+		t.Fatalf("first approval: %v", err)
+	}
+	if first.Status != models.StatusAwaitingApproval || len(first.Approval.Approvals) != 1 {
+		t.Fatalf("after one of two approvals: %s with %d approvals", first.Status, len(first.Approval.Approvals))
+	}
+
+	// The caller's copy may be stale; the stored approvals still count once
+	if _, err := Approve(ctx, deployment, models.Approval{Actor: "bob"}); !errors.Is(err, ErrAlreadyApproved) {
+		t.Fatalf("second approval by the same caller: error = %v, want ErrAlreadyApproved", err)
+	}
+
+	second, err := Approve(ctx, first, models.Approval{Actor: "carol"})
+	if err != nil {
+		t.Fatalf("second approval: %v", err)
+	}
+	if second.Status != models.StatusScheduled {
+		t.Fatalf("after two of two approvals: %s, want Scheduled", second.Status)
+	}
+	if second.Approval.ApprovedAt.IsZero() || len(second.Approval.Approvals) != 2 {
+		t.Fatalf("approval state = %+v, want approved by two callers", second.Approval)
+	}
+}
+
+func TestReject(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+	deployment := createAwaitingApproval(t, models.ApprovalPolicy{RequiredApprovals: 1})
+
+	rejected, err := Reject(ctx, deployment, models.Approval{Actor: "bob", Comment: "not today"})
+	if err != nil {
+		t.Fatalf("Reject: %v", err)
+	}
+	if rejected.Status != models.StatusFailed || rejected.FailureReason != "rejected by bob: not today" {
+		t.Fatalf("rejected deployment is %s (%q)", rejected.Status, rejected.FailureReason)
+	}
+	if rejected.Approval.Rejection == nil || rejected.Approval.Rejection.Actor != "bob" {
+		t.Fatalf("rejection = %+v, want bob's", rejected.Approval.Rejection)
+	}
+
+	if _, err := Approve(ctx, rejected, models.Approval{Actor: "carol"}); !errors.Is(err, ErrNotAwaitingApproval) {
+		t.Fatalf("approving a rejected deployment: error = %v, want ErrNotAwaitingApproval", err)
+	}
+}
@@ -0,0 +1,72 @@
+package deployments
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"deployment-service/config"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CallbackSignatureHeader carries the callback signature in the form
+// "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">", keyed
+// with the deployment's callback secret
+const CallbackSignatureHeader = "X-Callback-Signature"
+
+var ErrInvalidSignature = errors.New("invalid callback signature")
+
+// GenerateCallbackSecret returns a random per-deployment signing secret
+func GenerateCallbackSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate callback secret: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// SignCallback computes the signature header value for a callback body
+func SignCallback(secret string, timestamp time.Time, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), callbackMAC(secret, timestamp.Unix(), body))
+}
+
+// VerifyCallback checks a signature header against the body and rejects
+// signatures older than DEPLOYMENT_CALLBACK_TOLERANCE
+func VerifyCallback(secret, header string, body []byte) error {
+	var timestamp int64
+	var signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			signature = value
+		}
+	}
+	if timestamp == 0 || signature == "" || secret == "" {
+		return ErrInvalidSignature
+	}
+
+	age := time.Since(time.Unix(timestamp, 0))
+	if age > config.App.Deployments.CallbackTolerance || age < -config.App.Deployments.CallbackTolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	expected := callbackMAC(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func callbackMAC(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
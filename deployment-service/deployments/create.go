@@ -0,0 +1,66 @@
+package deployments
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// claimLease keeps the scheduler off a deployment its creator is about to
+// pass to Ready. Should the creator fail to, the scheduler takes over after.
+const claimLease = 2 * time.Minute
+
+// Create stores a new deployment with a fresh callback secret. It is
+// AwaitingApproval if it has an approval policy, Scheduled if it is scheduled
+// for later, Waiting if its release holds it, and Queued otherwise, either
+// until a freeze ends or, with nothing holding it, for the caller to pass it
+// to Ready, which starts it. source names who created it, for the timeline.
+func Create(ctx context.Context, deployment models.Deployment, source string) (models.Deployment, error) {
+	callbackSecret, err := GenerateCallbackSecret()
+	if err != nil {
+		return deployment, err
+	}
+
+	now := time.Now()
+	deployment.Status = models.StatusQueued
+	if deployment.Queue == nil {
+		deployment.Queue = &models.QueueState{Until: now, Freezes: []models.ActiveFreeze{}, LockUntil: now.Add(claimLease)}
+	}
+	if deployment.ScheduledAt != nil && deployment.ScheduledAt.After(now) {
+		deployment.Status = models.StatusScheduled
+		deployment.Queue = nil
+	}
+	if deployment.Held {
+		// Freezes are checked again once the release lets it go
+		deployment.Status = models.StatusWaiting
+		deployment.Queue = nil
+	}
+	if deployment.Approval != nil {
+		deployment.Status = models.StatusAwaitingApproval
+		deployment.Approval.Approvals = []models.Approval{}
+		if deployment.Approval.Policy.ExpirySeconds > 0 {
+			deployment.Approval.ExpiresAt = now.Add(time.Duration(deployment.Approval.Policy.ExpirySeconds) * time.Second)
+		}
+	}
+	deployment.Timeline = []models.StatusChange{{Status: deployment.Status, At: now, Source: source}}
+	deployment.CallbackSecret = callbackSecret
+	deployment.CreatedAt = now
+	deployment.UpdatedAt = now
+
+	result, err := config.GetCollection("deployments").InsertOne(ctx, deployment)
+	if err != nil {
+		return deployment, err
+	}
+	deployment.ID = result.InsertedID.(bson.ObjectID)
+	return deployment, nil
+}
+
+// Start announces a Pending deployment and hands it to the deployer. It
+// blocks until the deploy target accepted or refused it.
+func Start(deployment models.Deployment, source string) {
+	PublishEvent(EventStarted, deployment)
+	Drive(deployment, source)
+}
@@ -0,0 +1,45 @@
+package deployments
+
+import (
+	"context"
+	"deployment-service/deployer"
+	"deployment-service/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Drive hands a Pending deployment to the deployer and marks it Running once
+// the deploy target accepted it, or Failed if it refused. source names who
+// drove it, for the timeline.
+func Drive(deployment models.Deployment, source string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := deployer.Default.Deploy(ctx, deployment); err != nil {
+		// Mark as Failed
+		fmt.Printf("Deployment %s failed: %v\n", deployment.ID.Hex(), err)
+		if _, err := Transition(ctx, deployment.ID, Update{
+			Status:        models.StatusFailed,
+			Source:        source,
+			FailureReason: err.Error(),
+		}); err != nil && !errors.Is(err, ErrInvalidTransition) {
+			fmt.Printf("Failed to mark deployment %s as Failed: %v\n", deployment.ID.Hex(), err)
+		}
+		return
+	}
+
+	// Mark as Running until the deploy target calls back. A fast deploy
+	// target may already have reported, which is not an error.
+	_, err := Transition(ctx, deployment.ID, Update{
+		Status:  models.StatusRunning,
+		Source:  source,
+		Message: "accepted by the deploy target",
+	})
+	switch {
+	case err == nil:
+		fmt.Printf("Deployment %s is now Running\n", deployment.ID.Hex())
+	case !errors.Is(err, ErrInvalidTransition):
+		fmt.Printf("Failed to update deployment %s to Running: %v\n", deployment.ID.Hex(), err)
+	}
+}
@@ -0,0 +1,75 @@
+package deployments
+
+import (
+	"bytes"
+	"deployment-service/config"
+	"deployment-service/models"
+	"deployment-service/utils"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Deployment lifecycle events reported to core-service
+const (
+	EventStarted   = "DeploymentStarted"
+	EventSucceeded = "DeploymentSucceeded"
+	EventFailed    = "DeploymentFailed"
+)
+
+// PublishEvent reports a deployment lifecycle event to the core-service
+// event feed, from where it reaches webhook subscribers. Delivery is retried
+// a few times; a lost event is logged, never fatal.
+func PublishEvent(eventType string, deployment models.Deployment) {
+	event := map[string]interface{}{
+		"type":           eventType,
+		"aggregate_type": "deployment",
+		"aggregate_id":   deployment.ID.Hex(),
+		"payload":        deployment,
+	}
+	if !deployment.TenantID.IsZero() {
+		event["tenant_id"] = deployment.TenantID.Hex()
+	}
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		fmt.Printf("Failed to encode %s event for deployment %s: %v\n", eventType, deployment.ID.Hex(), err)
+		return
+	}
+
+	url := fmt.Sprintf("%s/events", config.CoreServiceURL())
+	for attempt := 1; attempt <= 3; attempt++ {
+		if err = postEvent(url, jsonData); err == nil {
+			return
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	fmt.Printf("Failed to publish %s event for deployment %s: %v\n", eventType, deployment.ID.Hex(), err)
+}
+
+func postEvent(url string, body []byte) error {
+	token, err := utils.GetServiceToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate auth token: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	resp, err := utils.NewCoreServiceClient(10 * time.Second).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("core service returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package deployments
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/freeze"
+	"deployment-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// lockCollection holds a lease per service and environment, taken while a
+// deployment there is checked and started
+const lockCollection = "deployment_locks"
+
+// targetLease bounds how long a crashed replica can hold a target's lease
+const targetLease = 30 * time.Second
+
+// Ready moves a new, approved, scheduled, waiting or queued deployment on,
+// and is the only way a deployment is started. If it is scheduled for later
+// it is Scheduled until then, and if its release still holds it it is
+// Waiting until let go. If a freeze is in effect it is Queued until the
+// freeze ends, whatever the freeze's action, since nobody is waiting on a
+// response to reject. While another deployment of its service to the same
+// environment is in progress, or being started, a Scheduled one stays
+// Scheduled and any other is Queued. Otherwise it becomes Pending with a
+// fresh deadline and is started. Break-glass deployments skip the freeze
+// check.
+func Ready(ctx context.Context, deployment models.Deployment, source, message string) (models.Deployment, error) {
+	now := time.Now()
+	fields := bson.M{}
+	if deployment.Approval != nil && deployment.Approval.ApprovedAt.IsZero() {
+		fields["approval.approved_at"] = now
+	}
+
+	if deployment.ScheduledAt != nil && deployment.ScheduledAt.After(now) {
+		return Transition(ctx, deployment.ID, Update{
+			Status:  models.StatusScheduled,
+			Source:  source,
+			From:    []models.DeploymentStatus{deployment.Status},
+			Message: fmt.Sprintf("%s; scheduled for %s", message, deployment.ScheduledAt.Format(time.RFC3339)),
+			Fields:  fields,
+		})
+	}
+
+	if deployment.Held {
+		return Transition(ctx, deployment.ID, Update{
+			Status:  models.StatusWaiting,
+			Source:  source,
+			From:    []models.DeploymentStatus{deployment.Status},
+			Message: message + "; waiting for its release",
+			Fields:  fields,
+		})
+	}
+
+	if deployment.BreakGlass == nil {
+		decision, err := freeze.Check(ctx, deployment.TenantID, deployment.ServiceID, now)
+		if err != nil {
+			return deployment, err
+		}
+		if decision.Frozen {
+			queue := models.QueueState{Until: decision.Until, Freezes: decision.Windows}
+			if deployment.Status == models.StatusQueued {
+				// Still frozen, wait for the next freeze to end
+				_, err := config.GetCollection("deployments").UpdateOne(ctx,
+					bson.M{"_id": deployment.ID, "status": models.StatusQueued},
+					bson.M{"$set": bson.M{"queue": queue, "updated_at": now}},
+				)
+				deployment.Queue = &queue
+				return deployment, err
+			}
+			fields["queue"] = queue
+			return Transition(ctx, deployment.ID, Update{
+				Status:  models.StatusQueued,
+				Source:  source,
+				From:    []models.DeploymentStatus{deployment.Status},
+				Message: fmt.Sprintf("%s; queued until %s by a freeze", message, decision.Until.Format(time.RFC3339)),
+				Fields:  fields,
+			})
+		}
+	}
+
+	// Only one deployment of a service to an environment is in progress at
+	// a time; the others wait and are tried again on the scheduler's next
+	// pass. The target's lease keeps two callers from both finding it idle
+	// and starting one each.
+	key := targetKey(deployment)
+	locked, err := lockTarget(ctx, key, now)
+	if err != nil {
+		return deployment, err
+	}
+	busy := &models.Deployment{}
+	if locked {
+		defer unlockTarget(key)
+		if busy, err = inProgress(ctx, deployment); err != nil {
+			return deployment, err
+		}
+	}
+	if busy != nil {
+		retry := now.Add(config.App.Scheduler.Interval)
+		collection := config.GetCollection("deployments")
+		if deployment.Status == models.StatusScheduled {
+			_, err := collection.UpdateOne(ctx,
+				bson.M{"_id": deployment.ID, "status": models.StatusScheduled},
+				bson.M{"$set": bson.M{"schedule_lock_until": retry, "updated_at": now}},
+			)
+			return deployment, err
+		}
+		queue := models.QueueState{Until: retry, Freezes: []models.ActiveFreeze{}, BlockedBy: busy.ID}
+		if deployment.Status == models.StatusQueued {
+			_, err := collection.UpdateOne(ctx,
+				bson.M{"_id": deployment.ID, "status": models.StatusQueued},
+				bson.M{"$set": bson.M{"queue": queue, "updated_at": now}},
+			)
+			deployment.Queue = &queue
+			return deployment, err
+		}
+		fields["queue"] = queue
+		behind := "another deployment"
+		if !busy.ID.IsZero() {
+			behind = "deployment " + busy.ID.Hex()
+		}
+		return Transition(ctx, deployment.ID, Update{
+			Status:  models.StatusQueued,
+			Source:  source,
+			From:    []models.DeploymentStatus{deployment.Status},
+			Message: fmt.Sprintf("%s; queued behind %s", message, behind),
+			Fields:  fields,
+		})
+	}
+
+	fields["deadline_at"] = now.Add(config.App.Deployments.Timeout)
+	pending, err := Transition(ctx, deployment.ID, Update{
+		Status:  models.StatusPending,
+		Source:  source,
+		From:    []models.DeploymentStatus{deployment.Status},
+		Message: message,
+		Fields:  fields,
+	})
+	if err != nil {
+		return pending, err
+	}
+	go Start(pending, "deployer")
+	return pending, nil
+}
+
+// targetKey names the service and environment a deployment goes to
+func targetKey(deployment models.Deployment) string {
+	if deployment.Environment == nil {
+		return deployment.ServiceID.Hex()
+	}
+	return deployment.ServiceID.Hex() + "/" + deployment.Environment.ID.Hex()
+}
+
+// lockTarget takes the lease on a service and environment for starting a
+// deployment there, and reports false if someone else holds it
+func lockTarget(ctx context.Context, key string, now time.Time) (bool, error) {
+	_, err := config.GetCollection(lockCollection).UpdateOne(ctx,
+		bson.M{"_id": key, "lock_until": bson.M{"$not": bson.M{"$gt": now}}},
+		bson.M{"$set": bson.M{"lock_until": now.Add(targetLease)}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlockTarget gives up the lease taken by lockTarget
+func unlockTarget(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := config.GetCollection(lockCollection).UpdateOne(ctx,
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"lock_until": time.Time{}}},
+	); err != nil {
+		fmt.Printf("Failed to unlock deployment target %s: %v\n", key, err)
+	}
+}
+
+// inProgress returns another deployment of the same service to the same
+// environment that is Pending, Running or Verifying, or nil if there is none
+func inProgress(ctx context.Context, deployment models.Deployment) (*models.Deployment, error) {
+	filter := bson.M{
+		"_id":        bson.M{"$ne": deployment.ID},
+		"service_id": deployment.ServiceID,
+		"status":     bson.M{"$in": []models.DeploymentStatus{models.StatusPending, models.StatusRunning, models.StatusVerifying}},
+	}
+	if deployment.Environment != nil {
+		filter["environment.id"] = deployment.Environment.ID
+	} else {
+		filter["environment"] = bson.M{"$exists": false}
+	}
+
+	var other models.Deployment
+	err := config.GetCollection("deployments").FindOne(ctx, filter,
+		options.FindOne().SetProjection(bson.M{"_id": 1}),
+	).Decode(&other)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &other, nil
+}
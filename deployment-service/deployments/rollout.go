@@ -0,0 +1,270 @@
+package deployments
+
+import (
+	"context"
+	"deployment-service/analysis"
+	"deployment-service/config"
+	"deployment-service/deployer"
+	"deployment-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var ErrNoRollout = errors.New("deployment has no active rollout")
+
+// beginRollout starts the steps of a canary or blue-green deployment once the
+// deploy target reports the new version is up. The deployment stays Running
+// until the last step is done.
+func beginRollout(ctx context.Context, deployment models.Deployment, update Update) (models.Deployment, error) {
+	message := update.Message
+	if message == "" {
+		message = "new version is up"
+	}
+	return saveRollout(ctx,
+		bson.M{
+			"_id":     deployment.ID,
+			"status":  bson.M{"$in": []models.DeploymentStatus{models.StatusPending, models.StatusRunning}},
+			"rollout": bson.M{"$exists": false},
+		},
+		models.RolloutState{Phase: models.RolloutProgressing, ResumeAt: time.Now()},
+		update.Source,
+		fmt.Sprintf("%s, starting %s rollout", message, deployment.Spec.Strategy.Type),
+		nil,
+	)
+}
+
+// AdvanceRollout runs the due steps of a progressing rollout until it pauses
+// or completes. A completed rollout goes on to Succeed; a step the deploy
+// target refuses aborts the deployment.
+func AdvanceRollout(ctx context.Context, deployment models.Deployment) error {
+	steps := deployment.Spec.Strategy.Steps()
+
+	for deployment.Rollout != nil && deployment.Rollout.Phase == models.RolloutProgressing && !deployment.Rollout.ResumeAt.After(time.Now()) {
+		state := *deployment.Rollout
+
+		if state.Step >= len(steps) {
+			state.Phase = models.RolloutCompleted
+			done, err := saveRollout(ctx, rolloutAt(deployment), state, "", "", nil)
+			if err != nil {
+				return ignoreConflict(err)
+			}
+			_, err = Succeed(ctx, done, Update{Source: "orchestrator", Message: "rollout complete"})
+			return ignoreConflict(err)
+		}
+
+		step := steps[state.Step]
+		number := state.Step + 1
+		state.Step++
+		state.Retries = 0
+		var message, failure string
+		var run *models.AnalysisRun
+		switch {
+		case (step.Action == models.StepPause || step.Action == models.StepAnalyze) && state.SkipPauses:
+			message = step.Action + " skipped"
+		case step.Action == models.StepPause && step.Manual:
+			state.Phase = models.RolloutPaused
+			message = "paused until promoted"
+		case step.Action == models.StepPause:
+			pause := time.Duration(step.PauseSeconds) * time.Second
+			state.ResumeAt = time.Now().Add(pause)
+			message = fmt.Sprintf("paused for %s", pause)
+		case step.Action == models.StepAnalyze:
+			message, failure, run = analyze(ctx, deployment, &state, step)
+		default:
+			if err := deployer.Default.Step(ctx, deployment, step); err != nil {
+				failRollout(ctx, deployment, fmt.Sprintf("%s failed: %v", describeStep(step), err))
+				return err
+			}
+			state.Weight = step.Weight
+			message = describeStep(step)
+		}
+
+		next, err := saveRollout(ctx, rolloutAt(deployment), state, "orchestrator",
+			fmt.Sprintf("step %d/%d: %s", number, len(steps), message), run)
+		if err != nil {
+			return ignoreConflict(err)
+		}
+		if failure != "" {
+			failRollout(ctx, next, failure)
+			return nil
+		}
+		deployment = next
+	}
+	return nil
+}
+
+// analyze judges the canary at its current weight. A passing analysis moves
+// the rollout on, an inconclusive one is retried after the analysis interval
+// until it runs out of retries, and anything else returns the reason to
+// abort the canary for.
+func analyze(ctx context.Context, deployment models.Deployment, state *models.RolloutState, step models.StrategyStep) (string, string, *models.AnalysisRun) {
+	spec := *deployment.Spec.Strategy.Canary.Analysis
+	run := analysis.Run(ctx, deployment, spec, step.Weight)
+	run.Step = state.Step
+
+	switch run.Outcome {
+	case models.AnalysisPassed:
+		return fmt.Sprintf("analysis passed at %d%%", step.Weight), "", &run
+	case models.AnalysisInconclusive:
+		maxInconclusive := spec.MaxInconclusive
+		if maxInconclusive == 0 {
+			maxInconclusive = analysis.DefaultMaxInconclusive
+		}
+		retries := deployment.Rollout.Retries + 1
+		if retries < maxInconclusive {
+			interval := analysis.DefaultInterval
+			if spec.IntervalSeconds > 0 {
+				interval = time.Duration(spec.IntervalSeconds) * time.Second
+			}
+			// Run the same step again later
+			state.Step--
+			state.Retries = retries
+			state.ResumeAt = time.Now().Add(interval)
+			return fmt.Sprintf("analysis inconclusive (%d/%d), retrying in %s: %s", retries, maxInconclusive, interval, run.Error), "", &run
+		}
+		reason := fmt.Sprintf("analysis inconclusive %d times at %d%%: %s", retries, step.Weight, analysis.Describe(run))
+		return reason, reason, &run
+	default:
+		reason := fmt.Sprintf("analysis failed at %d%%: %s", step.Weight, analysis.Describe(run))
+		return reason, reason, &run
+	}
+}
+
+// Promote resumes a rollout that waits for it, or cuts a timed pause short.
+// With full set the remaining pauses are skipped as well.
+func Promote(ctx context.Context, deployment models.Deployment, source string, full bool) (models.Deployment, error) {
+	if !deployment.Rollout.IsActive() || deployment.Status != models.StatusRunning {
+		return deployment, ErrNoRollout
+	}
+
+	state := *deployment.Rollout
+	state.Phase = models.RolloutProgressing
+	state.ResumeAt = time.Now()
+	message := "promoted"
+	if full {
+		state.SkipPauses = true
+		message = "promoted, skipping remaining pauses"
+	}
+	return saveRollout(ctx, rolloutAt(deployment), state, source, message, nil)
+}
+
+// Abort stops a deployment and marks it Failed. Deployments the deploy target
+// is working on are aborted there first, sending all traffic back to the old
+// version; nothing is changed if the deploy target can't be reached.
+func Abort(ctx context.Context, deployment models.Deployment, source, reason string) (models.Deployment, error) {
+	if deployment.Status.IsFinal() {
+		return deployment, ErrInvalidTransition
+	}
+	if deployment.Status == models.StatusRunning || deployment.Status == models.StatusVerifying {
+		if err := deployer.Default.Step(ctx, deployment, models.StrategyStep{Action: models.StepAbort}); err != nil {
+			return deployment, err
+		}
+	}
+
+	failed, err := Transition(ctx, deployment.ID, Update{
+		Status:        models.StatusFailed,
+		Source:        source,
+		FailureReason: "aborted: " + reason,
+	})
+	if err != nil {
+		return failed, err
+	}
+	if failed.Rollout != nil {
+		if _, err := config.GetCollection("deployments").UpdateOne(ctx,
+			bson.M{"_id": failed.ID},
+			bson.M{"$set": bson.M{"rollout.phase": models.RolloutAborted, "rollout.weight": 0}},
+		); err != nil {
+			return failed, err
+		}
+		failed.Rollout.Phase = models.RolloutAborted
+		failed.Rollout.Weight = 0
+	}
+	return failed, nil
+}
+
+// failRollout aborts a deployment whose step failed, and still marks it
+// Failed if the abort doesn't reach the deploy target
+func failRollout(ctx context.Context, deployment models.Deployment, reason string) {
+	fmt.Printf("Rollout of deployment %s failed: %s\n", deployment.ID.Hex(), reason)
+	_, err := Abort(ctx, deployment, "orchestrator", reason)
+	if err == nil || errors.Is(err, ErrInvalidTransition) {
+		return
+	}
+	reason = fmt.Sprintf("%s; abort failed: %v", reason, err)
+	if _, err := Transition(ctx, deployment.ID, Update{
+		Status:        models.StatusFailed,
+		Source:        "orchestrator",
+		FailureReason: reason,
+	}); err != nil && !errors.Is(err, ErrInvalidTransition) {
+		fmt.Printf("Failed to mark deployment %s as Failed: %v\n", deployment.ID.Hex(), err)
+	}
+}
+
+// rolloutAt matches a Running deployment whose rollout hasn't moved since it
+// was read, so concurrent promotes, aborts and steps can't both apply
+func rolloutAt(deployment models.Deployment) bson.M {
+	return bson.M{
+		"_id":           deployment.ID,
+		"status":        models.StatusRunning,
+		"rollout.step":  deployment.Rollout.Step,
+		"rollout.phase": deployment.Rollout.Phase,
+	}
+}
+
+// saveRollout stores a rollout state on the deployment matching filter and
+// records message on its timeline unless it is empty, along with an analysis
+// run if there is one. It returns ErrInvalidTransition if the deployment
+// changed in the meantime.
+func saveRollout(ctx context.Context, filter bson.M, state models.RolloutState, source, message string, run *models.AnalysisRun) (models.Deployment, error) {
+	now := time.Now()
+	change := bson.M{"$set": bson.M{"status": models.StatusRunning, "rollout": state, "updated_at": now}}
+	push := bson.M{}
+	if message != "" {
+		push["timeline"] = models.StatusChange{
+			Status:  models.StatusRunning,
+			At:      now,
+			Source:  source,
+			Message: message,
+		}
+	}
+	if run != nil {
+		push["analyses"] = run
+	}
+	if len(push) > 0 {
+		change["$push"] = push
+	}
+
+	var deployment models.Deployment
+	err := config.GetCollection("deployments").FindOneAndUpdate(ctx, filter, change,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&deployment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return deployment, ErrInvalidTransition
+	}
+	return deployment, err
+}
+
+func describeStep(step models.StrategyStep) string {
+	switch step.Action {
+	case models.StepSetWeight:
+		return fmt.Sprintf("canary weight %d%%", step.Weight)
+	case models.StepCutover:
+		return "traffic cut over to the new version"
+	case models.StepRetireOld:
+		return "old version retired"
+	default:
+		return step.Action
+	}
+}
+
+func ignoreConflict(err error) error {
+	if errors.Is(err, ErrInvalidTransition) {
+		return nil
+	}
+	return err
+}
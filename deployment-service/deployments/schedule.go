@@ -0,0 +1,60 @@
+package deployments
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var ErrNotScheduled = errors.New("deployment is not waiting to start")
+
+// Reschedule moves the scheduled time of a deployment that is Scheduled, or
+// AwaitingApproval and scheduled
+func Reschedule(ctx context.Context, deployment models.Deployment, at time.Time, source string) (models.Deployment, error) {
+	if deployment.Status != models.StatusScheduled &&
+		!(deployment.Status == models.StatusAwaitingApproval && deployment.ScheduledAt != nil) {
+		return deployment, ErrNotScheduled
+	}
+
+	now := time.Now()
+	var rescheduled models.Deployment
+	err := config.GetCollection("deployments").FindOneAndUpdate(ctx,
+		bson.M{"_id": deployment.ID, "status": deployment.Status},
+		bson.M{
+			"$set": bson.M{"scheduled_at": at, "updated_at": now},
+			"$push": bson.M{"timeline": models.StatusChange{
+				Status:  deployment.Status,
+				At:      now,
+				Source:  source,
+				Message: fmt.Sprintf("rescheduled to %s", at.Format(time.RFC3339)),
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&rescheduled)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return deployment, ErrNotScheduled
+	}
+	return rescheduled, err
+}
+
+// Cancel marks a deployment that hasn't started yet, because it is
+// Scheduled, Waiting or Queued, as Failed
+func Cancel(ctx context.Context, deployment models.Deployment, source, reason string) (models.Deployment, error) {
+	canceled, err := Transition(ctx, deployment.ID, Update{
+		Status:        models.StatusFailed,
+		Source:        source,
+		From:          []models.DeploymentStatus{models.StatusScheduled, models.StatusWaiting, models.StatusQueued},
+		FailureReason: "canceled: " + reason,
+	})
+	if errors.Is(err, ErrInvalidTransition) {
+		return deployment, ErrNotScheduled
+	}
+	return canceled, err
+}
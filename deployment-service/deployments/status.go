@@ -0,0 +1,122 @@
+package deployments
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/models"
+	"errors"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// maxLogLines is how many log lines are kept per deployment
+const maxLogLines = 1000
+
+var (
+	ErrNotFound          = errors.New("deployment not found")
+	ErrInvalidTransition = errors.New("invalid status transition")
+)
+
+var allStatuses = []models.DeploymentStatus{
+	models.StatusAwaitingApproval,
+	models.StatusWaiting,
+	models.StatusScheduled,
+	models.StatusQueued,
+	models.StatusPending,
+	models.StatusRunning,
+	models.StatusVerifying,
+	models.StatusSucceeded,
+	models.StatusFailed,
+}
+
+// Update describes a status change and the details that come with it
+type Update struct {
+	Status        models.DeploymentStatus
+	Source        string
+	Message       string
+	Progress      int
+	FailureReason string
+	Logs          []string
+	// Fields are set along with the status, in the same write
+	Fields bson.M
+	// From, if set, further limits the statuses the change may start from
+	From []models.DeploymentStatus
+}
+
+// Transition moves a deployment to a new status if its current status
+// allows it, recording the change on its timeline. The check and the write
+// are one conditional update, so concurrent callbacks can't both win.
+// Reaching a final status publishes the matching lifecycle event.
+func Transition(ctx context.Context, id bson.ObjectID, update Update) (models.Deployment, error) {
+	var from []models.DeploymentStatus
+	for _, status := range allStatuses {
+		if len(update.From) > 0 && !slices.Contains(update.From, status) {
+			continue
+		}
+		if status.CanTransitionTo(update.Status) {
+			from = append(from, status)
+		}
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"status":     update.Status,
+		"updated_at": now,
+	}
+	if update.Progress > 0 {
+		set["progress"] = update.Progress
+	}
+	if update.Status == models.StatusSucceeded {
+		set["progress"] = 100
+	}
+	if update.FailureReason != "" {
+		set["failure_reason"] = update.FailureReason
+	}
+	for field, value := range update.Fields {
+		set[field] = value
+	}
+	push := bson.M{
+		"timeline": models.StatusChange{
+			Status:  update.Status,
+			At:      now,
+			Source:  update.Source,
+			Message: update.Message,
+		},
+	}
+	if len(update.Logs) > 0 {
+		push["logs"] = bson.M{"$each": update.Logs, "$slice": -maxLogLines}
+	}
+
+	collection := config.GetCollection("deployments")
+	var deployment models.Deployment
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": bson.M{"$in": from}},
+		bson.M{"$set": set, "$push": push},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&deployment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		count, countErr := collection.CountDocuments(ctx, bson.M{"_id": id})
+		if countErr != nil {
+			return deployment, countErr
+		}
+		if count == 0 {
+			return deployment, ErrNotFound
+		}
+		return deployment, ErrInvalidTransition
+	}
+	if err != nil {
+		return deployment, err
+	}
+
+	switch deployment.Status {
+	case models.StatusSucceeded:
+		go PublishEvent(EventSucceeded, deployment)
+	case models.StatusFailed:
+		go PublishEvent(EventFailed, deployment)
+	}
+	return deployment, nil
+}
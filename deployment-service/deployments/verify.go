@@ -0,0 +1,165 @@
+package deployments
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/freeze"
+	"deployment-service/health"
+	"deployment-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Succeed records that the deploy target finished a deployment. Canary and
+// blue-green deployments start their rollout first and come back here once
+// it completes, with a fresh deadline. Without a health check the deployment
+// is Succeeded right away; otherwise it moves to Verifying and the health
+// check decides in the background.
+func Succeed(ctx context.Context, deployment models.Deployment, update Update) (models.Deployment, error) {
+	if len(deployment.Spec.Strategy.Steps()) > 0 {
+		if deployment.Rollout == nil {
+			return beginRollout(ctx, deployment, update)
+		}
+		if deployment.Rollout.Phase != models.RolloutCompleted {
+			return deployment, ErrInvalidTransition
+		}
+		// The rollout's pauses may have used up the deadline, which the
+		// reaper would fail the completed deployment by
+		if update.Fields == nil {
+			update.Fields = bson.M{}
+		}
+		update.Fields["deadline_at"] = time.Now().Add(config.App.Deployments.Timeout)
+	}
+
+	update.Status = models.StatusSucceeded
+	if deployment.HealthCheck == nil {
+		return Transition(ctx, deployment.ID, update)
+	}
+
+	update.Status = models.StatusVerifying
+	verifying, err := Transition(ctx, deployment.ID, update)
+	if err != nil {
+		return verifying, err
+	}
+	go Verify(verifying)
+	return verifying, nil
+}
+
+// Verify runs a Verifying deployment's health check and marks it Succeeded
+// or Failed. A failed deployment is rolled back when the health check asks
+// for it, unless it was itself a rollback.
+func Verify(deployment models.Deployment) {
+	if deployment.HealthCheck == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), health.Window(*deployment.HealthCheck)+time.Minute)
+	defer cancel()
+
+	if err := health.Verify(ctx, *deployment.HealthCheck); err != nil {
+		failed, transitionErr := Transition(ctx, deployment.ID, Update{
+			Status:        models.StatusFailed,
+			Source:        "health_check",
+			FailureReason: "health check failed: " + err.Error(),
+		})
+		if transitionErr != nil {
+			if !errors.Is(transitionErr, ErrInvalidTransition) {
+				fmt.Printf("Failed to mark deployment %s as Failed: %v\n", deployment.ID.Hex(), transitionErr)
+			}
+			return
+		}
+		if failed.HealthCheck.AutoRollback && failed.RollbackOf.IsZero() {
+			if err := Rollback(ctx, failed); err != nil {
+				fmt.Printf("Failed to roll back deployment %s: %v\n", deployment.ID.Hex(), err)
+			}
+		}
+		return
+	}
+
+	if _, err := Transition(ctx, deployment.ID, Update{
+		Status:  models.StatusSucceeded,
+		Source:  "health_check",
+		Message: "health checks passed",
+	}); err != nil && !errors.Is(err, ErrInvalidTransition) {
+		fmt.Printf("Failed to mark deployment %s as Succeeded: %v\n", deployment.ID.Hex(), err)
+	}
+}
+
+// Rollback redeploys the spec and config version of the last deployment of
+// the same service to the same environment that succeeded before the failed
+// one. It does nothing if there is none. The rollback starts through Ready,
+// so it waits for other deployments of the service to the environment, but
+// a freeze doesn't keep a failed version running: the rollback breaks glass
+// through any active freeze and records so.
+func Rollback(ctx context.Context, failed models.Deployment) error {
+	collection := config.GetCollection("deployments")
+
+	// A null environment.id also matches deployments without an environment
+	var environmentID interface{}
+	if failed.Environment != nil {
+		environmentID = failed.Environment.ID
+	}
+
+	var previous models.Deployment
+	err := collection.FindOne(ctx,
+		bson.M{
+			"service_id":     failed.ServiceID,
+			"environment.id": environmentID,
+			"status":         models.StatusSucceeded,
+			"created_at":     bson.M{"$lt": failed.CreatedAt},
+		},
+		options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	).Decode(&previous)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		fmt.Printf("No earlier successful deployment to roll back to from %s\n", failed.ID.Hex())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	decision, err := freeze.Check(ctx, failed.TenantID, failed.ServiceID, now)
+	if err != nil {
+		return err
+	}
+	var breakGlass *models.BreakGlass
+	if decision.Frozen {
+		breakGlass = &models.BreakGlass{
+			Actor:   "rollback",
+			Reason:  "automatic rollback of deployment " + failed.ID.Hex(),
+			Freezes: decision.Windows,
+			At:      now,
+		}
+	}
+
+	rollback, err := Create(ctx, models.Deployment{
+		ServiceID:     failed.ServiceID,
+		TenantID:      failed.TenantID,
+		Environment:   failed.Environment,
+		Labels:        failed.Labels,
+		Annotations:   failed.Annotations,
+		Spec:          previous.Spec,
+		ConfigVersion: previous.ConfigVersion,
+		HealthCheck:   failed.HealthCheck,
+		RollbackOf:    failed.ID,
+		BreakGlass:    breakGlass,
+	}, "rollback")
+	if err != nil {
+		return err
+	}
+	if _, err := collection.UpdateOne(ctx,
+		bson.M{"_id": failed.ID},
+		bson.M{"$set": bson.M{"rolled_back_by": rollback.ID}},
+	); err != nil {
+		return err
+	}
+
+	fmt.Printf("Rolling back deployment %s with %s (spec of %s)\n", failed.ID.Hex(), rollback.ID.Hex(), previous.ID.Hex())
+	_, err = Ready(ctx, rollback, "rollback", "rolling back deployment "+failed.ID.Hex())
+	return err
+}
@@ -0,0 +1,107 @@
+package freeze
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "* * * * *"},
+		{expr: "0 22 * * 5"},
+		{expr: "*/15 9-17 1,15 * 1-5"},
+		{expr: "0 0 * * 7"},
+		{expr: "5-55/10 * * 1-12 *"},
+		{expr: "0 0 * *", wantErr: true},
+		{expr: "0 0 * * * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "* 24 * * *", wantErr: true},
+		{expr: "* * 0 * *", wantErr: true},
+		{expr: "* * * 13 *", wantErr: true},
+		{expr: "* * * * 8", wantErr: true},
+		{expr: "5-1 * * * *", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "*/x * * * *", wantErr: true},
+		{expr: "a * * * *", wantErr: true},
+		{expr: "1- * * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := parseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduleMatches(t *testing.T) {
+	// 2026-03-02 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		expr string
+		t    time.Time
+		want bool
+	}{
+		{"every minute", "* * * * *", at(2, 13, 7), true},
+		{"exact time", "30 22 * * *", at(2, 22, 30), true},
+		{"other minute", "30 22 * * *", at(2, 22, 31), false},
+		{"step matches", "*/15 * * * *", at(2, 10, 45), true},
+		{"step misses", "*/15 * * * *", at(2, 10, 50), false},
+		{"range with step", "5-55/10 * * * *", at(2, 10, 25), true},
+		{"range with step misses", "5-55/10 * * * *", at(2, 10, 30), false},
+		{"single value with step runs to the end", "50/5 * * * *", at(2, 10, 55), true},
+		{"list", "0 9,17 * * *", at(2, 17, 0), true},
+		{"month", "0 0 * 4 *", at(2, 0, 0), false},
+		{"sunday as 7", "0 0 * * 7", at(1, 0, 0), true},
+		{"sunday as 0", "0 0 * * 0", at(1, 0, 0), true},
+		{"weekday only", "0 0 * * 1-5", at(1, 0, 0), false},
+		// Both day fields restricted: either matches
+		{"day of month or day of week, by day of month", "0 0 15 * 5", at(15, 0, 0), true},
+		{"day of month or day of week, by day of week", "0 0 15 * 1", at(2, 0, 0), true},
+		{"day of month or day of week, neither", "0 0 15 * 5", at(2, 0, 0), false},
+		// A day field starting with * is unrestricted, so only the other
+		// one decides
+		{"*/1 day of month leaves day of week to decide", "0 0 */1 * 5", at(2, 0, 0), false},
+		{"*/1 day of month on the day of week", "0 0 */1 * 1", at(2, 0, 0), true},
+		{"*/1 day of week leaves day of month to decide", "0 0 15 * */1", at(2, 0, 0), false},
+		{"*/1 day of week on the day of month", "0 0 15 * */1", at(15, 0, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("parseCron(%q): %v", tt.expr, err)
+			}
+			if got := s.matches(tt.t); got != tt.want {
+				t.Fatalf("%q matches %s = %v, want %v", tt.expr, tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleLastFire(t *testing.T) {
+	s, err := parseCron("0 22 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, time.March, 3, 1, 30, 45, 0, time.UTC)
+
+	fired, ok := s.lastFire(now, 4*time.Hour)
+	if !ok {
+		t.Fatal("expected a fire within 4h")
+	}
+	if want := time.Date(2026, time.March, 2, 22, 0, 0, 0, time.UTC); !fired.Equal(want) {
+		t.Fatalf("lastFire = %s, want %s", fired, want)
+	}
+
+	if _, ok := s.lastFire(now, 3*time.Hour); ok {
+		t.Fatal("expected no fire within 3h")
+	}
+}
@@ -0,0 +1,142 @@
+package freeze
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Collection holds the freeze windows
+const Collection = "freeze_windows"
+
+// MaxDuration bounds a recurring freeze, which keeps checking it cheap
+const MaxDuration = 31 * 24 * time.Hour
+
+// Decision is the outcome of checking a deployment against the freezes
+type Decision struct {
+	Frozen bool
+	// Action is reject if any active freeze rejects, else queue
+	Action string
+	// Until is when the last active freeze ends
+	Until   time.Time
+	Windows []models.ActiveFreeze
+}
+
+// Validate checks a freeze window for settings that can't be evaluated
+func Validate(w models.FreezeWindow) error {
+	switch w.Scope {
+	case models.FreezeScopeGlobal:
+	case models.FreezeScopeTenant:
+		if w.TenantID.IsZero() {
+			return errors.New("tenant_id is required for tenant freezes")
+		}
+	case models.FreezeScopeService:
+		if w.ServiceID.IsZero() {
+			return errors.New("service_id is required for service freezes")
+		}
+	default:
+		return fmt.Errorf("scope must be %s, %s or %s", models.FreezeScopeGlobal, models.FreezeScopeTenant, models.FreezeScopeService)
+	}
+	if w.Reason == "" {
+		return errors.New("reason is required")
+	}
+	if w.Action != models.FreezeActionReject && w.Action != models.FreezeActionQueue {
+		return fmt.Errorf("action must be %s or %s", models.FreezeActionReject, models.FreezeActionQueue)
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %v", err)
+	}
+
+	switch {
+	case w.Cron != "" && (w.Start != nil || w.End != nil):
+		return errors.New("give either start and end or cron, not both")
+	case w.Cron != "":
+		if _, err := parseCron(w.Cron); err != nil {
+			return err
+		}
+		if w.DurationMinutes < 1 || time.Duration(w.DurationMinutes)*time.Minute > MaxDuration {
+			return fmt.Errorf("duration_minutes must be between 1 and %d", int(MaxDuration/time.Minute))
+		}
+	case w.Start != nil && w.End != nil:
+		if !w.End.After(*w.Start) {
+			return errors.New("end must be after start")
+		}
+	default:
+		return errors.New("start and end, or cron and duration_minutes, are required")
+	}
+	return nil
+}
+
+// ActiveUntil reports whether a freeze window is in effect at t and when it
+// ends
+func ActiveUntil(w models.FreezeWindow, t time.Time) (time.Time, bool) {
+	if w.Cron == "" {
+		if w.Start == nil || w.End == nil || t.Before(*w.Start) || !t.Before(*w.End) {
+			return time.Time{}, false
+		}
+		return *w.End, true
+	}
+
+	s, err := parseCron(w.Cron)
+	if err != nil {
+		return time.Time{}, false
+	}
+	location, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	duration := time.Duration(w.DurationMinutes) * time.Minute
+	fired, ok := s.lastFire(t.In(location), duration)
+	if !ok {
+		return time.Time{}, false
+	}
+	end := fired.Add(duration)
+	if !end.After(t) {
+		return time.Time{}, false
+	}
+	return end, true
+}
+
+// Check finds the freezes in effect at t for a deployment of a service
+func Check(ctx context.Context, tenantID, serviceID bson.ObjectID, t time.Time) (Decision, error) {
+	scopes := bson.A{bson.M{"scope": models.FreezeScopeGlobal}}
+	if !tenantID.IsZero() {
+		scopes = append(scopes, bson.M{"scope": models.FreezeScopeTenant, "tenant_id": tenantID})
+	}
+	scopes = append(scopes, bson.M{"scope": models.FreezeScopeService, "service_id": serviceID})
+
+	cursor, err := config.GetCollection(Collection).Find(ctx, bson.M{
+		"$or": scopes,
+		// Absolute windows that ended can't apply
+		"$nor": bson.A{bson.M{"end": bson.M{"$lte": t}}},
+	})
+	if err != nil {
+		return Decision{}, err
+	}
+	var windows []models.FreezeWindow
+	if err := cursor.All(ctx, &windows); err != nil {
+		return Decision{}, err
+	}
+
+	decision := Decision{Action: models.FreezeActionQueue}
+	for _, w := range windows {
+		until, ok := ActiveUntil(w, t)
+		if !ok {
+			continue
+		}
+		decision.Frozen = true
+		decision.Windows = append(decision.Windows, models.ActiveFreeze{ID: w.ID, Reason: w.Reason, Action: w.Action, Until: until})
+		if w.Action == models.FreezeActionReject {
+			decision.Action = models.FreezeActionReject
+		}
+		if until.After(decision.Until) {
+			decision.Until = until
+		}
+	}
+	return decision, nil
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"deployment-service/audit"
+	"deployment-service/deployments"
+	"deployment-service/middleware"
+	"deployment-service/models"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// approvalRequest is the optional body of an approval or rejection
+type approvalRequest struct {
+	Comment string `json:"comment"`
+}
+
+// ApproveDeployment records the caller's approval of a deployment that is
+// AwaitingApproval. The deployment starts once enough callers approved.
+func ApproveDeployment(w http.ResponseWriter, r *http.Request) {
+	decideApproval(w, r, "approve", "approved", deployments.Approve)
+}
+
+// RejectDeployment rejects a deployment that is AwaitingApproval, marking it
+// Failed
+func RejectDeployment(w http.ResponseWriter, r *http.Request) {
+	decideApproval(w, r, "reject", "rejected", deployments.Reject)
+}
+
+func decideApproval(w http.ResponseWriter, r *http.Request, action, done string,
+	decide func(context.Context, models.Deployment, models.Approval) (models.Deployment, error)) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request approvalRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok || claims.Identity() == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "authentication required",
+		})
+		return
+	}
+	if claims.Legacy {
+		// A shared-secret token can't tell one caller from another
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "not allowed to " + action,
+			"msg":   "approvals need a token issued by core-service",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deployment, ok := loadDeployment(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	decided, err := decide(ctx, deployment, models.Approval{
+		Actor:   claims.Identity(),
+		Roles:   claims.Roles,
+		Comment: request.Comment,
+	})
+	switch {
+	case errors.Is(err, deployments.ErrSelfApproval), errors.Is(err, deployments.ErrRoleNotAllowed):
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "not allowed to " + action,
+			"msg":   err.Error(),
+		})
+		return
+	case errors.Is(err, deployments.ErrNotAwaitingApproval), errors.Is(err, deployments.ErrApprovalExpired),
+		errors.Is(err, deployments.ErrAlreadyApproved):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "can't " + action + " deployment",
+			"msg":   err.Error(),
+		})
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to " + action + " deployment",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetAction(r, action)
+	audit.SetChange(r, map[string]interface{}{"status": deployment.Status, "approval": deployment.Approval},
+		map[string]interface{}{"status": decided.Status, "approval": decided.Approval})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "deployment " + done,
+		"data":    decided,
+	})
+}
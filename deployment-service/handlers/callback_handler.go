@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"deployment-service/audit"
+	"deployment-service/config"
+	"deployment-service/deployments"
+	"deployment-service/models"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// maxCallbackBody bounds the size of a callback, logs included
+const maxCallbackBody = 1 << 20
+
+// callbackRequest is what the deploy target reports about a deployment
+type callbackRequest struct {
+	Status   models.DeploymentStatus `json:"status"`
+	Progress int                     `json:"progress"`
+	Message  string                  `json:"message"`
+	Logs     []string                `json:"logs"`
+}
+
+// DeploymentCallback receives progress, the final status and logs from the
+// deploy target. Callbacks are signed with the deployment's callback secret
+// and must follow the status order Pending, Running, then Succeeded or Failed.
+// A reported success is verified first when the service has a health check.
+func DeploymentCallback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	deploymentID, err := bson.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid deployment ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackBody+1))
+	if err != nil || len(body) > maxCallbackBody {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "callback body is missing or too large",
+		})
+		return
+	}
+
+	collection := config.GetCollection("deployments")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var deployment models.Deployment
+	if err := collection.FindOne(ctx, bson.M{"_id": deploymentID}).Decode(&deployment); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "deployment not found",
+		})
+		return
+	}
+
+	// Verify before trusting anything in the body
+	if err := deployments.VerifyCallback(deployment.CallbackSecret, r.Header.Get(deployments.CallbackSignatureHeader), body); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid signature",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetActor(r, "deployer:"+deploymentID.Hex(), "callback")
+	audit.SetResource(r, "deployment", deploymentID.Hex())
+	if !deployment.TenantID.IsZero() {
+		audit.SetTenant(r, deployment.TenantID.Hex())
+	}
+
+	var request callbackRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if request.Status != models.StatusRunning && !request.Status.IsFinal() {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "status must be Running, Succeeded or Failed",
+		})
+		return
+	}
+	if request.Progress < 0 || request.Progress > 100 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "progress must be between 0 and 100",
+		})
+		return
+	}
+
+	update := deployments.Update{
+		Status:   request.Status,
+		Source:   "callback",
+		Message:  request.Message,
+		Progress: request.Progress,
+		Logs:     request.Logs,
+	}
+	if request.Status == models.StatusFailed {
+		update.FailureReason = request.Message
+	}
+
+	var updated models.Deployment
+	if request.Status == models.StatusSucceeded {
+		updated, err = deployments.Succeed(ctx, deployment, update)
+	} else {
+		updated, err = deployments.Transition(ctx, deploymentID, update)
+	}
+	if errors.Is(err, deployments.ErrInvalidTransition) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid status transition",
+			"msg":   "deployment is " + string(deployment.Status) + " and can't move to " + string(request.Status),
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to update deployment",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetAction(r, "callback")
+	audit.SetChange(r, map[string]interface{}{"status": deployment.Status, "progress": deployment.Progress},
+		map[string]interface{}{"status": updated.Status, "progress": updated.Progress})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "deployment updated",
+		"data": map[string]interface{}{
+			"id":       updated.ID,
+			"status":   updated.Status,
+			"progress": updated.Progress,
+		},
+	})
+}
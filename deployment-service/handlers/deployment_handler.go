@@ -1,16 +1,23 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
+	"deployment-service/audit"
 	"deployment-service/config"
+	"deployment-service/deployments"
+	"deployment-service/freeze"
+	"deployment-service/middleware"
 	"deployment-service/models"
+	"deployment-service/selector"
 	"deployment-service/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -18,117 +25,172 @@ import (
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
-// validateServiceExists checks if service exists in core-service
-func validateServiceExists(serviceID string) (bool, error) {
-	url := fmt.Sprintf("%s/services/%s", config.CoreServiceURL, serviceID)
+// fetchService looks up a service in core-service, returning nil if it
+// doesn't exist
+func fetchService(serviceID string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/services/%s", config.CoreServiceURL(), serviceID)
 
 	// Generate JWT token for authentication
-	token, err := utils.GenerateServiceToken()
+	token, err := utils.GetServiceToken()
 	if err != nil {
-		return false, fmt.Errorf("failed to generate auth token: %v", err)
+		return nil, fmt.Errorf("failed to generate auth token: %v", err)
 	}
 
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	// Create HTTP client with timeout (mutual TLS when configured)
+	client := utils.NewCoreServiceClient(10 * time.Second)
 
 	// Create request with JWT Bearer token
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return false, fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return false, fmt.Errorf("failed to connect to core service at %s: %v", config.CoreServiceURL, err)
+		return nil, fmt.Errorf("failed to connect to core service at %s: %v", config.CoreServiceURL(), err)
 	}
 	defer resp.Body.Close()
 
 	// Service not found
 	if resp.StatusCode == http.StatusNotFound {
-		return false, nil
+		return nil, nil
 	}
 
 	// Authentication/Authorization errors
 	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
 		body, _ := io.ReadAll(resp.Body)
-		return false, fmt.Errorf("authentication failed with core service: %s", string(body))
+		return nil, fmt.Errorf("authentication failed with core service: %s", string(body))
 	}
 
 	// Unexpected error from core service
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return false, fmt.Errorf("core service returned status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("core service returned status %d: %s", resp.StatusCode, string(body))
 	}
 
 	// Optionally validate response body to ensure it's a valid service
 	var serviceResponse map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&serviceResponse); err != nil {
-		return false, fmt.Errorf("failed to decode core service response: %v", err)
+		return nil, fmt.Errorf("failed to decode core service response: %v", err)
 	}
 
 	// Verify the service has required fields
 	if _, ok := serviceResponse["id"]; !ok {
-		return false, fmt.Errorf("invalid service response: missing id field")
+		return nil, fmt.Errorf("invalid service response: missing id field")
 	}
 
-	return true, nil
+	return serviceResponse, nil
 }
 
-// callExternalAPI calls the external deployment API
-func callExternalAPI(deployment models.Deployment) error {
-
-	url := "https://jsonplaceholder.typicode.com/posts"
-
-	payload := map[string]interface{}{
-		"deployment_id": deployment.ID.Hex(),
-		"service_id":    deployment.ServiceID.Hex(),
-		"status":        deployment.Status,
-		"timestamp":     time.Now().Unix(),
+// fetchConfigVersion checks that a version of a service's config exists in
+// core-service and returns it, or the latest version if version is zero. A
+// service without config has version 0. It returns false if the version
+// doesn't exist.
+func fetchConfigVersion(serviceID string, version int) (int, bool, error) {
+	endpoint := fmt.Sprintf("%s/services/%s/config", config.CoreServiceURL(), serviceID)
+	if version > 0 {
+		endpoint += "?version=" + strconv.Itoa(version)
 	}
 
-	jsonData, err := json.Marshal(payload)
+	token, err := utils.GetServiceToken()
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %v", err)
+		return 0, false, fmt.Errorf("failed to generate auth token: %v", err)
 	}
 
-	// Create client with timeout
-	client := &http.Client{
-		Timeout: 15 * time.Second,
+	client := utils.NewCoreServiceClient(10 * time.Second)
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create request: %v", err)
 	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 
-	resp, err := client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to call external API: %v", err)
+		return 0, false, fmt.Errorf("failed to connect to core service at %s: %v", config.CoreServiceURL(), err)
 	}
 	defer resp.Body.Close()
 
-	// Accept both 200 and 201 status codes
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("external API returned status %d: %s", resp.StatusCode, string(body))
+		return 0, false, fmt.Errorf("core service returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	return nil
+	var configResponse struct {
+		Data struct {
+			Version int `json:"version"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&configResponse); err != nil {
+		return 0, false, fmt.Errorf("failed to decode core service response: %v", err)
+	}
+	return configResponse.Data.Version, true, nil
 }
 
-// updateDeploymentStatus updates the deployment status in the database
-func updateDeploymentStatus(deploymentID bson.ObjectID, status models.DeploymentStatus) error {
-	collection := config.GetCollection("deployments")
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// serviceHealthCheck decodes the health check of a service fetched from
+// core-service, returning nil if it has none
+func serviceHealthCheck(service map[string]interface{}) (*models.HealthCheck, error) {
+	raw, ok := service["health_check"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var check models.HealthCheck
+	if err := json.Unmarshal(data, &check); err != nil {
+		return nil, fmt.Errorf("invalid health check: %v", err)
+	}
+	if len(check.Probes) == 0 {
+		return nil, nil
+	}
+	return &check, nil
+}
+
+// breakGlassScope lets a caller deploy through active freezes
+const breakGlassScope = "deployments:break-glass"
 
-	update := bson.M{
-		"$set": bson.M{
-			"status":     status,
-			"updated_at": time.Now(),
-		},
+// serviceApprovalPolicy decodes the approval policy of a service fetched from
+// core-service, returning nil if it has none
+func serviceApprovalPolicy(service map[string]interface{}) (*models.ApprovalPolicy, error) {
+	raw, ok := service["approval_policy"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
 	}
+	var policy models.ApprovalPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid approval policy: %v", err)
+	}
+	if policy.RequiredApprovals < 1 {
+		return nil, nil
+	}
+	return &policy, nil
+}
 
-	_, err := collection.UpdateOne(ctx, bson.M{"_id": deploymentID}, update)
-	return err
+// callerClaims returns the claims of the caller's Bearer token, if it sent a
+// valid one
+func callerClaims(r *http.Request) (*utils.Claims, bool) {
+	if claims, ok := middleware.ClaimsFromContext(r.Context()); ok {
+		return claims, true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, false
+	}
+	claims, err := utils.ParseServiceToken(token)
+	if err != nil || claims.Identity() == "" {
+		return nil, false
+	}
+	return claims, true
 }
 
 // CreateDeployment creates a new deployment
@@ -149,8 +211,68 @@ func CreateDeployment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The spec is optional; a deployment without one redeploys whatever the
+	// deploy target considers current
+	var req struct {
+		models.DeploymentSpec
+		Environment   string            `json:"environment"`
+		ConfigVersion int               `json:"config_version"`
+		ScheduledAt   *time.Time        `json:"scheduled_at"`
+		Labels        map[string]string `json:"labels"`
+		Annotations   map[string]string `json:"annotations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	spec := req.DeploymentSpec
+
+	if req.ScheduledAt != nil && !req.ScheduledAt.After(time.Now()) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "scheduled_at must be in the future",
+		})
+		return
+	}
+
+	err = selector.ValidateLabels(req.Labels)
+	if err == nil {
+		err = selector.ValidateAnnotations(req.Annotations)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	if req.ConfigVersion < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "config_version must not be negative",
+		})
+		return
+	}
+
+	if err := spec.Strategy.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid deployment strategy",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
 	// Validate service exists in core-service
-	exists, err := validateServiceExists(serviceID)
+	service, err := fetchService(serviceID)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -160,7 +282,7 @@ func CreateDeployment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !exists {
+	if service == nil {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"error": "service not found",
@@ -168,20 +290,65 @@ func CreateDeployment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create deployment with Pending status
-	now := time.Now()
-	deployment := models.Deployment{
-		ServiceID: serviceObjectID,
-		Status:    models.StatusPending,
-		CreatedAt: now,
-		UpdatedAt: now,
+	var environment *models.Environment
+	if req.Environment != "" {
+		projectID, _ := service["project_id"].(string)
+		environments, err := fetchEnvironments(projectID, req.Environment)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "failed to validate environment",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		if len(environments) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "environment not found",
+				"msg":   "the service's project has no environment named " + req.Environment,
+			})
+			return
+		}
+		environment = &environments[0]
 	}
 
-	collection := config.GetCollection("deployments")
+	createDeployment(w, r, service, models.Deployment{
+		ServiceID:     serviceObjectID,
+		Environment:   environment,
+		Spec:          spec,
+		ConfigVersion: req.ConfigVersion,
+		ScheduledAt:   req.ScheduledAt,
+		Labels:        req.Labels,
+		Annotations:   req.Annotations,
+	})
+}
+
+// requestError is a request check that failed, with the response to send
+type requestError struct {
+	status int
+	body   map[string]interface{}
+}
+
+func (e *requestError) write(w http.ResponseWriter) {
+	w.WriteHeader(e.status)
+	json.NewEncoder(w).Encode(e.body)
+}
+
+// createDeployment creates a deployment of a service fetched from
+// core-service and writes the response. It starts the deployment unless it
+// has to wait.
+func createDeployment(w http.ResponseWriter, r *http.Request, service map[string]interface{}, deployment models.Deployment) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := collection.InsertOne(ctx, deployment)
+	if failed := prepareDeployment(ctx, r, service, &deployment); failed != nil {
+		failed.write(w)
+		return
+	}
+
+	var err error
+	deployment, err = deployments.Create(ctx, deployment, "api")
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -190,41 +357,173 @@ func CreateDeployment(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	insertedID := deployment.ID
 
-	// Get the inserted deployment ID
-	insertedID := result.InsertedID.(bson.ObjectID)
-	deployment.ID = insertedID
-
-	// Process deployment asynchronously
-	go func() {
-		// Call external API
-		if err := callExternalAPI(deployment); err != nil {
-			// Mark as Failed
-			updateDeploymentStatus(insertedID, models.StatusFailed)
-			fmt.Printf("Deployment %s failed: %v\n", insertedID.Hex(), err)
-			return
-		}
-
-		// Mark as Running
-		if err := updateDeploymentStatus(insertedID, models.StatusRunning); err != nil {
-			fmt.Printf("Failed to update deployment %s to Running: %v\n", insertedID.Hex(), err)
+	audit.SetResource(r, "deployment", insertedID.Hex())
+	if !deployment.TenantID.IsZero() {
+		audit.SetTenant(r, deployment.TenantID.Hex())
+	}
+	audit.SetChange(r, nil, deployment)
+
+	// Start the deployment unless something holds it; deployments awaiting
+	// approval are passed to Ready once approved
+	if deployment.Status == models.StatusQueued {
+		ready, err := deployments.Ready(ctx, deployment, "api", "created")
+		if err != nil {
+			log.Printf("Failed to start deployment %s, the scheduler will retry: %v", insertedID.Hex(), err)
 		} else {
-			fmt.Printf("Deployment %s is now Running\n", insertedID.Hex())
+			deployment = ready
 		}
-	}()
+	}
+
+	data := map[string]interface{}{
+		"id":             insertedID,
+		"service_id":     deployment.ServiceID,
+		"status":         deployment.Status,
+		"spec":           deployment.Spec,
+		"config_version": deployment.ConfigVersion,
+		"scheduled_at":   deployment.ScheduledAt,
+		"queue":          deployment.Queue,
+		"labels":         deployment.Labels,
+	}
+	if deployment.Environment != nil {
+		data["environment"] = deployment.Environment.Name
+	}
+	if !deployment.PromotedFrom.IsZero() {
+		data["promoted_from"] = deployment.PromotedFrom
+	}
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message": "deployment created successfully",
-		"data": map[string]interface{}{
-			"id":         insertedID,
-			"service_id": serviceObjectID,
-			"status":     models.StatusPending,
-		},
+		"data":    data,
 	})
 }
 
-// GetDeploymentsByServiceID retrieves all deployments for a service
+// prepareDeployment checks a new deployment of a service fetched from
+// core-service and fills in what it is created with. It pins the config
+// version, the latest unless one is given, applies the service's health
+// check and the approval gates and freeze windows of the service and its
+// target environment.
+func prepareDeployment(ctx context.Context, r *http.Request, service map[string]interface{}, deployment *models.Deployment) *requestError {
+	configVersion, found, err := fetchConfigVersion(deployment.ServiceID.Hex(), deployment.ConfigVersion)
+	if err != nil {
+		return &requestError{http.StatusInternalServerError, map[string]interface{}{
+			"error": "failed to fetch service config",
+			"msg":   err.Error(),
+		}}
+	}
+	if !found {
+		return &requestError{http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   fmt.Sprintf("config version %d doesn't exist", deployment.ConfigVersion),
+		}}
+	}
+	deployment.ConfigVersion = configVersion
+
+	// Snapshot the service's health check so later edits don't change how
+	// this deployment is verified
+	healthCheck, err := serviceHealthCheck(service)
+	if err != nil {
+		return &requestError{http.StatusInternalServerError, map[string]interface{}{
+			"error": "failed to validate service",
+			"msg":   err.Error(),
+		}}
+	}
+	deployment.HealthCheck = healthCheck
+
+	approvalPolicy, err := serviceApprovalPolicy(service)
+	if err != nil {
+		return &requestError{http.StatusInternalServerError, map[string]interface{}{
+			"error": "failed to validate service",
+			"msg":   err.Error(),
+		}}
+	}
+	// The environment's approval policy takes precedence over the
+	// service's, and protected environments always need approval
+	if environment := deployment.Environment; environment != nil {
+		if environment.ApprovalPolicy != nil {
+			approvalPolicy = environment.ApprovalPolicy
+		} else if environment.Protected && approvalPolicy == nil {
+			approvalPolicy = &models.ApprovalPolicy{RequiredApprovals: 1}
+		}
+	}
+
+	// Record who triggered the deployment. Deployments that need approval
+	// must be triggered by a known caller, who then can't approve them.
+	if claims, ok := callerClaims(r); ok {
+		deployment.TriggeredBy = claims.Identity()
+	}
+	if approvalPolicy != nil {
+		if deployment.TriggeredBy == "" {
+			return &requestError{http.StatusUnauthorized, map[string]interface{}{
+				"error": "authentication required",
+				"msg":   "deployments of this service need approval and must be triggered with a Bearer token",
+			}}
+		}
+		deployment.Approval = &models.ApprovalState{Policy: *approvalPolicy}
+	}
+	if tenantID, ok := service["tenant_id"].(string); ok {
+		deployment.TenantID, _ = bson.ObjectIDFromHex(tenantID)
+	}
+
+	// Scheduled deployments are checked against the freezes at their
+	// scheduled time, and again when it comes
+	checkAt := time.Now()
+	if deployment.ScheduledAt != nil {
+		checkAt = *deployment.ScheduledAt
+	}
+	decision, err := freeze.Check(ctx, deployment.TenantID, deployment.ServiceID, checkAt)
+	if err != nil {
+		return &requestError{http.StatusInternalServerError, map[string]interface{}{
+			"error": "failed to check freeze windows",
+			"msg":   err.Error(),
+		}}
+	}
+	if r.URL.Query().Get("break_glass") == "true" {
+		// Break-glass deploys go through any freeze, by callers with the
+		// elevated scope and a reason, and are audited as such
+		claims, ok := callerClaims(r)
+		if !ok || claims.Legacy || !claims.HasScope(breakGlassScope) {
+			return &requestError{http.StatusForbidden, map[string]interface{}{
+				"error": "insufficient scope",
+				"msg":   "break-glass deployments require a token with scope " + breakGlassScope,
+			}}
+		}
+		reason := r.URL.Query().Get("break_glass_reason")
+		if reason == "" {
+			return &requestError{http.StatusBadRequest, map[string]interface{}{
+				"error": "invalid request",
+				"msg":   "break_glass_reason is required",
+			}}
+		}
+		deployment.BreakGlass = &models.BreakGlass{
+			Actor:   claims.Identity(),
+			Reason:  reason,
+			Freezes: decision.Windows,
+			At:      time.Now(),
+		}
+		audit.SetAction(r, "break_glass")
+	} else if decision.Frozen {
+		if decision.Action == models.FreezeActionReject {
+			return &requestError{http.StatusConflict, map[string]interface{}{
+				"error":   "deployments are frozen",
+				"msg":     "a freeze is in effect until " + decision.Until.Format(time.RFC3339),
+				"freezes": decision.Windows,
+			}}
+		}
+		// Deployments awaiting approval or scheduled check freezes again
+		// once approved or due
+		if deployment.Approval == nil && deployment.ScheduledAt == nil {
+			deployment.Queue = &models.QueueState{Until: decision.Until, Freezes: decision.Windows}
+		}
+	}
+
+	return nil
+}
+
+// GetDeploymentsByServiceID retrieves all deployments for a service. Supports
+// an environment filter by name and a label selector.
 func GetDeploymentsByServiceID(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -259,6 +558,12 @@ func GetDeploymentsByServiceID(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	filter := bson.M{"service_id": serviceObjectID}
+	if environment := r.URL.Query().Get("environment"); environment != "" {
+		filter["environment.name"] = environment
+	}
+	if !withSelector(w, r, filter) {
+		return
+	}
 
 	totalCount, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
@@ -311,3 +616,35 @@ func GetDeploymentsByServiceID(w http.ResponseWriter, r *http.Request) {
 		"total_pages": totalPages,
 	})
 }
+
+// GetDeploymentByID returns a deployment with its timeline and logs
+func GetDeploymentByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	deploymentID, err := bson.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid deployment ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	collection := config.GetCollection("deployments")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var deployment models.Deployment
+	if err := collection.FindOne(ctx, bson.M{"_id": deploymentID}).Decode(&deployment); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "deployment not found",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(deployment)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/models"
+	"deployment-service/utils"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// fetchEnvironments lists a project's environments in core-service in
+// order, only the one with the given name if name isn't empty
+func fetchEnvironments(projectID, name string) ([]models.Environment, error) {
+	query := url.Values{"limit": {"100"}}
+	if name != "" {
+		query.Set("name", name)
+	}
+	endpoint := fmt.Sprintf("%s/projects/%s/environments?%s", config.CoreServiceURL(), url.PathEscape(projectID), query.Encode())
+
+	token, err := utils.GetServiceToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate auth token: %v", err)
+	}
+
+	client := utils.NewCoreServiceClient(10 * time.Second)
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to core service at %s: %v", config.CoreServiceURL(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("core service returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var environmentsResponse struct {
+		Data []models.Environment `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&environmentsResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode core service response: %v", err)
+	}
+	return environmentsResponse.Data, nil
+}
+
+// GetServiceEnvironments returns the version of a service currently running
+// in each environment of its project: that of its latest deployment to the
+// environment that succeeded. Environments it was never deployed to have
+// no current version.
+func GetServiceEnvironments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	serviceID := params["serviceId"]
+
+	serviceObjectID, err := bson.ObjectIDFromHex(serviceID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid service ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	service, err := fetchService(serviceID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to validate service",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if service == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "service not found",
+		})
+		return
+	}
+
+	projectID, _ := service["project_id"].(string)
+	environments, err := fetchEnvironments(projectID, "")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch environments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	environmentIDs := make([]bson.ObjectID, 0, len(environments))
+	for _, environment := range environments {
+		environmentIDs = append(environmentIDs, environment.ID)
+	}
+
+	collection := config.GetCollection("deployments")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// The latest successful deployment to each environment
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"service_id":     serviceObjectID,
+			"environment.id": bson.M{"$in": environmentIDs},
+			"status":         models.StatusSucceeded,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "updated_at", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":        "$environment.id",
+			"deployment": bson.M{"$first": "$$ROOT"},
+		}}},
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch deployments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var latest []struct {
+		EnvironmentID bson.ObjectID     `bson:"_id"`
+		Deployment    models.Deployment `bson:"deployment"`
+	}
+	if err := cursor.All(ctx, &latest); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode deployments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	current := make(map[bson.ObjectID]models.Deployment, len(latest))
+	for _, entry := range latest {
+		current[entry.EnvironmentID] = entry.Deployment
+	}
+
+	data := make([]map[string]interface{}, 0, len(environments))
+	for _, environment := range environments {
+		entry := map[string]interface{}{
+			"environment_id": environment.ID,
+			"environment":    environment.Name,
+			"order":          environment.Order,
+			"protected":      environment.Protected,
+			"current":        nil,
+		}
+		if deployment, ok := current[environment.ID]; ok {
+			entry["current"] = map[string]interface{}{
+				"deployment_id":  deployment.ID,
+				"spec":           deployment.Spec,
+				"config_version": deployment.ConfigVersion,
+				"triggered_by":   deployment.TriggeredBy,
+				"deployed_at":    deployment.UpdatedAt,
+			}
+		}
+		data = append(data, entry)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": data,
+	})
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"deployment-service/audit"
+	"deployment-service/config"
+	"deployment-service/freeze"
+	"deployment-service/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// CreateFreeze adds a freeze window
+func CreateFreeze(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var window models.FreezeWindow
+	if err := json.NewDecoder(r.Body).Decode(&window); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if window.Action == "" {
+		window.Action = models.FreezeActionReject
+	}
+	if err := freeze.Validate(window); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid freeze window",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	window.ID = bson.ObjectID{}
+	window.CreatedAt = now
+	window.UpdatedAt = now
+	if claims, ok := callerClaims(r); ok {
+		window.CreatedBy = claims.Identity()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := config.GetCollection(freeze.Collection).InsertOne(ctx, window)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to create freeze window",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	window.ID = result.InsertedID.(bson.ObjectID)
+
+	audit.SetResource(r, "freeze_window", window.ID.Hex())
+	if !window.TenantID.IsZero() {
+		audit.SetTenant(r, window.TenantID.Hex())
+	}
+	audit.SetChange(r, nil, window)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "freeze window created successfully",
+		"data":    window,
+	})
+}
+
+// GetFreezes lists freeze windows, optionally filtered by scope, tenant_id
+// and service_id. With active=true only windows in effect now are listed.
+func GetFreezes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	filter := bson.M{}
+	if scope := query.Get("scope"); scope != "" {
+		filter["scope"] = scope
+	}
+	for param, field := range map[string]string{"tenant_id": "tenant_id", "service_id": "service_id"} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		id, err := bson.ObjectIDFromHex(value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid " + param + " format",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		filter[field] = id
+	}
+
+	collection := config.GetCollection(freeze.Collection)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	active := query.Get("active") == "true"
+	if !active {
+		findOptions.SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
+	}
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch freeze windows",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var windows []models.FreezeWindow
+	if err := cursor.All(ctx, &windows); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode freeze windows",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	var totalCount int64
+	if active {
+		// Whether a recurring window is in effect can't be queried, so
+		// filter and paginate here
+		now := time.Now()
+		var inEffect []models.FreezeWindow
+		for _, window := range windows {
+			if _, ok := freeze.ActiveUntil(window, now); ok {
+				inEffect = append(inEffect, window)
+			}
+		}
+		totalCount = int64(len(inEffect))
+		start := min((page-1)*limit, len(inEffect))
+		windows = inEffect[start:min(start+limit, len(inEffect))]
+	} else if totalCount, err = collection.CountDocuments(ctx, filter); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to count freeze windows",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	if windows == nil {
+		windows = []models.FreezeWindow{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        windows,
+		"page":        page,
+		"limit":       limit,
+		"total_count": totalCount,
+		"total_pages": (int(totalCount) + limit - 1) / limit,
+	})
+}
+
+// UpdateFreeze replaces a freeze window
+func UpdateFreeze(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	windowID, err := bson.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid freeze window ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	var update models.FreezeWindow
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if update.Action == "" {
+		update.Action = models.FreezeActionReject
+	}
+	if err := freeze.Validate(update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid freeze window",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	collection := config.GetCollection(freeze.Collection)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before models.FreezeWindow
+	if err := collection.FindOne(ctx, bson.M{"_id": windowID}).Decode(&before); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "freeze window not found",
+		})
+		return
+	}
+
+	update.ID = windowID
+	update.CreatedBy = before.CreatedBy
+	update.CreatedAt = before.CreatedAt
+	update.UpdatedAt = time.Now()
+	if _, err := collection.ReplaceOne(ctx, bson.M{"_id": windowID}, update); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to update freeze window",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	audit.SetResource(r, "freeze_window", windowID.Hex())
+	if !update.TenantID.IsZero() {
+		audit.SetTenant(r, update.TenantID.Hex())
+	}
+	audit.SetChange(r, before, update)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "freeze window updated successfully",
+		"data":    update,
+	})
+}
+
+// DeleteFreeze removes a freeze window. Deployments it queued are released
+// once no other freeze holds them.
+func DeleteFreeze(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	windowID, err := bson.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid freeze window ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before models.FreezeWindow
+	err = config.GetCollection(freeze.Collection).FindOneAndDelete(ctx, bson.M{"_id": windowID}).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "freeze window not found",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to delete freeze window",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	// Let the scheduler check deployments this window held back
+	if _, err := config.GetCollection("deployments").UpdateMany(ctx,
+		bson.M{"status": models.StatusQueued, "queue.freezes.id": windowID},
+		bson.M{"$set": bson.M{"queue.until": time.Now()}},
+	); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to release queued deployments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	audit.SetResource(r, "freeze_window", windowID.Hex())
+	if !before.TenantID.IsZero() {
+		audit.SetTenant(r, before.TenantID.Hex())
+	}
+	audit.SetChange(r, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "freeze window deleted successfully",
+	})
+}
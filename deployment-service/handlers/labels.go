@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"deployment-service/selector"
+	"encoding/json"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// withSelector narrows filter to the deployments matching the label selector
+// in the request's selector parameter, and writes a 400 response if it
+// can't be parsed
+func withSelector(w http.ResponseWriter, r *http.Request, filter bson.M) bool {
+	labelFilter, err := selector.Parse(r.URL.Query().Get("selector"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid selector",
+			"msg":   err.Error(),
+		})
+		return false
+	}
+	for key, value := range labelFilter {
+		filter[key] = value
+	}
+	return true
+}
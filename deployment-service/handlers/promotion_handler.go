@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"deployment-service/audit"
+	"deployment-service/config"
+	"deployment-service/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// PromoteService deploys the version currently running in one environment
+// of a service to another, given as {"from": "staging", "to": "prod"}. The
+// spec of the latest successful deployment to from is copied as is, and the
+// new deployment goes through the approval gates and freeze windows of to
+// like any other.
+func PromoteService(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := mux.Vars(r)
+	serviceID := params["serviceId"]
+
+	serviceObjectID, err := bson.ObjectIDFromHex(serviceID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid service ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	var request struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if request.From == "" || request.To == "" || request.From == request.To {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "from and to must name two different environments",
+		})
+		return
+	}
+
+	service, err := fetchService(serviceID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to validate service",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if service == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "service not found",
+		})
+		return
+	}
+
+	projectID, _ := service["project_id"].(string)
+	environments, err := fetchEnvironments(projectID, "")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch environments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	var from, to *models.Environment
+	for i := range environments {
+		switch environments[i].Name {
+		case request.From:
+			from = &environments[i]
+		case request.To:
+			to = &environments[i]
+		}
+	}
+	for name, environment := range map[string]*models.Environment{request.From: from, request.To: to} {
+		if environment == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "environment not found",
+				"msg":   "the service's project has no environment named " + name,
+			})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var source models.Deployment
+	err = config.GetCollection("deployments").FindOne(ctx,
+		bson.M{
+			"service_id":     serviceObjectID,
+			"environment.id": from.ID,
+			"status":         models.StatusSucceeded,
+		},
+		options.FindOne().SetSort(bson.D{{Key: "updated_at", Value: -1}}),
+	).Decode(&source)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "nothing to promote",
+			"msg":   "the service has no successful deployment to " + request.From,
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch deployments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	audit.SetAction(r, "promote")
+	createDeployment(w, r, service, models.Deployment{
+		ServiceID:    serviceObjectID,
+		Environment:  to,
+		Spec:         source.Spec,
+		Labels:       source.Labels,
+		Annotations:  source.Annotations,
+		PromotedFrom: source.ID,
+	})
+}
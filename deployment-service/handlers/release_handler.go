@@ -0,0 +1,1011 @@
+package handlers
+
+import (
+	"context"
+	"deployment-service/audit"
+	"deployment-service/config"
+	"deployment-service/deployments"
+	"deployment-service/models"
+	"deployment-service/releases"
+	"deployment-service/selector"
+	"deployment-service/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// fetchDeployOrder looks up a project's services in core-service, in waves
+// of services that only depend on services of earlier waves. It returns
+// nil if the project doesn't exist.
+func fetchDeployOrder(projectID string) ([][]map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("%s/projects/%s/deploy-order", config.CoreServiceURL(), url.PathEscape(projectID))
+
+	token, err := utils.GetServiceToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate auth token: %v", err)
+	}
+
+	client := utils.NewCoreServiceClient(10 * time.Second)
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to core service at %s: %v", config.CoreServiceURL(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("core service returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var orderResponse struct {
+		Waves [][]map[string]interface{} `json:"waves"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&orderResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode core service response: %v", err)
+	}
+	if orderResponse.Waves == nil {
+		orderResponse.Waves = [][]map[string]interface{}{}
+	}
+	return orderResponse.Waves, nil
+}
+
+// CreateProjectDeployment deploys several services of a project as one
+// release: the listed services, or all of the project's services at the
+// given version. Each service gets a deployment that goes through the
+// usual approval gates and freeze checks and is held until the release
+// lets it go, after the services it depends on.
+func CreateProjectDeployment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	projectID := mux.Vars(r)["projectId"]
+	projectObjectID, err := bson.ObjectIDFromHex(projectID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid project ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	// Services without a version of their own are deployed at the
+	// release's version
+	var req struct {
+		Name        string `json:"name"`
+		Notes       string `json:"notes"`
+		Environment string `json:"environment"`
+		Version     string `json:"version"`
+		Services    []struct {
+			models.DeploymentSpec
+			ServiceID     bson.ObjectID `json:"service_id"`
+			ConfigVersion int           `json:"config_version"`
+		} `json:"services"`
+		MaxParallel *int              `json:"max_parallel"`
+		OnFailure   string            `json:"on_failure"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	invalid := func(msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   msg,
+		})
+	}
+	if len(req.Services) == 0 && req.Version == "" {
+		invalid("services or a version to deploy all services at is required")
+		return
+	}
+	if req.Name != "" {
+		if err := validateReleaseName(req.Name); err != nil {
+			invalid(err.Error())
+			return
+		}
+	}
+	maxParallel := 1
+	if req.MaxParallel != nil {
+		maxParallel = *req.MaxParallel
+	}
+	if maxParallel < 0 {
+		invalid("max_parallel must not be negative")
+		return
+	}
+	if req.OnFailure == "" {
+		req.OnFailure = models.OnFailureStop
+	}
+	if req.OnFailure != models.OnFailureStop && req.OnFailure != models.OnFailureContinue {
+		invalid("on_failure must be stop or continue")
+		return
+	}
+	err = selector.ValidateLabels(req.Labels)
+	if err == nil {
+		err = selector.ValidateAnnotations(req.Annotations)
+	}
+	if err != nil {
+		invalid(err.Error())
+		return
+	}
+
+	listed := map[bson.ObjectID]int{}
+	for i, service := range req.Services {
+		if service.ServiceID.IsZero() {
+			invalid("service_id is required for every service")
+			return
+		}
+		if _, ok := listed[service.ServiceID]; ok {
+			invalid("service " + service.ServiceID.Hex() + " is listed more than once")
+			return
+		}
+		listed[service.ServiceID] = i
+		if service.ConfigVersion < 0 {
+			invalid("config_version must not be negative")
+			return
+		}
+		if err := service.Strategy.Validate(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":      "invalid deployment strategy",
+				"msg":        err.Error(),
+				"service_id": service.ServiceID,
+			})
+			return
+		}
+	}
+
+	waves, err := fetchDeployOrder(projectID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch deploy order",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if waves == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "project not found",
+		})
+		return
+	}
+
+	var environment *models.Environment
+	if req.Environment != "" {
+		environments, err := fetchEnvironments(projectID, req.Environment)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "failed to validate environment",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		if len(environments) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "environment not found",
+				"msg":   "the project has no environment named " + req.Environment,
+			})
+			return
+		}
+		environment = &environments[0]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if req.Name != "" {
+		taken, err := config.GetCollection("releases").CountDocuments(ctx, bson.M{"project_id": projectObjectID, "name": req.Name})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "failed to check release name",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		if taken > 0 {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "a release with this name already exists in the project",
+			})
+			return
+		}
+	}
+
+	// Check every deployment before creating any, in deploy order
+	release := models.Release{
+		ID:          bson.NewObjectID(),
+		ProjectID:   projectObjectID,
+		Name:        req.Name,
+		Notes:       req.Notes,
+		Environment: environment,
+		Status:      models.ReleaseRunning,
+		MaxParallel: maxParallel,
+		OnFailure:   req.OnFailure,
+		Items:       []models.ReleaseItem{},
+	}
+	var children []models.Deployment
+	found := 0
+	for wave, services := range waves {
+		for _, service := range services {
+			id, _ := service["id"].(string)
+			serviceID, err := bson.ObjectIDFromHex(id)
+			if err != nil {
+				continue
+			}
+			deployment := models.Deployment{
+				ServiceID:   serviceID,
+				Environment: environment,
+				Spec:        models.DeploymentSpec{Version: req.Version},
+				ReleaseID:   release.ID,
+				Held:        true,
+				Labels:      req.Labels,
+				Annotations: req.Annotations,
+			}
+			if len(req.Services) > 0 {
+				i, ok := listed[serviceID]
+				if !ok {
+					continue
+				}
+				found++
+				deployment.Spec = req.Services[i].DeploymentSpec
+				deployment.ConfigVersion = req.Services[i].ConfigVersion
+				if deployment.Spec.Version == "" {
+					deployment.Spec.Version = req.Version
+				}
+			}
+			if failed := prepareDeployment(ctx, r, service, &deployment); failed != nil {
+				failed.body["service_id"] = serviceID
+				failed.write(w)
+				return
+			}
+
+			name, _ := service["name"].(string)
+			release.Items = append(release.Items, models.ReleaseItem{
+				ServiceID:   serviceID,
+				ServiceName: name,
+				Version:     deployment.Spec.Version,
+				CommitSHA:   deployment.Spec.CommitSHA,
+				Wave:        wave,
+			})
+			children = append(children, deployment)
+			release.TenantID = deployment.TenantID
+			if deployment.TriggeredBy != "" {
+				release.TriggeredBy = deployment.TriggeredBy
+			}
+		}
+	}
+	if found < len(req.Services) {
+		for _, service := range req.Services {
+			if !containsService(release.Items, service.ServiceID) {
+				invalid("service " + service.ServiceID.Hex() + " doesn't belong to the project")
+				return
+			}
+		}
+	}
+	if len(children) == 0 {
+		invalid("the project has no services to deploy")
+		return
+	}
+
+	for i, child := range children {
+		created, err := deployments.Create(ctx, child, "release")
+		if err != nil {
+			cancelReleaseDeployments(ctx, release.Items[:i])
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "failed to create deployment",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		release.Items[i].DeploymentID = created.ID
+		release.Items[i].Status = created.Status
+	}
+	releases.Summarize(&release)
+	release.CreatedAt = time.Now()
+	release.UpdatedAt = release.CreatedAt
+
+	err = releases.Changelog(ctx, &release)
+	if err == nil {
+		_, err = config.GetCollection("releases").InsertOne(ctx, release)
+	}
+	if err != nil {
+		cancelReleaseDeployments(ctx, release.Items)
+		status, msg := http.StatusInternalServerError, "failed to create release"
+		if mongo.IsDuplicateKeyError(err) {
+			status, msg = http.StatusConflict, "a release with this name already exists in the project"
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": msg,
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	audit.SetResource(r, "release", release.ID.Hex())
+	if !release.TenantID.IsZero() {
+		audit.SetTenant(r, release.TenantID.Hex())
+	}
+	audit.SetChange(r, nil, release)
+
+	// Let go the first wave right away rather than on the next tick
+	go func() {
+		if err := releases.Advance(context.Background(), release.ID); err != nil {
+			log.Printf("Failed to advance release %s: %v", release.ID.Hex(), err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "release created successfully",
+		"data":    release,
+	})
+}
+
+// cancelReleaseDeployments fails the held deployments of a release that
+// couldn't be created, so none are left behind
+func cancelReleaseDeployments(ctx context.Context, items []models.ReleaseItem) {
+	for _, item := range items {
+		deployments.Transition(ctx, item.DeploymentID, deployments.Update{
+			Status:        models.StatusFailed,
+			Source:        "release",
+			From:          []models.DeploymentStatus{models.StatusWaiting, models.StatusAwaitingApproval},
+			FailureReason: "canceled: release couldn't be created",
+		})
+	}
+}
+
+func containsService(items []models.ReleaseItem, serviceID bson.ObjectID) bool {
+	for _, item := range items {
+		if item.ServiceID == serviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetProjectReleases lists a project's releases, newest first. Supports
+// name and status filters.
+func GetProjectReleases(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	projectObjectID, err := bson.ObjectIDFromHex(mux.Vars(r)["projectId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid project ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10 // Default limit
+	}
+
+	skip := (page - 1) * limit
+
+	filter := bson.M{"project_id": projectObjectID}
+	for _, field := range []string{"name", "status"} {
+		if value := r.URL.Query().Get(field); value != "" {
+			filter[field] = value
+		}
+	}
+
+	collection := config.GetCollection("releases")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to count releases",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64(skip))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}}) // Sort by newest first
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch releases",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var found []models.Release
+	if err := cursor.All(ctx, &found); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode releases",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	if found == nil {
+		found = []models.Release{}
+	}
+
+	totalPages := (int(totalCount) + limit - 1) / limit
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        found,
+		"page":        page,
+		"limit":       limit,
+		"total_count": totalCount,
+		"total_pages": totalPages,
+	})
+}
+
+// GetRelease returns a release with its deployments in deploy order
+func GetRelease(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	releaseID, err := bson.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid release ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var release models.Release
+	if err := config.GetCollection("releases").FindOne(ctx, bson.M{"_id": releaseID}).Decode(&release); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "release not found",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	cursor, err := config.GetCollection("deployments").Find(ctx, bson.M{"release_id": releaseID})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch deployments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var children []models.Deployment
+	if err := cursor.All(ctx, &children); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode deployments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	byID := make(map[bson.ObjectID]models.Deployment, len(children))
+	for _, child := range children {
+		byID[child.ID] = child
+	}
+	ordered := make([]models.Deployment, 0, len(children))
+	for _, item := range release.Items {
+		if child, ok := byID[item.DeploymentID]; ok {
+			ordered = append(ordered, child)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(struct {
+		models.Release
+		Deployments []models.Deployment `json:"deployments"`
+	}{release, ordered})
+}
+
+// releaseNamePattern allows version-like names such as 2026.10.1 or v1.2.0-rc.1
+var releaseNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]{0,99}$`)
+
+func validateReleaseName(name string) error {
+	if !releaseNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid release name %q: must be at most 100 letters, digits, '.', '_', '+' or '-', starting with a letter or digit", name)
+	}
+	return nil
+}
+
+// CreateRelease records a named release of a project that groups deployments
+// already created, and links them to it. They must be deployments of the
+// project's services, to the same environment, at most one per service, and
+// not part of another release. The release follows them without holding any
+// back, and is done once they all are.
+func CreateRelease(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	projectID := mux.Vars(r)["projectId"]
+	projectObjectID, err := bson.ObjectIDFromHex(projectID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid project ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	var req struct {
+		Name          string          `json:"name"`
+		Notes         string          `json:"notes"`
+		DeploymentIDs []bson.ObjectID `json:"deployment_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	invalid := func(msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   msg,
+		})
+	}
+	if req.Name == "" {
+		invalid("name is required")
+		return
+	}
+	if err := validateReleaseName(req.Name); err != nil {
+		invalid(err.Error())
+		return
+	}
+	if len(req.DeploymentIDs) == 0 {
+		invalid("deployment_ids must list at least one deployment")
+		return
+	}
+
+	waves, err := fetchDeployOrder(projectID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch deploy order",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if waves == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "project not found",
+		})
+		return
+	}
+	type projectService struct {
+		name string
+		wave int
+	}
+	services := map[bson.ObjectID]projectService{}
+	for wave, inWave := range waves {
+		for _, service := range inWave {
+			id, _ := service["id"].(string)
+			serviceID, err := bson.ObjectIDFromHex(id)
+			if err != nil {
+				continue
+			}
+			name, _ := service["name"].(string)
+			services[serviceID] = projectService{name: name, wave: wave}
+		}
+	}
+
+	collection := config.GetCollection("deployments")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": req.DeploymentIDs}})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch deployments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	var found []models.Deployment
+	if err := cursor.All(ctx, &found); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode deployments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	byID := make(map[bson.ObjectID]models.Deployment, len(found))
+	for _, deployment := range found {
+		byID[deployment.ID] = deployment
+	}
+
+	release := models.Release{
+		ID:        bson.NewObjectID(),
+		ProjectID: projectObjectID,
+		Name:      req.Name,
+		Notes:     req.Notes,
+		Status:    models.ReleaseRunning,
+		// A recorded release never cancels deployments it didn't create
+		OnFailure: models.OnFailureContinue,
+		Items:     []models.ReleaseItem{},
+	}
+	if claims, ok := callerClaims(r); ok {
+		release.TriggeredBy = claims.Identity()
+	}
+	for i, id := range req.DeploymentIDs {
+		deployment, ok := byID[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "deployment not found",
+				"msg":   "deployment " + id.Hex() + " doesn't exist",
+			})
+			return
+		}
+		service, ok := services[deployment.ServiceID]
+		if !ok {
+			invalid("deployment " + id.Hex() + " isn't a deployment of the project's services")
+			return
+		}
+		if containsService(release.Items, deployment.ServiceID) {
+			invalid("more than one deployment of service " + deployment.ServiceID.Hex() + " is listed")
+			return
+		}
+		if !deployment.ReleaseID.IsZero() {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":      "deployment is part of another release",
+				"msg":        "deployment " + id.Hex() + " belongs to release " + deployment.ReleaseID.Hex(),
+				"release_id": deployment.ReleaseID,
+			})
+			return
+		}
+		if i == 0 {
+			release.Environment = deployment.Environment
+		} else if environmentName(deployment.Environment) != environmentName(release.Environment) {
+			invalid("all deployments of a release must be to the same environment")
+			return
+		}
+		release.TenantID = deployment.TenantID
+		release.Items = append(release.Items, models.ReleaseItem{
+			ServiceID:    deployment.ServiceID,
+			ServiceName:  service.name,
+			Version:      deployment.Spec.Version,
+			CommitSHA:    deployment.Spec.CommitSHA,
+			Wave:         service.wave,
+			DeploymentID: deployment.ID,
+			Status:       deployment.Status,
+			Released:     true,
+		})
+	}
+	releases.Summarize(&release)
+	release.CreatedAt = time.Now()
+	release.UpdatedAt = release.CreatedAt
+
+	if err := releases.Changelog(ctx, &release); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to compute changelog",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	// Link the deployments first, so the release never lists deployments
+	// another release got hold of
+	unlink := func(items []models.ReleaseItem) {
+		for _, item := range items {
+			collection.UpdateOne(ctx,
+				bson.M{"_id": item.DeploymentID, "release_id": release.ID},
+				bson.M{"$unset": bson.M{"release_id": ""}},
+			)
+		}
+	}
+	for i, item := range release.Items {
+		result, err := collection.UpdateOne(ctx,
+			bson.M{"_id": item.DeploymentID, "release_id": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"release_id": release.ID, "updated_at": time.Now()}},
+		)
+		if err == nil && result.MatchedCount == 0 {
+			unlink(release.Items[:i])
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "deployment is part of another release",
+				"msg":   "deployment " + item.DeploymentID.Hex() + " was added to another release",
+			})
+			return
+		}
+		if err != nil {
+			unlink(release.Items[:i])
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "failed to link deployment",
+				"msg":   err.Error(),
+			})
+			return
+		}
+	}
+
+	if _, err := config.GetCollection("releases").InsertOne(ctx, release); err != nil {
+		unlink(release.Items)
+		status, msg := http.StatusInternalServerError, "failed to create release"
+		if mongo.IsDuplicateKeyError(err) {
+			status, msg = http.StatusConflict, "a release with this name already exists in the project"
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": msg,
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	audit.SetResource(r, "release", release.ID.Hex())
+	if !release.TenantID.IsZero() {
+		audit.SetTenant(r, release.TenantID.Hex())
+	}
+	audit.SetChange(r, nil, release)
+
+	// Catch up with deployments that are already done
+	go func() {
+		if err := releases.Advance(context.Background(), release.ID); err != nil {
+			log.Printf("Failed to advance release %s: %v", release.ID.Hex(), err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "release created successfully",
+		"data":    release,
+	})
+}
+
+func environmentName(environment *models.Environment) string {
+	if environment == nil {
+		return ""
+	}
+	return environment.Name
+}
+
+// UpdateRelease replaces a release's name and notes. A release without a
+// name is left unnamed.
+func UpdateRelease(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	releaseID, err := bson.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid release ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	var req struct {
+		Name  string `json:"name"`
+		Notes string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if req.Name != "" {
+		if err := validateReleaseName(req.Name); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid request payload",
+				"msg":   err.Error(),
+			})
+			return
+		}
+	}
+
+	collection := config.GetCollection("releases")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	set := bson.M{"updated_at": time.Now()}
+	unset := bson.M{}
+	for field, value := range map[string]string{"name": req.Name, "notes": req.Notes} {
+		if value == "" {
+			unset[field] = ""
+		} else {
+			set[field] = value
+		}
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var before models.Release
+	err = collection.FindOneAndUpdate(ctx, bson.M{"_id": releaseID}, update).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "release not found",
+		})
+		return
+	}
+	if err != nil {
+		status, msg := http.StatusInternalServerError, "failed to update release"
+		if mongo.IsDuplicateKeyError(err) {
+			status, msg = http.StatusConflict, "a release with this name already exists in the project"
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": msg,
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	audit.SetResource(r, "release", releaseID.Hex())
+	if !before.TenantID.IsZero() {
+		audit.SetTenant(r, before.TenantID.Hex())
+	}
+	audit.SetChange(r,
+		map[string]interface{}{"name": before.Name, "notes": before.Notes},
+		map[string]interface{}{"name": req.Name, "notes": req.Notes},
+	)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "release updated successfully",
+	})
+}
+
+// DeleteRelease removes a release that is done and unlinks its deployments,
+// which are kept. Running releases can't be deleted, since they may still
+// hold deployments back, and neither can releases that later releases'
+// changelogs are based on.
+func DeleteRelease(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	releaseID, err := bson.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid release ID format",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	collection := config.GetCollection("releases")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var before models.Release
+	if err := collection.FindOne(ctx, bson.M{"_id": releaseID}).Decode(&before); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "release not found",
+		})
+		return
+	}
+
+	referenced, err := collection.CountDocuments(ctx, bson.M{"$or": bson.A{
+		bson.M{"previous_release_id": releaseID},
+		bson.M{"changelog.from_release_id": releaseID},
+	}})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to check release references",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if referenced > 0 {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "release is referenced",
+			"msg":   fmt.Sprintf("the changelogs of %d later releases are based on this release", referenced),
+		})
+		return
+	}
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": releaseID, "status": bson.M{"$ne": models.ReleaseRunning}})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to delete release",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if result.DeletedCount == 0 {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "release is running",
+			"msg":   "a release can only be deleted once all its deployments are done",
+		})
+		return
+	}
+
+	if _, err := config.GetCollection("deployments").UpdateMany(ctx,
+		bson.M{"release_id": releaseID},
+		bson.M{"$unset": bson.M{"release_id": ""}},
+	); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to unlink deployments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	audit.SetResource(r, "release", releaseID.Hex())
+	if !before.TenantID.IsZero() {
+		audit.SetTenant(r, before.TenantID.Hex())
+	}
+	audit.SetChange(r, before, nil)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "release deleted successfully",
+	})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"deployment-service/audit"
+	"deployment-service/config"
+	"deployment-service/deployments"
+	"deployment-service/models"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// loadDeployment reads the deployment named in the path, writing the error
+// response and returning false if it can't
+func loadDeployment(ctx context.Context, w http.ResponseWriter, r *http.Request) (models.Deployment, bool) {
+	var deployment models.Deployment
+
+	deploymentID, err := bson.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid deployment ID format",
+			"msg":   err.Error(),
+		})
+		return deployment, false
+	}
+
+	if err := config.GetCollection("deployments").FindOne(ctx, bson.M{"_id": deploymentID}).Decode(&deployment); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "deployment not found",
+			"msg":   err.Error(),
+		})
+		return deployment, false
+	}
+
+	audit.SetResource(r, "deployment", deployment.ID.Hex())
+	if !deployment.TenantID.IsZero() {
+		audit.SetTenant(r, deployment.TenantID.Hex())
+	}
+	return deployment, true
+}
+
+// PromoteDeployment resumes a paused canary or blue-green rollout, or cuts a
+// timed pause short. ?full=true also skips the remaining pauses.
+func PromoteDeployment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deployment, ok := loadDeployment(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	promoted, err := deployments.Promote(ctx, deployment, "api", r.URL.Query().Get("full") == "true")
+	if errors.Is(err, deployments.ErrNoRollout) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "nothing to promote",
+			"msg":   "deployment is " + string(deployment.Status) + " without an active rollout",
+		})
+		return
+	}
+	if errors.Is(err, deployments.ErrInvalidTransition) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "rollout changed while promoting, try again",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to promote deployment",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetAction(r, "promote")
+	audit.SetChange(r, map[string]interface{}{"rollout": deployment.Rollout}, map[string]interface{}{"rollout": promoted.Rollout})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "deployment promoted",
+		"data":    promoted,
+	})
+}
+
+// AbortDeployment stops a deployment that hasn't finished, sends all traffic
+// back to the old version and marks it Failed. The body may give a reason.
+func AbortDeployment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if request.Reason == "" {
+		request.Reason = "aborted through the API"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deployment, ok := loadDeployment(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	aborted, err := deployments.Abort(ctx, deployment, "api", request.Reason)
+	if errors.Is(err, deployments.ErrInvalidTransition) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid status transition",
+			"msg":   "deployment is already " + string(deployment.Status),
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to abort deployment",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetAction(r, "abort")
+	audit.SetChange(r, map[string]interface{}{"status": deployment.Status, "rollout": deployment.Rollout},
+		map[string]interface{}{"status": aborted.Status, "rollout": aborted.Rollout})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "deployment aborted",
+		"data":    aborted,
+	})
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"deployment-service/audit"
+	"deployment-service/config"
+	"deployment-service/deployments"
+	"deployment-service/freeze"
+	"deployment-service/models"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// GetScheduledDeployments lists deployments scheduled for later, soonest
+// first, including those still awaiting approval. Supports service_id and
+// tenant_id filters and a label selector.
+func GetScheduledDeployments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10 // Default limit
+	}
+
+	skip := (page - 1) * limit
+
+	filter := bson.M{
+		"status":       bson.M{"$in": []models.DeploymentStatus{models.StatusScheduled, models.StatusAwaitingApproval}},
+		"scheduled_at": bson.M{"$exists": true},
+	}
+	for _, field := range []string{"service_id", "tenant_id"} {
+		value := r.URL.Query().Get(field)
+		if value == "" {
+			continue
+		}
+		id, err := bson.ObjectIDFromHex(value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid " + field + " format",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		filter[field] = id
+	}
+	if !withSelector(w, r, filter) {
+		return
+	}
+
+	collection := config.GetCollection("deployments")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to count deployments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64(skip))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "scheduled_at", Value: 1}}) // Sort by soonest first
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to fetch deployments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var scheduled []models.Deployment
+	if err := cursor.All(ctx, &scheduled); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to decode deployments",
+			"msg":   err.Error(),
+		})
+		return
+	}
+
+	if scheduled == nil {
+		scheduled = []models.Deployment{}
+	}
+
+	totalPages := (int(totalCount) + limit - 1) / limit
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        scheduled,
+		"page":        page,
+		"limit":       limit,
+		"total_count": totalCount,
+		"total_pages": totalPages,
+	})
+}
+
+// RescheduleDeployment moves a scheduled deployment to another time given
+// as {"scheduled_at": "..."}. The new time is checked against the freezes
+// the same way it is on creation.
+func RescheduleDeployment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		ScheduledAt *time.Time `json:"scheduled_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if request.ScheduledAt == nil || !request.ScheduledAt.After(time.Now()) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   "scheduled_at must be in the future",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deployment, ok := loadDeployment(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	if deployment.BreakGlass == nil {
+		decision, err := freeze.Check(ctx, deployment.TenantID, deployment.ServiceID, *request.ScheduledAt)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "failed to check freeze windows",
+				"msg":   err.Error(),
+			})
+			return
+		}
+		if decision.Frozen && decision.Action == models.FreezeActionReject {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":   "deployments are frozen",
+				"msg":     "a freeze is in effect until " + decision.Until.Format(time.RFC3339),
+				"freezes": decision.Windows,
+			})
+			return
+		}
+	}
+
+	rescheduled, err := deployments.Reschedule(ctx, deployment, *request.ScheduledAt, "api")
+	if errors.Is(err, deployments.ErrNotScheduled) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "deployment is not scheduled",
+			"msg":   "deployment is " + string(deployment.Status),
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to reschedule deployment",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetAction(r, "reschedule")
+	audit.SetChange(r, map[string]interface{}{"scheduled_at": deployment.ScheduledAt},
+		map[string]interface{}{"scheduled_at": rescheduled.ScheduledAt})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "deployment rescheduled",
+		"data":    rescheduled,
+	})
+}
+
+// CancelDeployment marks a deployment that is Scheduled, Waiting or Queued as
+// Failed before it starts. The body may give a reason.
+func CancelDeployment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "invalid request payload",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	if request.Reason == "" {
+		request.Reason = "canceled through the API"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deployment, ok := loadDeployment(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	canceled, err := deployments.Cancel(ctx, deployment, "api", request.Reason)
+	if errors.Is(err, deployments.ErrNotScheduled) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "deployment has already started",
+			"msg":   "deployment is " + string(deployment.Status),
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "failed to cancel deployment",
+			"msg":   err.Error(),
+		})
+		return
+	}
+	audit.SetAction(r, "cancel")
+	audit.SetChange(r, map[string]interface{}{"status": deployment.Status},
+		map[string]interface{}{"status": canceled.Status})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "deployment canceled",
+		"data":    canceled,
+	})
+}
@@ -0,0 +1,142 @@
+package health
+
+import (
+	"context"
+	"deployment-service/models"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// Defaults for health check settings a service leaves out
+const (
+	DefaultSuccessThreshold = 1
+	DefaultInterval         = 10 * time.Second
+	DefaultWindow           = 5 * time.Minute
+	DefaultProbeTimeout     = 5 * time.Second
+)
+
+// maxBodyMatch bounds how much of a response body is matched against
+const maxBodyMatch = 1 << 20
+
+// Window returns how long a health check may take to pass
+func Window(check models.HealthCheck) time.Duration {
+	if check.WindowSeconds > 0 {
+		return time.Duration(check.WindowSeconds) * time.Second
+	}
+	return DefaultWindow
+}
+
+// Verify runs the probes of a health check every interval until all of them
+// pass SuccessThreshold times in a row. It returns the last probe failure if
+// that doesn't happen within the window.
+func Verify(ctx context.Context, check models.HealthCheck) error {
+	threshold := check.SuccessThreshold
+	if threshold < 1 {
+		threshold = DefaultSuccessThreshold
+	}
+	interval := DefaultInterval
+	if check.IntervalSeconds > 0 {
+		interval = time.Duration(check.IntervalSeconds) * time.Second
+	}
+	window := Window(check)
+
+	ctx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	streak := 0
+	var lastErr error
+	for {
+		if err := probeAll(ctx, check.Probes); err != nil {
+			streak = 0
+			lastErr = err
+		} else if streak++; streak >= threshold {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr == nil {
+				lastErr = errors.New("no probe failed")
+			}
+			return fmt.Errorf("%d consecutive successes not reached within %s: %v", threshold, window, lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+func probeAll(ctx context.Context, probes []models.HealthProbe) error {
+	for _, p := range probes {
+		timeout := DefaultProbeTimeout
+		if p.TimeoutSeconds > 0 {
+			timeout = time.Duration(p.TimeoutSeconds) * time.Second
+		}
+		var err error
+		switch p.Type {
+		case "http":
+			err = probeHTTP(ctx, p, timeout)
+		case "tcp":
+			err = probeTCP(ctx, p, timeout)
+		default:
+			err = fmt.Errorf("unknown probe type %q", p.Type)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func probeHTTP(ctx context.Context, p models.HealthProbe, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return fmt.Errorf("http %s: %v", p.URL, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("http %s: %v", p.URL, err)
+	}
+	defer resp.Body.Close()
+
+	expected := p.ExpectedStatus
+	if expected == 0 {
+		expected = http.StatusOK
+	}
+	if resp.StatusCode != expected {
+		return fmt.Errorf("http %s: got status %d, expected %d", p.URL, resp.StatusCode, expected)
+	}
+
+	if p.BodyMatch != "" {
+		pattern, err := regexp.Compile(p.BodyMatch)
+		if err != nil {
+			return fmt.Errorf("http %s: invalid body_match: %v", p.URL, err)
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyMatch))
+		if err != nil {
+			return fmt.Errorf("http %s: %v", p.URL, err)
+		}
+		if !pattern.Match(body) {
+			return fmt.Errorf("http %s: body does not match %q", p.URL, p.BodyMatch)
+		}
+	}
+	return nil
+}
+
+func probeTCP(ctx context.Context, p models.HealthProbe, timeout time.Duration) error {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.Address)
+	if err != nil {
+		return fmt.Errorf("tcp %s: %v", p.Address, err)
+	}
+	return conn.Close()
+}
@@ -1,22 +1,144 @@
 package main
 
 import (
+	"context"
+	"deployment-service/audit"
 	"deployment-service/config"
 	"deployment-service/handlers"
+	"deployment-service/metrics"
+	"deployment-service/middleware"
+	"deployment-service/orchestrator"
+	"deployment-service/reaper"
+	"deployment-service/releases"
+	"deployment-service/scheduler"
+	"deployment-service/selector"
+	"deployment-service/tlsutil"
+	"deployment-service/utils"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
+	// Layered configuration: defaults, YAML file, environment, flags
+	if err := config.Load(os.Args[1:]); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
 	config.ConnectDB()
 
+	// Audit log indexes keep the hash chain linear across replicas
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := audit.EnsureIndexes(ctx); err != nil {
+		log.Fatal("Failed to create audit log indexes:", err)
+	}
+	if err := selector.EnsureIndexes(ctx, "deployments"); err != nil {
+		log.Fatal("Failed to create label indexes:", err)
+	}
+	cancel()
+
+	// Re-drive or fail deployments stuck in Pending or Running
+	if err := reaper.Start(); err != nil {
+		log.Fatal("Failed to start deployment reaper:", err)
+	}
+	if err := orchestrator.Start(); err != nil {
+		log.Fatal("Failed to start rollout orchestrator:", err)
+	}
+	if err := scheduler.Start(); err != nil {
+		log.Fatal("Failed to start deployment scheduler:", err)
+	}
+	if err := releases.Start(); err != nil {
+		log.Fatal("Failed to start release driver:", err)
+	}
+
+	// Pick up rotated secrets and URLs from CONFIG_DIR and on SIGHUP
+	config.WatchConfig()
+
+	// TLS certificate shared by the server and the core-service client
+	var reloader *tlsutil.CertReloader
+	if config.App.TLSEnabled() {
+		var err error
+		reloader, err = tlsutil.NewCertReloader(config.App.TLS.CertFile, config.App.TLS.KeyFile, config.App.TLS.CAFile)
+		if err != nil {
+			log.Fatal("Failed to load TLS certificate:", err)
+		}
+		reloader.Watch(config.App.TLS.ReloadInterval)
+		utils.SetCertReloader(reloader)
+	}
+
 	r := mux.NewRouter()
-	r.HandleFunc("/services/{serviceId}/deployments", handlers.CreateDeployment).Methods("POST")
-	r.HandleFunc("/services/{serviceId}/deployments", handlers.GetDeploymentsByServiceID).Methods("GET")
+	r.Use(middleware.RequestID, audit.Middleware)
+
+	r.HandleFunc("/metrics", metrics.Handler).Methods("GET")
+
+	// Callbacks from the deploy target are authenticated by their signature
+	r.HandleFunc("/deployments/{id}/callback", handlers.DeploymentCallback).Methods("POST")
+
+	apiRouter := r.PathPrefix("").Subrouter()
+	if len(config.App.TLS.AllowedClientSANs) > 0 {
+		apiRouter.Use(middleware.RequireClientSAN)
+	}
+	apiRouter.HandleFunc("/services/{serviceId}/deployments", handlers.CreateDeployment).Methods("POST")
+	apiRouter.HandleFunc("/services/{serviceId}/deployments", handlers.GetDeploymentsByServiceID).Methods("GET")
+	apiRouter.HandleFunc("/services/{serviceId}/environments", handlers.GetServiceEnvironments).Methods("GET")
+	apiRouter.HandleFunc("/services/{serviceId}/promote", handlers.PromoteService).Methods("POST")
+	apiRouter.HandleFunc("/projects/{projectId}/deployments", handlers.CreateProjectDeployment).Methods("POST")
+	apiRouter.HandleFunc("/projects/{projectId}/releases", handlers.CreateRelease).Methods("POST")
+	apiRouter.HandleFunc("/projects/{projectId}/releases", handlers.GetProjectReleases).Methods("GET")
+	apiRouter.HandleFunc("/releases/{id}", handlers.GetRelease).Methods("GET")
+	apiRouter.HandleFunc("/releases/{id}", handlers.UpdateRelease).Methods("PUT")
+	apiRouter.HandleFunc("/releases/{id}", handlers.DeleteRelease).Methods("DELETE")
+	apiRouter.HandleFunc("/deployments/scheduled", handlers.GetScheduledDeployments).Methods("GET")
+	apiRouter.HandleFunc("/deployments/{id}", handlers.GetDeploymentByID).Methods("GET")
+	apiRouter.HandleFunc("/deployments/{id}/promote", handlers.PromoteDeployment).Methods("POST")
+	apiRouter.HandleFunc("/deployments/{id}/abort", handlers.AbortDeployment).Methods("POST")
+	apiRouter.HandleFunc("/deployments/{id}/schedule", handlers.RescheduleDeployment).Methods("PUT")
+	apiRouter.HandleFunc("/deployments/{id}/cancel", handlers.CancelDeployment).Methods("POST")
+
+	// Approvals are recorded against the caller's core-service token
+	approvalRouter := apiRouter.PathPrefix("/deployments/{id}").Subrouter()
+	approvalRouter.Use(middleware.AuthMiddleware)
+	approvalRouter.HandleFunc("/approve", handlers.ApproveDeployment).Methods("POST")
+	approvalRouter.HandleFunc("/reject", handlers.RejectDeployment).Methods("POST")
+
+	// Freeze windows (changes require a core-service token with the
+	// freezes:write scope)
+	apiRouter.HandleFunc("/freezes", handlers.GetFreezes).Methods("GET")
+	freezeRouter := apiRouter.PathPrefix("/freezes").Subrouter()
+	freezeRouter.Use(middleware.AuthMiddleware, middleware.RequireScope("freezes:write"))
+	freezeRouter.HandleFunc("", handlers.CreateFreeze).Methods("POST")
+	freezeRouter.HandleFunc("/{id}", handlers.UpdateFreeze).Methods("PUT")
+	freezeRouter.HandleFunc("/{id}", handlers.DeleteFreeze).Methods("DELETE")
+
+	// Audit log (requires a core-service token with the audit:read scope)
+	auditRouter := apiRouter.PathPrefix("/audit").Subrouter()
+	auditRouter.Use(middleware.AuthMiddleware, middleware.RequireScope("audit:read"))
+	auditRouter.HandleFunc("", handlers.GetAuditEntries).Methods("GET")
+	auditRouter.HandleFunc("/export", handlers.ExportAuditEntries).Methods("GET")
+	auditRouter.HandleFunc("/verify", handlers.VerifyAuditLog).Methods("GET")
 
 	// Start server
-	log.Println("Deployment Service starting on :8082")
-	log.Fatal(http.ListenAndServe(":8082", r))
+	server := &http.Server{
+		Addr:         config.App.Addr(),
+		Handler:      r,
+		ReadTimeout:  config.App.Server.ReadTimeout,
+		WriteTimeout: config.App.Server.WriteTimeout,
+		IdleTimeout:  config.App.Server.IdleTimeout,
+	}
+	if reloader == nil {
+		log.Printf("Deployment Service starting on %s", server.Addr)
+		log.Fatal(server.ListenAndServe())
+	}
+
+	clientAuth, err := tlsutil.ParseClientAuth(config.App.TLS.ClientAuth)
+	if err != nil {
+		log.Fatal("Invalid TLS_CLIENT_AUTH:", err)
+	}
+	server.TLSConfig = reloader.ServerConfig(clientAuth)
+
+	log.Printf("Deployment Service starting with TLS on %s", server.Addr)
+	log.Fatal(server.ListenAndServeTLS("", ""))
 }
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CounterVec is a counter partitioned by label values. Counters are kept per
+// replica; the metrics backend sums them across replicas.
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*CounterVec
+)
+
+// NewCounterVec creates a counter and registers it for exposition
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: map[string]float64{}}
+	registryMu.Lock()
+	registry = append(registry, c)
+	registryMu.Unlock()
+	return c
+}
+
+// Inc increments the counter for the given label values, in label order
+func (c *CounterVec) Inc(values ...string) {
+	pairs := make([]string, len(c.labels))
+	for i, label := range c.labels {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = fmt.Sprintf("%s=%q", label, value)
+	}
+	key := strings.Join(pairs, ",")
+
+	c.mu.Lock()
+	c.values[key]++
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) write(w http.ResponseWriter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s{%s} %g\n", c.name, key, c.values[key])
+	}
+}
+
+// Handler serves all registered metrics in the Prometheus text format
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, c := range registry {
+		c.write(w)
+	}
+}
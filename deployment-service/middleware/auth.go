@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"deployment-service/utils"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type contextKey string
+
+const claimsContextKey contextKey = "claims"
+
+// ClaimsFromContext returns the claims of the authenticated caller, if any
+func ClaimsFromContext(ctx context.Context) (*utils.Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*utils.Claims)
+	return claims, ok
+}
+
+// AuthMiddleware requires a valid Bearer token issued by core-service or
+// signed with the shared JWT secret
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || tokenString == "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "missing authorization header",
+				"msg":   "Authorization header with Bearer token is required",
+			})
+			return
+		}
+
+		claims, err := utils.ParseServiceToken(tokenString)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "invalid token",
+				"msg":   err.Error(),
+			})
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireScope rejects callers whose token was not granted the given scope
+// by core-service. Tokens signed with the shared JWT secret never have one.
+// It must run after AuthMiddleware.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok || claims.Legacy || !claims.HasScope(scope) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error": "insufficient scope",
+					"msg":   "token requires scope " + scope,
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
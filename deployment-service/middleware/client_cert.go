@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"deployment-service/config"
+	"deployment-service/tlsutil"
+	"encoding/json"
+	"net/http"
+)
+
+// RequireClientSAN only admits callers presenting a verified client
+// certificate whose SAN is in ALLOWED_CLIENT_SANS
+func RequireClientSAN(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := tlsutil.VerifiedPeerSAN(r.TLS, config.App.TLS.AllowedClientSANs); !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "client certificate required",
+				"msg":   "a verified client certificate with an allowed SAN is required",
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Outcomes of a canary analysis. An inconclusive analysis, for example one
+// without data yet, is retried.
+const (
+	AnalysisPassed       = "passed"
+	AnalysisFailed       = "failed"
+	AnalysisInconclusive = "inconclusive"
+)
+
+// AnalysisSpec lists the metrics a canary is judged by. Inconclusive analyses
+// are retried every IntervalSeconds (default 60) up to MaxInconclusive times
+// (default 3) before the canary is aborted.
+type AnalysisSpec struct {
+	Metrics         []AnalysisMetric `json:"metrics" bson:"metrics"`
+	IntervalSeconds int              `json:"interval_seconds,omitempty" bson:"interval_seconds,omitempty"`
+	MaxInconclusive int              `json:"max_inconclusive,omitempty" bson:"max_inconclusive,omitempty"`
+}
+
+// AnalysisMetric is a query run for the canary and the baseline. In Query,
+// {{track}} is replaced by "canary" or "baseline", and {{deployment_id}} and
+// {{service_id}} by the deployment's IDs. The canary fails if its value is
+// above Max, or above the baseline's by more than the MaxIncrease fraction.
+type AnalysisMetric struct {
+	Name        string   `json:"name" bson:"name"`
+	Query       string   `json:"query" bson:"query"`
+	Max         *float64 `json:"max,omitempty" bson:"max,omitempty"`
+	MaxIncrease *float64 `json:"max_increase,omitempty" bson:"max_increase,omitempty"`
+}
+
+// AnalysisRun is the result of one analysis, recorded on the deployment
+type AnalysisRun struct {
+	Step         int           `json:"step" bson:"step"`
+	Weight       int           `json:"weight" bson:"weight"`
+	Outcome      string        `json:"outcome" bson:"outcome"`
+	Measurements []Measurement `json:"measurements" bson:"measurements"`
+	Error        string        `json:"error,omitempty" bson:"error,omitempty"`
+	At           time.Time     `json:"at" bson:"at"`
+}
+
+// Measurement is one metric's values and whether the canary passed it
+type Measurement struct {
+	Metric   string   `json:"metric" bson:"metric"`
+	Canary   float64  `json:"canary" bson:"canary"`
+	Baseline *float64 `json:"baseline,omitempty" bson:"baseline,omitempty"`
+	Passed   bool     `json:"passed" bson:"passed"`
+}
+
+// Validate checks the analysis for metrics that can't be evaluated
+func (a AnalysisSpec) Validate() error {
+	if len(a.Metrics) == 0 {
+		return errors.New("strategy.canary.analysis.metrics must not be empty")
+	}
+	if a.IntervalSeconds < 0 || a.MaxInconclusive < 0 {
+		return errors.New("strategy.canary.analysis settings must not be negative")
+	}
+	for i, m := range a.Metrics {
+		if m.Name == "" || m.Query == "" {
+			return fmt.Errorf("strategy.canary.analysis.metrics[%d]: name and query are required", i)
+		}
+		if m.Max == nil && m.MaxIncrease == nil {
+			return fmt.Errorf("strategy.canary.analysis.metrics[%d]: max or max_increase is required", i)
+		}
+		if m.MaxIncrease != nil && *m.MaxIncrease < 0 {
+			return fmt.Errorf("strategy.canary.analysis.metrics[%d]: max_increase must not be negative", i)
+		}
+	}
+	return nil
+}
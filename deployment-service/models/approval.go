@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// ApprovalPolicy is a service's approval policy as defined in core-service.
+// It is copied onto each deployment when it is created.
+type ApprovalPolicy struct {
+	RequiredApprovals int      `json:"required_approvals" bson:"required_approvals"`
+	AllowedRoles      []string `json:"allowed_roles,omitempty" bson:"allowed_roles,omitempty"`
+	ExpirySeconds     int      `json:"expiry_seconds,omitempty" bson:"expiry_seconds,omitempty"`
+}
+
+// ApprovalState tracks the sign-off of a deployment that is or was
+// AwaitingApproval
+type ApprovalState struct {
+	Policy     ApprovalPolicy `json:"policy" bson:"policy"`
+	Approvals  []Approval     `json:"approvals" bson:"approvals"`
+	Rejection  *Approval      `json:"rejection,omitempty" bson:"rejection,omitempty"`
+	ExpiresAt  time.Time      `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	ApprovedAt time.Time      `json:"approved_at,omitempty" bson:"approved_at,omitempty"`
+}
+
+// Approval records who approved or rejected a deployment
+type Approval struct {
+	Actor   string    `json:"actor" bson:"actor"`
+	Roles   []string  `json:"roles,omitempty" bson:"roles,omitempty"`
+	Comment string    `json:"comment,omitempty" bson:"comment,omitempty"`
+	At      time.Time `json:"at" bson:"at"`
+}
@@ -8,16 +8,103 @@ import (
 
 type DeploymentStatus string
 
+// A deployment starts Pending, is Running while the deploy target works on it
+// and ends Succeeded or Failed. Services with a health check pass through
+// Verifying between the deploy target reporting success and Succeeded.
+// Services with an approval policy start AwaitingApproval and become Pending
+// once approved, or Failed when rejected or expired. Deployments held back by
+// a freeze are Queued until it ends, and those scheduled for later are
+// Scheduled until their time. Deployments of a release are Waiting until the
+// release gets to them.
 const (
-	StatusPending DeploymentStatus = "Pending"
-	StatusRunning DeploymentStatus = "Running"
-	StatusFailed  DeploymentStatus = "Failed"
+	StatusAwaitingApproval DeploymentStatus = "AwaitingApproval"
+	StatusWaiting          DeploymentStatus = "Waiting"
+	StatusScheduled        DeploymentStatus = "Scheduled"
+	StatusQueued           DeploymentStatus = "Queued"
+	StatusPending          DeploymentStatus = "Pending"
+	StatusRunning          DeploymentStatus = "Running"
+	StatusVerifying        DeploymentStatus = "Verifying"
+	StatusSucceeded        DeploymentStatus = "Succeeded"
+	StatusFailed           DeploymentStatus = "Failed"
 )
 
+// IsFinal reports whether no further transitions are allowed
+func (s DeploymentStatus) IsFinal() bool {
+	return s == StatusSucceeded || s == StatusFailed
+}
+
+// CanTransitionTo reports whether a deployment may move from s to next.
+// Progress updates while Running are allowed; final states are terminal.
+func (s DeploymentStatus) CanTransitionTo(next DeploymentStatus) bool {
+	switch s {
+	case StatusAwaitingApproval:
+		return next == StatusPending || next == StatusWaiting || next == StatusScheduled || next == StatusQueued || next == StatusFailed
+	case StatusWaiting:
+		return next == StatusPending || next == StatusQueued || next == StatusFailed
+	case StatusScheduled:
+		return next == StatusPending || next == StatusQueued || next == StatusFailed
+	case StatusQueued:
+		return next == StatusPending || next == StatusFailed
+	case StatusPending:
+		return next == StatusRunning || next == StatusVerifying || next.IsFinal()
+	case StatusRunning:
+		return next == StatusRunning || next == StatusVerifying || next.IsFinal()
+	case StatusVerifying:
+		return next.IsFinal()
+	default:
+		return false
+	}
+}
+
 type Deployment struct {
-	ID        bson.ObjectID    `json:"id,omitempty" bson:"_id,omitempty"`
-	ServiceID bson.ObjectID    `json:"service_id,omitempty" bson:"service_id,omitempty"`
-	Status    DeploymentStatus `json:"status,omitempty" bson:"status,omitempty"`
-	CreatedAt time.Time        `json:"created_at,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt time.Time        `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	ID             bson.ObjectID     `json:"id,omitempty" bson:"_id,omitempty"`
+	ServiceID      bson.ObjectID     `json:"service_id,omitempty" bson:"service_id,omitempty"`
+	TenantID       bson.ObjectID     `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Environment    *Environment      `json:"environment,omitempty" bson:"environment,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty" bson:"labels,omitempty"`
+	Annotations    map[string]string `json:"annotations,omitempty" bson:"annotations,omitempty"`
+	Status         DeploymentStatus  `json:"status,omitempty" bson:"status,omitempty"`
+	Spec           DeploymentSpec    `json:"spec" bson:"spec"`
+	ConfigVersion  int               `json:"config_version,omitempty" bson:"config_version,omitempty"`
+	Rollout        *RolloutState     `json:"rollout,omitempty" bson:"rollout,omitempty"`
+	Analyses       []AnalysisRun     `json:"analyses,omitempty" bson:"analyses,omitempty"`
+	HealthCheck    *HealthCheck      `json:"health_check,omitempty" bson:"health_check,omitempty"`
+	RollbackOf     bson.ObjectID     `json:"rollback_of,omitempty" bson:"rollback_of,omitempty"`
+	RolledBackBy   bson.ObjectID     `json:"rolled_back_by,omitempty" bson:"rolled_back_by,omitempty"`
+	PromotedFrom   bson.ObjectID     `json:"promoted_from,omitempty" bson:"promoted_from,omitempty"`
+	ReleaseID      bson.ObjectID     `json:"release_id,omitempty" bson:"release_id,omitempty"`
+	Held           bool              `json:"held,omitempty" bson:"held,omitempty"`
+	TriggeredBy    string            `json:"triggered_by,omitempty" bson:"triggered_by,omitempty"`
+	Approval       *ApprovalState    `json:"approval,omitempty" bson:"approval,omitempty"`
+	ScheduledAt    *time.Time        `json:"scheduled_at,omitempty" bson:"scheduled_at,omitempty"`
+	ScheduleLock   time.Time         `json:"-" bson:"schedule_lock_until,omitempty"`
+	Queue          *QueueState       `json:"queue,omitempty" bson:"queue,omitempty"`
+	BreakGlass     *BreakGlass       `json:"break_glass,omitempty" bson:"break_glass,omitempty"`
+	Progress       int               `json:"progress,omitempty" bson:"progress,omitempty"`
+	FailureReason  string            `json:"failure_reason,omitempty" bson:"failure_reason,omitempty"`
+	Timeline       []StatusChange    `json:"timeline,omitempty" bson:"timeline,omitempty"`
+	Logs           []string          `json:"logs,omitempty" bson:"logs,omitempty"`
+	CallbackSecret string            `json:"-" bson:"callback_secret,omitempty"`
+	DeadlineAt     time.Time         `json:"deadline_at,omitempty" bson:"deadline_at,omitempty"`
+	Redrives       int               `json:"redrives,omitempty" bson:"redrives,omitempty"`
+	ReapLockUntil  time.Time         `json:"-" bson:"reap_lock_until,omitempty"`
+	CreatedAt      time.Time         `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt      time.Time         `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+// DeploymentSpec is what gets deployed. Rolling back redeploys the spec of
+// the last deployment that succeeded.
+type DeploymentSpec struct {
+	Version   string   `json:"version,omitempty" bson:"version,omitempty"`
+	Image     string   `json:"image,omitempty" bson:"image,omitempty"`
+	CommitSHA string   `json:"commit_sha,omitempty" bson:"commit_sha,omitempty"`
+	Strategy  Strategy `json:"strategy" bson:"strategy"`
+}
+
+// StatusChange is one entry of a deployment's timeline
+type StatusChange struct {
+	Status  DeploymentStatus `json:"status" bson:"status"`
+	At      time.Time        `json:"at" bson:"at"`
+	Source  string           `json:"source" bson:"source"`
+	Message string           `json:"message,omitempty" bson:"message,omitempty"`
 }
@@ -0,0 +1,24 @@
+package models
+
+import "go.mongodb.org/mongo-driver/v2/bson"
+
+// Environment is a project's environment as defined in core-service, such as
+// dev, staging or prod. It is copied onto each deployment to it when the
+// deployment is created.
+type Environment struct {
+	ID             bson.ObjectID     `json:"id" bson:"id"`
+	Name           string            `json:"name" bson:"name"`
+	Order          int               `json:"order" bson:"order"`
+	Protected      bool              `json:"protected,omitempty" bson:"protected,omitempty"`
+	Deployer       *DeployerConfig   `json:"deployer,omitempty" bson:"deployer,omitempty"`
+	Variables      map[string]string `json:"variables,omitempty" bson:"variables,omitempty"`
+	ApprovalPolicy *ApprovalPolicy   `json:"approval_policy,omitempty" bson:"approval_policy,omitempty"`
+}
+
+// DeployerConfig tells the deploy target where an environment lives. URL
+// overrides DEPLOYER_URL for deployments to the environment.
+type DeployerConfig struct {
+	URL        string            `json:"url,omitempty" bson:"url,omitempty"`
+	Namespace  string            `json:"namespace,omitempty" bson:"namespace,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty" bson:"parameters,omitempty"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Freeze scopes and what happens to deployments created during a freeze
+const (
+	FreezeScopeGlobal  = "global"
+	FreezeScopeTenant  = "tenant"
+	FreezeScopeService = "service"
+
+	FreezeActionReject = "reject"
+	FreezeActionQueue  = "queue"
+)
+
+// FreezeWindow blocks deployments during an absolute time range from Start
+// to End, or for DurationMinutes each time the five-field Cron expression
+// fires in Timezone. Scope selects which deployments it applies to.
+type FreezeWindow struct {
+	ID              bson.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Scope           string        `json:"scope" bson:"scope"`
+	TenantID        bson.ObjectID `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	ServiceID       bson.ObjectID `json:"service_id,omitempty" bson:"service_id,omitempty"`
+	Start           *time.Time    `json:"start,omitempty" bson:"start,omitempty"`
+	End             *time.Time    `json:"end,omitempty" bson:"end,omitempty"`
+	Cron            string        `json:"cron,omitempty" bson:"cron,omitempty"`
+	DurationMinutes int           `json:"duration_minutes,omitempty" bson:"duration_minutes,omitempty"`
+	Timezone        string        `json:"timezone,omitempty" bson:"timezone,omitempty"`
+	Reason          string        `json:"reason" bson:"reason"`
+	Action          string        `json:"action" bson:"action"`
+	CreatedBy       string        `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	CreatedAt       time.Time     `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt       time.Time     `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+// ActiveFreeze is a freeze window in effect and when it ends
+type ActiveFreeze struct {
+	ID     bson.ObjectID `json:"id" bson:"id"`
+	Reason string        `json:"reason" bson:"reason"`
+	Action string        `json:"action" bson:"action"`
+	Until  time.Time     `json:"until" bson:"until"`
+}
+
+// QueueState holds a deployment back until a freeze ends, or until another
+// deployment of its service to the same environment, BlockedBy, is done
+type QueueState struct {
+	Until     time.Time      `json:"until" bson:"until"`
+	Freezes   []ActiveFreeze `json:"freezes" bson:"freezes"`
+	BlockedBy bson.ObjectID  `json:"blocked_by,omitempty" bson:"blocked_by,omitempty"`
+	LockUntil time.Time      `json:"-" bson:"lock_until,omitempty"`
+}
+
+// BreakGlass records a deployment that was let through active freezes
+type BreakGlass struct {
+	Actor   string         `json:"actor" bson:"actor"`
+	Reason  string         `json:"reason" bson:"reason"`
+	Freezes []ActiveFreeze `json:"freezes" bson:"freezes"`
+	At      time.Time      `json:"at" bson:"at"`
+}
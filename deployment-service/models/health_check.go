@@ -0,0 +1,26 @@
+package models
+
+// HealthCheck is a service's health check as defined in core-service. It is
+// copied onto each deployment when it is created. Probes run every
+// IntervalSeconds; verification passes once all of them succeed
+// SuccessThreshold times in a row, and fails if that doesn't happen within
+// WindowSeconds.
+type HealthCheck struct {
+	Probes           []HealthProbe `json:"probes" bson:"probes"`
+	SuccessThreshold int           `json:"success_threshold,omitempty" bson:"success_threshold,omitempty"`
+	IntervalSeconds  int           `json:"interval_seconds,omitempty" bson:"interval_seconds,omitempty"`
+	WindowSeconds    int           `json:"window_seconds,omitempty" bson:"window_seconds,omitempty"`
+	AutoRollback     bool          `json:"auto_rollback,omitempty" bson:"auto_rollback,omitempty"`
+}
+
+// HealthProbe is a single check. HTTP probes GET URL and expect
+// ExpectedStatus (default 200) and, if set, a body matching the BodyMatch
+// regular expression. TCP probes connect to Address.
+type HealthProbe struct {
+	Type           string `json:"type" bson:"type"`
+	URL            string `json:"url,omitempty" bson:"url,omitempty"`
+	ExpectedStatus int    `json:"expected_status,omitempty" bson:"expected_status,omitempty"`
+	BodyMatch      string `json:"body_match,omitempty" bson:"body_match,omitempty"`
+	Address        string `json:"address,omitempty" bson:"address,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty" bson:"timeout_seconds,omitempty"`
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type ReleaseStatus string
+
+// A release is Running until all its deployments are final. It Succeeded if
+// all of them did and Failed otherwise.
+const (
+	ReleaseRunning   ReleaseStatus = "Running"
+	ReleaseSucceeded ReleaseStatus = "Succeeded"
+	ReleaseFailed    ReleaseStatus = "Failed"
+)
+
+// What a release does when one of its deployments fails: stop cancels the
+// deployments that haven't started, continue deploys them anyway
+const (
+	OnFailureStop     = "stop"
+	OnFailureContinue = "continue"
+)
+
+// Release groups the deployments of several services of a project under a
+// named version. A release that deploys them itself holds them until it lets
+// them go, wave by wave in dependency order, with at most MaxParallel of them
+// in progress at a time. One that records existing deployments only follows
+// them.
+type Release struct {
+	ID                bson.ObjectID    `json:"id,omitempty" bson:"_id,omitempty"`
+	ProjectID         bson.ObjectID    `json:"project_id" bson:"project_id"`
+	Name              string           `json:"name,omitempty" bson:"name,omitempty"`
+	Notes             string           `json:"notes,omitempty" bson:"notes,omitempty"`
+	PreviousReleaseID bson.ObjectID    `json:"previous_release_id,omitempty" bson:"previous_release_id,omitempty"`
+	Changelog         []ChangelogEntry `json:"changelog" bson:"changelog"`
+	TenantID          bson.ObjectID    `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Environment       *Environment     `json:"environment,omitempty" bson:"environment,omitempty"`
+	Status            ReleaseStatus    `json:"status" bson:"status"`
+	MaxParallel       int              `json:"max_parallel" bson:"max_parallel"`
+	OnFailure         string           `json:"on_failure" bson:"on_failure"`
+	Items             []ReleaseItem    `json:"items" bson:"items"`
+	Summary           ReleaseSummary   `json:"summary" bson:"summary"`
+	TriggeredBy       string           `json:"triggered_by,omitempty" bson:"triggered_by,omitempty"`
+	FailureReason     string           `json:"failure_reason,omitempty" bson:"failure_reason,omitempty"`
+	LockUntil         time.Time        `json:"-" bson:"lock_until,omitempty"`
+	CreatedAt         time.Time        `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt         time.Time        `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	FinishedAt        *time.Time       `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+// ReleaseItem is the deployment of one service in a release. Services of a
+// wave are let go once every earlier wave is done.
+type ReleaseItem struct {
+	ServiceID    bson.ObjectID    `json:"service_id" bson:"service_id"`
+	ServiceName  string           `json:"service_name,omitempty" bson:"service_name,omitempty"`
+	Version      string           `json:"version,omitempty" bson:"version,omitempty"`
+	CommitSHA    string           `json:"commit_sha,omitempty" bson:"commit_sha,omitempty"`
+	Wave         int              `json:"wave" bson:"wave"`
+	DeploymentID bson.ObjectID    `json:"deployment_id,omitempty" bson:"deployment_id,omitempty"`
+	Status       DeploymentStatus `json:"status,omitempty" bson:"status,omitempty"`
+	Released     bool             `json:"released" bson:"released"`
+}
+
+// ReleaseSummary counts a release's deployments by how far they got
+type ReleaseSummary struct {
+	Total      int `json:"total" bson:"total"`
+	Waiting    int `json:"waiting" bson:"waiting"`
+	InProgress int `json:"in_progress" bson:"in_progress"`
+	Succeeded  int `json:"succeeded" bson:"succeeded"`
+	Failed     int `json:"failed" bson:"failed"`
+}
+
+// Changes a changelog entry records
+const (
+	ChangeAdded   = "added"
+	ChangeUpdated = "updated"
+)
+
+// ChangelogEntry is a service whose version or commit changed since the
+// latest earlier release of the project that deployed it successfully, or
+// that no earlier release deployed successfully
+type ChangelogEntry struct {
+	ServiceID     bson.ObjectID `json:"service_id" bson:"service_id"`
+	ServiceName   string        `json:"service_name,omitempty" bson:"service_name,omitempty"`
+	Change        string        `json:"change" bson:"change"`
+	FromReleaseID bson.ObjectID `json:"from_release_id,omitempty" bson:"from_release_id,omitempty"`
+	FromVersion   string        `json:"from_version,omitempty" bson:"from_version,omitempty"`
+	ToVersion     string        `json:"to_version,omitempty" bson:"to_version,omitempty"`
+	FromCommitSHA string        `json:"from_commit_sha,omitempty" bson:"from_commit_sha,omitempty"`
+	ToCommitSHA   string        `json:"to_commit_sha,omitempty" bson:"to_commit_sha,omitempty"`
+}
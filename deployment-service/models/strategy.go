@@ -0,0 +1,189 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Deployment strategies. Recreate and rolling deployments are carried out by
+// the deploy target in one go; canary and blue-green deployments are driven
+// step by step once the deploy target reports the new version is up.
+const (
+	StrategyRecreate  = "recreate"
+	StrategyRolling   = "rolling"
+	StrategyCanary    = "canary"
+	StrategyBlueGreen = "blue-green"
+)
+
+// Actions of a strategy step. Pauses and analyses are handled here; every
+// other action is sent to the deploy target.
+const (
+	StepSetWeight = "set_weight"
+	StepPause     = "pause"
+	StepCutover   = "cutover"
+	StepRetireOld = "retire_old"
+	StepAnalyze   = "analyze"
+	StepAbort     = "abort"
+)
+
+// Rollout phases of a canary or blue-green deployment
+const (
+	RolloutProgressing = "progressing"
+	RolloutPaused      = "paused"
+	RolloutCompleted   = "completed"
+	RolloutAborted     = "aborted"
+)
+
+var surgePattern = regexp.MustCompile(`^[0-9]+%?$`)
+
+// Strategy says how a deployment replaces the running version. An empty
+// type means recreate.
+type Strategy struct {
+	Type      string             `json:"type,omitempty" bson:"type,omitempty"`
+	Rolling   *RollingStrategy   `json:"rolling,omitempty" bson:"rolling,omitempty"`
+	Canary    *CanaryStrategy    `json:"canary,omitempty" bson:"canary,omitempty"`
+	BlueGreen *BlueGreenStrategy `json:"blue_green,omitempty" bson:"blue_green,omitempty"`
+}
+
+// RollingStrategy bounds how many instances are added above and taken out of
+// the desired count during a rolling update, as a count or a percentage
+type RollingStrategy struct {
+	MaxSurge       string `json:"max_surge,omitempty" bson:"max_surge,omitempty"`
+	MaxUnavailable string `json:"max_unavailable,omitempty" bson:"max_unavailable,omitempty"`
+}
+
+// CanaryStrategy shifts traffic to the new version in weighted steps. With
+// Analysis, each step below 100% is followed by a metric analysis that
+// promotes or aborts the canary.
+type CanaryStrategy struct {
+	Steps    []CanaryStep  `json:"steps" bson:"steps"`
+	Analysis *AnalysisSpec `json:"analysis,omitempty" bson:"analysis,omitempty"`
+}
+
+// CanaryStep sends Weight percent of traffic to the new version, then waits
+// PauseSeconds, or until the deployment is promoted if Manual is set
+type CanaryStep struct {
+	Weight       int  `json:"weight" bson:"weight"`
+	PauseSeconds int  `json:"pause_seconds,omitempty" bson:"pause_seconds,omitempty"`
+	Manual       bool `json:"manual,omitempty" bson:"manual,omitempty"`
+}
+
+// BlueGreenStrategy brings up the new version next to the old one and cuts
+// traffic over, right away with AutoCutover or else once promoted. The old
+// version is kept for KeepOldSeconds so an abort can switch back to it.
+type BlueGreenStrategy struct {
+	AutoCutover    bool `json:"auto_cutover,omitempty" bson:"auto_cutover,omitempty"`
+	KeepOldSeconds int  `json:"keep_old_seconds,omitempty" bson:"keep_old_seconds,omitempty"`
+}
+
+// StrategyStep is one step of a canary or blue-green rollout
+type StrategyStep struct {
+	Action       string `json:"action" bson:"action"`
+	Weight       int    `json:"weight,omitempty" bson:"weight,omitempty"`
+	PauseSeconds int    `json:"pause_seconds,omitempty" bson:"pause_seconds,omitempty"`
+	Manual       bool   `json:"manual,omitempty" bson:"manual,omitempty"`
+}
+
+// RolloutState is the persisted progress of a canary or blue-green rollout.
+// Step is the index of the next step to run, which is due at ResumeAt while
+// progressing. A paused rollout waits to be promoted. Retries counts
+// inconclusive analyses of the current step.
+type RolloutState struct {
+	Phase      string    `json:"phase" bson:"phase"`
+	Step       int       `json:"step" bson:"step"`
+	Weight     int       `json:"weight" bson:"weight"`
+	ResumeAt   time.Time `json:"resume_at,omitempty" bson:"resume_at,omitempty"`
+	SkipPauses bool      `json:"skip_pauses,omitempty" bson:"skip_pauses,omitempty"`
+	Retries    int       `json:"retries,omitempty" bson:"retries,omitempty"`
+	LockUntil  time.Time `json:"-" bson:"lock_until,omitempty"`
+}
+
+// IsActive reports whether the rollout still has steps to run
+func (r *RolloutState) IsActive() bool {
+	return r != nil && (r.Phase == RolloutProgressing || r.Phase == RolloutPaused)
+}
+
+// Validate checks the strategy for settings that can't be carried out
+func (s Strategy) Validate() error {
+	switch s.Type {
+	case "", StrategyRecreate:
+	case StrategyRolling:
+		if s.Rolling != nil {
+			for name, value := range map[string]string{"max_surge": s.Rolling.MaxSurge, "max_unavailable": s.Rolling.MaxUnavailable} {
+				if value != "" && !surgePattern.MatchString(value) {
+					return fmt.Errorf("strategy.rolling.%s must be a count or a percentage", name)
+				}
+			}
+		}
+	case StrategyCanary:
+		if s.Canary == nil || len(s.Canary.Steps) == 0 {
+			return errors.New("strategy.canary.steps must not be empty")
+		}
+		for i, step := range s.Canary.Steps {
+			if step.Weight < 1 || step.Weight > 100 {
+				return fmt.Errorf("strategy.canary.steps[%d]: weight must be between 1 and 100", i)
+			}
+			if i > 0 && step.Weight < s.Canary.Steps[i-1].Weight {
+				return fmt.Errorf("strategy.canary.steps[%d]: weights must not decrease", i)
+			}
+			if step.PauseSeconds < 0 {
+				return fmt.Errorf("strategy.canary.steps[%d]: pause_seconds must not be negative", i)
+			}
+			if step.Manual && s.Canary.Analysis != nil {
+				return fmt.Errorf("strategy.canary.steps[%d]: manual steps can't be combined with analysis", i)
+			}
+		}
+		if s.Canary.Analysis != nil {
+			if err := s.Canary.Analysis.Validate(); err != nil {
+				return err
+			}
+		}
+	case StrategyBlueGreen:
+		if s.BlueGreen != nil && s.BlueGreen.KeepOldSeconds < 0 {
+			return errors.New("strategy.blue_green.keep_old_seconds must not be negative")
+		}
+	default:
+		return fmt.Errorf("unknown strategy %q", s.Type)
+	}
+	return nil
+}
+
+// Steps returns the rollout steps of a canary or blue-green strategy, and
+// none for strategies the deploy target carries out in one go
+func (s Strategy) Steps() []StrategyStep {
+	var steps []StrategyStep
+	switch s.Type {
+	case StrategyCanary:
+		if s.Canary == nil || len(s.Canary.Steps) == 0 {
+			return nil
+		}
+		for _, step := range s.Canary.Steps {
+			steps = append(steps, StrategyStep{Action: StepSetWeight, Weight: step.Weight})
+			if step.Manual || step.PauseSeconds > 0 {
+				steps = append(steps, StrategyStep{Action: StepPause, PauseSeconds: step.PauseSeconds, Manual: step.Manual})
+			}
+			if s.Canary.Analysis != nil && step.Weight < 100 {
+				steps = append(steps, StrategyStep{Action: StepAnalyze, Weight: step.Weight})
+			}
+		}
+		if s.Canary.Steps[len(s.Canary.Steps)-1].Weight != 100 {
+			steps = append(steps, StrategyStep{Action: StepSetWeight, Weight: 100})
+		}
+	case StrategyBlueGreen:
+		blueGreen := BlueGreenStrategy{}
+		if s.BlueGreen != nil {
+			blueGreen = *s.BlueGreen
+		}
+		if !blueGreen.AutoCutover {
+			steps = append(steps, StrategyStep{Action: StepPause, Manual: true})
+		}
+		steps = append(steps, StrategyStep{Action: StepCutover, Weight: 100})
+		if blueGreen.KeepOldSeconds > 0 {
+			steps = append(steps, StrategyStep{Action: StepPause, PauseSeconds: blueGreen.KeepOldSeconds})
+		}
+		steps = append(steps, StrategyStep{Action: StepRetireOld, Weight: 100})
+	}
+	return steps
+}
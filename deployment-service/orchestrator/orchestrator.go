@@ -0,0 +1,93 @@
+package orchestrator
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/deployments"
+	"deployment-service/models"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	// lockLease is how long a replica owns a rollout it claimed
+	lockLease = 2 * time.Minute
+	// maxPerPass bounds how many rollouts one pass advances
+	maxPerPass = 100
+)
+
+// Start creates the index rollouts are claimed by and advances due canary
+// and blue-green steps every DEPLOYMENT_ROLLOUT_INTERVAL
+func Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := config.GetCollection("deployments").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "rollout.phase", Value: 1}, {Key: "rollout.resume_at", Value: 1}},
+		Options: options.Index().SetPartialFilterExpression(bson.M{
+			"rollout.phase": models.RolloutProgressing,
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.App.Deployments.RolloutInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := advance(context.Background()); err != nil {
+				log.Printf("Rollout orchestrator failed: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// advance claims rollouts with a due step one at a time and runs their steps.
+// Claiming sets a lock that other replicas respect until it expires, so
+// each step is sent to the deploy target by one replica.
+func advance(ctx context.Context) error {
+	collection := config.GetCollection("deployments")
+
+	for i := 0; i < maxPerPass; i++ {
+		now := time.Now()
+		var deployment models.Deployment
+		err := collection.FindOneAndUpdate(ctx,
+			bson.M{
+				"status":             models.StatusRunning,
+				"rollout.phase":      models.RolloutProgressing,
+				"rollout.resume_at":  bson.M{"$lte": now},
+				"rollout.lock_until": bson.M{"$not": bson.M{"$gt": now}},
+			},
+			bson.M{"$set": bson.M{"rollout.lock_until": now.Add(lockLease)}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&deployment)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		stepCtx, cancel := context.WithTimeout(ctx, lockLease/2)
+		if err := deployments.AdvanceRollout(stepCtx, deployment); err != nil {
+			log.Printf("Failed to advance rollout of deployment %s: %v", deployment.ID.Hex(), err)
+		}
+		cancel()
+
+		// Release the lock so a short pause isn't held up by the lease
+		if _, err := collection.UpdateOne(ctx,
+			bson.M{"_id": deployment.ID, "rollout": bson.M{"$exists": true}},
+			bson.M{"$unset": bson.M{"rollout.lock_until": ""}},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
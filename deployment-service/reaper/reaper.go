@@ -0,0 +1,226 @@
+package reaper
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/deployer"
+	"deployment-service/deployments"
+	"deployment-service/health"
+	"deployment-service/metrics"
+	"deployment-service/models"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	// lockLease is how long a replica owns a stuck deployment it claimed
+	lockLease = 2 * time.Minute
+	// maxPerPass bounds how many deployments one pass handles
+	maxPerPass = 100
+)
+
+// Outcomes recorded in the deployments_reaped_total metric
+const (
+	outcomeRedeployed = "redeployed"
+	outcomeResolved   = "resolved"
+	outcomeRefreshed  = "refreshed"
+	outcomeReverified = "reverified"
+	outcomeExpired    = "expired"
+	outcomeFailed     = "failed"
+)
+
+var reaped = metrics.NewCounterVec("deployments_reaped_total",
+	"Stuck deployments handled by the reaper, by status when found and outcome.",
+	"status", "outcome")
+
+// Start creates the index the reaper queries by and runs it every
+// REAPER_INTERVAL
+func Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := config.GetCollection("deployments").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "updated_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.App.Reaper.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := reap(context.Background()); err != nil {
+				log.Printf("Deployment reaper failed: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// reap claims stuck deployments one at a time and handles them. Claiming
+// sets a lock that other replicas respect until it expires, so each stuck
+// deployment is handled by one replica per lease.
+func reap(ctx context.Context) error {
+	collection := config.GetCollection("deployments")
+	active := []models.DeploymentStatus{models.StatusPending, models.StatusRunning, models.StatusVerifying}
+
+	for i := 0; i < maxPerPass; i++ {
+		now := time.Now()
+		filter := bson.M{
+			"$or": bson.A{
+				bson.M{"status": models.StatusPending, "updated_at": bson.M{"$lt": now.Add(-config.App.Reaper.PendingDeadline)}},
+				bson.M{"status": models.StatusRunning, "updated_at": bson.M{"$lt": now.Add(-config.App.Reaper.RunningDeadline)}},
+				bson.M{"status": models.StatusVerifying, "updated_at": bson.M{"$lt": now.Add(-config.App.Reaper.RunningDeadline)}},
+				bson.M{"status": bson.M{"$in": active}, "deadline_at": bson.M{"$lt": now}},
+				bson.M{"status": models.StatusAwaitingApproval, "approval.expires_at": bson.M{"$lt": now}},
+			},
+			"reap_lock_until": bson.M{"$not": bson.M{"$gt": now}},
+			// Canary and blue-green rollouts are driven by the orchestrator
+			"rollout.phase": bson.M{"$nin": bson.A{models.RolloutProgressing, models.RolloutPaused}},
+		}
+
+		var deployment models.Deployment
+		err := collection.FindOneAndUpdate(ctx, filter,
+			bson.M{"$set": bson.M{"reap_lock_until": now.Add(lockLease)}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&deployment)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		outcome, err := handle(ctx, deployment)
+		if err != nil {
+			log.Printf("Failed to reap deployment %s: %v", deployment.ID.Hex(), err)
+			continue
+		}
+		if outcome != "" {
+			reaped.Inc(string(deployment.Status), outcome)
+			log.Printf("Reaped %s deployment %s: %s", deployment.Status, deployment.ID.Hex(), outcome)
+		}
+	}
+	return nil
+}
+
+// handle re-drives or fails one stuck deployment and returns the outcome,
+// or "" if another writer finished it first
+func handle(ctx context.Context, deployment models.Deployment) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, lockLease/2)
+	defer cancel()
+
+	if deployment.Status == models.StatusAwaitingApproval {
+		_, err := deployments.ExpireApproval(ctx, deployment, "reaper")
+		if errors.Is(err, deployments.ErrInvalidTransition) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return outcomeExpired, nil
+	}
+
+	if !deployment.DeadlineAt.IsZero() && time.Now().After(deployment.DeadlineAt) {
+		return fail(ctx, deployment, "timed out waiting for the deploy target to report a final status")
+	}
+
+	switch deployment.Status {
+	case models.StatusPending:
+		// The process may have died before handing it to the deployer
+		if deployment.Redrives >= config.App.Reaper.MaxRedrives {
+			return fail(ctx, deployment, fmt.Sprintf("stuck in Pending after %d redeploys", deployment.Redrives))
+		}
+		result, err := config.GetCollection("deployments").UpdateOne(ctx,
+			bson.M{"_id": deployment.ID, "status": models.StatusPending},
+			bson.M{"$inc": bson.M{"redrives": 1}, "$set": bson.M{"updated_at": time.Now()}},
+		)
+		if err != nil || result.MatchedCount == 0 {
+			return "", err
+		}
+		deployments.Drive(deployment, "reaper")
+		return outcomeRedeployed, nil
+
+	case models.StatusRunning:
+		// No callback for a while, ask the deploy target directly
+		status, err := deployer.Default.Status(ctx, deployment)
+		if errors.Is(err, deployer.ErrUnknown) {
+			return fail(ctx, deployment, "the deploy target has no record of the deployment")
+		}
+		if err != nil {
+			// Try again once the lock expires; the deadline still applies
+			return "", err
+		}
+
+		update := deployments.Update{
+			Status:  status.Status,
+			Source:  "reaper",
+			Message: status.Message,
+		}
+		outcome := outcomeResolved
+		switch status.Status {
+		case models.StatusSucceeded:
+			_, err := deployments.Succeed(ctx, deployment, update)
+			if errors.Is(err, deployments.ErrInvalidTransition) {
+				return "", nil
+			}
+			if err != nil {
+				return "", err
+			}
+			return outcome, nil
+		case models.StatusFailed:
+			update.FailureReason = status.Message
+		case models.StatusPending, models.StatusRunning:
+			update.Status = models.StatusRunning
+			update.Message = "deploy target reports the deployment is still in progress"
+			outcome = outcomeRefreshed
+		}
+		return transition(ctx, deployment, update, outcome)
+
+	case models.StatusVerifying:
+		// The process running the health check may have died. Leave it
+		// alone while the health check could still be running.
+		if deployment.HealthCheck == nil {
+			return fail(ctx, deployment, "verifying without a health check")
+		}
+		if time.Since(deployment.UpdatedAt) < config.App.Reaper.RunningDeadline+health.Window(*deployment.HealthCheck) {
+			return "", nil
+		}
+		result, err := config.GetCollection("deployments").UpdateOne(ctx,
+			bson.M{"_id": deployment.ID, "status": models.StatusVerifying, "updated_at": deployment.UpdatedAt},
+			bson.M{"$set": bson.M{"updated_at": time.Now()}},
+		)
+		if err != nil || result.MatchedCount == 0 {
+			return "", err
+		}
+		go deployments.Verify(deployment)
+		return outcomeReverified, nil
+	}
+	return "", nil
+}
+
+func fail(ctx context.Context, deployment models.Deployment, reason string) (string, error) {
+	return transition(ctx, deployment, deployments.Update{
+		Status:        models.StatusFailed,
+		Source:        "reaper",
+		FailureReason: reason,
+	}, outcomeFailed)
+}
+
+func transition(ctx context.Context, deployment models.Deployment, update deployments.Update, outcome string) (string, error) {
+	_, err := deployments.Transition(ctx, deployment.ID, update)
+	if errors.Is(err, deployments.ErrInvalidTransition) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return outcome, nil
+}
@@ -0,0 +1,91 @@
+package releases
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/models"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// maxLookback bounds how many earlier releases a changelog looks through for
+// the services of a release
+const maxLookback = 100
+
+// Changelog sets what changed in a release since the releases of its project
+// to the same environment that came before it. Each of its services is
+// compared with the latest earlier release that deployed it successfully, and
+// the latest earlier release of all becomes the previous release.
+func Changelog(ctx context.Context, release *models.Release) error {
+	filter := bson.M{
+		"project_id": release.ProjectID,
+		"_id":        bson.M{"$ne": release.ID},
+		"created_at": bson.M{"$lt": release.CreatedAt},
+	}
+	if release.Environment != nil {
+		filter["environment.name"] = release.Environment.Name
+	} else {
+		filter["environment"] = bson.M{"$exists": false}
+	}
+	cursor, err := config.GetCollection("releases").Find(ctx, filter,
+		options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}}).
+			SetLimit(maxLookback).
+			SetProjection(bson.M{"items": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	var earlier []models.Release
+	if err := cursor.All(ctx, &earlier); err != nil {
+		return err
+	}
+
+	compare(release, earlier)
+	return nil
+}
+
+// compare sets a release's changelog and previous release from the earlier
+// releases, newest first
+func compare(release *models.Release, earlier []models.Release) {
+	release.PreviousReleaseID = bson.ObjectID{}
+	if len(earlier) > 0 {
+		release.PreviousReleaseID = earlier[0].ID
+	}
+
+	release.Changelog = []models.ChangelogEntry{}
+	for _, item := range release.Items {
+		entry := models.ChangelogEntry{
+			ServiceID:   item.ServiceID,
+			ServiceName: item.ServiceName,
+			Change:      models.ChangeAdded,
+			ToVersion:   item.Version,
+			ToCommitSHA: item.CommitSHA,
+		}
+		if from, previous, ok := lastDeployed(earlier, item.ServiceID); ok {
+			if previous.Version == item.Version && previous.CommitSHA == item.CommitSHA {
+				continue
+			}
+			entry.Change = models.ChangeUpdated
+			entry.FromReleaseID = from
+			entry.FromVersion = previous.Version
+			entry.FromCommitSHA = previous.CommitSHA
+		}
+		release.Changelog = append(release.Changelog, entry)
+	}
+}
+
+// lastDeployed finds a service in the latest of the releases, newest first,
+// that deployed it successfully. Versions that failed to deploy never
+// shipped.
+func lastDeployed(releases []models.Release, serviceID bson.ObjectID) (bson.ObjectID, models.ReleaseItem, bool) {
+	for _, release := range releases {
+		for _, item := range release.Items {
+			if item.ServiceID == serviceID && item.Status == models.StatusSucceeded {
+				return release.ID, item, true
+			}
+		}
+	}
+	return bson.ObjectID{}, models.ReleaseItem{}, false
+}
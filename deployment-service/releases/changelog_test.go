@@ -0,0 +1,118 @@
+package releases
+
+import (
+	"deployment-service/models"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCompare(t *testing.T) {
+	api, web, worker := bson.NewObjectID(), bson.NewObjectID(), bson.NewObjectID()
+	names := map[bson.ObjectID]string{api: "api", web: "web", worker: "worker"}
+	deployed := func(serviceID bson.ObjectID, version, commit string, status models.DeploymentStatus) models.ReleaseItem {
+		return models.ReleaseItem{ServiceID: serviceID, ServiceName: names[serviceID], Version: version, CommitSHA: commit, Status: status}
+	}
+	// Newest first, as Changelog reads them
+	older := models.Release{ID: bson.NewObjectID(), Items: []models.ReleaseItem{
+		deployed(api, "1.0.0", "aaa", models.StatusSucceeded),
+		deployed(web, "2.0.0", "bbb", models.StatusSucceeded),
+	}}
+	newer := models.Release{ID: bson.NewObjectID(), Items: []models.ReleaseItem{
+		deployed(api, "1.1.0", "ccc", models.StatusFailed),
+		deployed(web, "2.1.0", "ddd", models.StatusSucceeded),
+		deployed(worker, "0.1.0", "eee", models.StatusFailed),
+	}}
+	earlier := []models.Release{newer, older}
+
+	tests := []struct {
+		name    string
+		items   []models.ReleaseItem
+		earlier []models.Release
+		want    []models.ChangelogEntry
+	}{
+		{
+			name:  "first release adds everything",
+			items: []models.ReleaseItem{deployed(api, "1.0.0", "aaa", models.StatusWaiting)},
+			want: []models.ChangelogEntry{
+				{ServiceID: api, ServiceName: "api", Change: models.ChangeAdded, ToVersion: "1.0.0", ToCommitSHA: "aaa"},
+			},
+		},
+		{
+			name:    "changed version against the latest success",
+			items:   []models.ReleaseItem{deployed(web, "2.2.0", "fff", models.StatusWaiting)},
+			earlier: earlier,
+			want: []models.ChangelogEntry{
+				{ServiceID: web, ServiceName: "web", Change: models.ChangeUpdated, FromReleaseID: newer.ID, FromVersion: "2.1.0", ToVersion: "2.2.0", FromCommitSHA: "ddd", ToCommitSHA: "fff"},
+			},
+		},
+		{
+			name:    "changed commit only",
+			items:   []models.ReleaseItem{deployed(web, "2.1.0", "fff", models.StatusWaiting)},
+			earlier: earlier,
+			want: []models.ChangelogEntry{
+				{ServiceID: web, ServiceName: "web", Change: models.ChangeUpdated, FromReleaseID: newer.ID, FromVersion: "2.1.0", ToVersion: "2.1.0", FromCommitSHA: "ddd", ToCommitSHA: "fff"},
+			},
+		},
+		{
+			name:    "a failed deployment is skipped for the success before it",
+			items:   []models.ReleaseItem{deployed(api, "1.1.0", "ccc", models.StatusWaiting)},
+			earlier: earlier,
+			want: []models.ChangelogEntry{
+				{ServiceID: api, ServiceName: "api", Change: models.ChangeUpdated, FromReleaseID: older.ID, FromVersion: "1.0.0", ToVersion: "1.1.0", FromCommitSHA: "aaa", ToCommitSHA: "ccc"},
+			},
+		},
+		{
+			name:    "a service that only failed before is added",
+			items:   []models.ReleaseItem{deployed(worker, "0.1.0", "eee", models.StatusWaiting)},
+			earlier: earlier,
+			want: []models.ChangelogEntry{
+				{ServiceID: worker, ServiceName: "worker", Change: models.ChangeAdded, ToVersion: "0.1.0", ToCommitSHA: "eee"},
+			},
+		},
+		{
+			name:    "unchanged services are left out",
+			items:   []models.ReleaseItem{deployed(web, "2.1.0", "ddd", models.StatusWaiting)},
+			earlier: earlier,
+			want:    []models.ChangelogEntry{},
+		},
+		{
+			name: "several services",
+			items: []models.ReleaseItem{
+				deployed(api, "1.0.0", "aaa", models.StatusWaiting),
+				deployed(web, "2.2.0", "fff", models.StatusWaiting),
+				deployed(worker, "0.2.0", "ggg", models.StatusWaiting),
+			},
+			earlier: earlier,
+			want: []models.ChangelogEntry{
+				{ServiceID: web, ServiceName: "web", Change: models.ChangeUpdated, FromReleaseID: newer.ID, FromVersion: "2.1.0", ToVersion: "2.2.0", FromCommitSHA: "ddd", ToCommitSHA: "fff"},
+				{ServiceID: worker, ServiceName: "worker", Change: models.ChangeAdded, ToVersion: "0.2.0", ToCommitSHA: "ggg"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := &models.Release{ID: bson.NewObjectID(), Items: tt.items}
+			compare(release, tt.earlier)
+			if !reflect.DeepEqual(release.Changelog, tt.want) {
+				t.Fatalf("changelog = %+v, want %+v", release.Changelog, tt.want)
+			}
+			var wantPrevious bson.ObjectID
+			if len(tt.earlier) > 0 {
+				wantPrevious = tt.earlier[0].ID
+			}
+			if release.PreviousReleaseID != wantPrevious {
+				t.Fatalf("previous release = %s, want %s", release.PreviousReleaseID.Hex(), wantPrevious.Hex())
+			}
+		})
+	}
+}
+
+func TestCompareResetsPreviousRelease(t *testing.T) {
+	release := &models.Release{PreviousReleaseID: bson.NewObjectID(), Changelog: []models.ChangelogEntry{{Change: models.ChangeAdded}}}
+	compare(release, nil)
+	if !release.PreviousReleaseID.IsZero() || len(release.Changelog) != 0 {
+		t.Fatalf("previous release %s with changelog %+v, want neither", release.PreviousReleaseID.Hex(), release.Changelog)
+	}
+}
@@ -0,0 +1,320 @@
+package releases
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/deployments"
+	"deployment-service/models"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	// lockLease is how long a replica owns a release it is advancing
+	lockLease = 2 * time.Minute
+	// maxPerPass bounds how many releases one pass advances
+	maxPerPass = 100
+)
+
+// inProgress are the statuses of a deployment that was let go and is on its
+// way, as opposed to waiting for a person or for its release
+var inProgress = []models.DeploymentStatus{
+	models.StatusQueued,
+	models.StatusPending,
+	models.StatusRunning,
+	models.StatusVerifying,
+}
+
+// Start creates the indexes releases are looked up by and, every
+// SCHEDULER_INTERVAL, advances the releases that are still running
+func Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := config.GetCollection("releases").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "updated_at", Value: 1}}},
+		{Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		// Releases that later releases' changelogs refer to
+		{Keys: bson.D{{Key: "previous_release_id", Value: 1}}},
+		{Keys: bson.D{{Key: "changelog.from_release_id", Value: 1}}},
+		// Release names are unique within a project
+		{
+			Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"name": bson.M{"$type": "string"}}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = config.GetCollection("deployments").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "release_id", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.App.Scheduler.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := advanceAll(context.Background()); err != nil {
+				log.Printf("Release driver failed: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// advanceAll advances the running releases, those that went longest without
+// an update first
+func advanceAll(ctx context.Context) error {
+	cursor, err := config.GetCollection("releases").Find(ctx,
+		bson.M{"status": models.ReleaseRunning},
+		options.Find().
+			SetSort(bson.D{{Key: "updated_at", Value: 1}}).
+			SetLimit(maxPerPass).
+			SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	var running []models.Release
+	if err := cursor.All(ctx, &running); err != nil {
+		return err
+	}
+
+	for _, release := range running {
+		if err := Advance(ctx, release.ID); err != nil {
+			log.Printf("Failed to advance release %s: %v", release.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// Advance moves a running release on: it catches up with its deployments,
+// lets go the next ones in dependency order, stops it if one failed and its
+// policy says so, and finishes it once all are final. It does nothing if
+// another replica is advancing the release.
+func Advance(ctx context.Context, id bson.ObjectID) error {
+	collection := config.GetCollection("releases")
+	now := time.Now()
+
+	var release models.Release
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":        id,
+			"status":     models.ReleaseRunning,
+			"lock_until": bson.M{"$not": bson.M{"$gt": now}},
+		},
+		bson.M{"$set": bson.M{"lock_until": now.Add(lockLease)}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&release)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	advanceCtx, cancel := context.WithTimeout(ctx, lockLease/2)
+	defer cancel()
+	advanceErr := advance(advanceCtx, &release)
+
+	// Save whatever progress was made, even if advancing failed halfway
+	changes := bson.M{
+		"items":      release.Items,
+		"summary":    release.Summary,
+		"status":     release.Status,
+		"updated_at": time.Now(),
+	}
+	if release.FinishedAt != nil {
+		changes["finished_at"] = release.FinishedAt
+		changes["failure_reason"] = release.FailureReason
+	}
+	if _, err := collection.UpdateOne(ctx,
+		bson.M{"_id": release.ID},
+		bson.M{"$set": changes, "$unset": bson.M{"lock_until": ""}},
+	); err != nil {
+		return err
+	}
+	return advanceErr
+}
+
+func advance(ctx context.Context, release *models.Release) error {
+	children, err := findDeployments(ctx, release.ID)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for i := range release.Items {
+		item := &release.Items[i]
+		if child, ok := children[item.DeploymentID]; ok {
+			item.Status = child.Status
+		}
+		if item.Status == models.StatusFailed {
+			failed = append(failed, item.ServiceName)
+		}
+	}
+
+	if len(failed) > 0 && release.OnFailure != models.OnFailureContinue {
+		stop(ctx, release, fmt.Sprintf("release stopped after %s failed", failed[0]))
+	} else if err := letGo(ctx, release, children); err != nil {
+		Summarize(release)
+		return err
+	}
+
+	Summarize(release)
+	if release.Summary.Succeeded+release.Summary.Failed == release.Summary.Total {
+		now := time.Now()
+		release.FinishedAt = &now
+		release.Status = models.ReleaseSucceeded
+		if release.Summary.Failed > 0 {
+			release.Status = models.ReleaseFailed
+			release.FailureReason = fmt.Sprintf("%d of %d deployments failed", release.Summary.Failed, release.Summary.Total)
+		}
+	}
+	return nil
+}
+
+// letGo lets go the deployments of the earliest wave that isn't done, as far
+// as MaxParallel allows. A deployment that is let go while still awaiting
+// approval goes on once approved.
+func letGo(ctx context.Context, release *models.Release, children map[bson.ObjectID]models.Deployment) error {
+	next := nextItems(release)
+	for i := range release.Items {
+		item := &release.Items[i]
+		if item.Status.IsFinal() {
+			continue
+		}
+
+		if !item.Released {
+			if !slices.Contains(next, i) {
+				continue
+			}
+			var child models.Deployment
+			err := config.GetCollection("deployments").FindOneAndUpdate(ctx,
+				bson.M{"_id": item.DeploymentID},
+				bson.M{"$unset": bson.M{"held": ""}, "$set": bson.M{"updated_at": time.Now()}},
+				options.FindOneAndUpdate().SetReturnDocument(options.After),
+			).Decode(&child)
+			if err != nil {
+				return fmt.Errorf("failed to let go deployment of %s: %v", item.ServiceName, err)
+			}
+			item.Released = true
+			children[child.ID] = child
+		}
+
+		// Also picks up deployments approved while being let go
+		child := children[item.DeploymentID]
+		if child.Status != models.StatusWaiting || child.Held {
+			continue
+		}
+		ready, err := deployments.Ready(ctx, child, "release", "let go by release "+release.ID.Hex())
+		if err != nil && !errors.Is(err, deployments.ErrInvalidTransition) {
+			return fmt.Errorf("failed to start deployment of %s: %v", item.ServiceName, err)
+		}
+		if err == nil {
+			item.Status = ready.Status
+		}
+	}
+	return nil
+}
+
+// nextItems returns the indexes of the items to let go now: those not yet let
+// go of the earliest wave that isn't done, as many as MaxParallel allows
+// alongside the ones in progress
+func nextItems(release *models.Release) []int {
+	wave := -1
+	active := 0
+	for _, item := range release.Items {
+		if item.Status.IsFinal() {
+			continue
+		}
+		if wave == -1 || item.Wave < wave {
+			wave = item.Wave
+		}
+		if item.Released {
+			active++
+		}
+	}
+
+	var next []int
+	for i, item := range release.Items {
+		if item.Status.IsFinal() || item.Released || item.Wave != wave {
+			continue
+		}
+		if release.MaxParallel > 0 && active >= release.MaxParallel {
+			break
+		}
+		next = append(next, i)
+		active++
+	}
+	return next
+}
+
+// stop cancels the deployments of a release that haven't started. Those
+// already on their way are left to finish.
+func stop(ctx context.Context, release *models.Release, reason string) {
+	for i := range release.Items {
+		item := &release.Items[i]
+		if item.Status != models.StatusWaiting && item.Status != models.StatusAwaitingApproval && item.Status != models.StatusQueued {
+			continue
+		}
+		canceled, err := deployments.Transition(ctx, item.DeploymentID, deployments.Update{
+			Status:        models.StatusFailed,
+			Source:        "release",
+			From:          []models.DeploymentStatus{models.StatusWaiting, models.StatusAwaitingApproval, models.StatusQueued},
+			FailureReason: "canceled: " + reason,
+		})
+		if err != nil {
+			if !errors.Is(err, deployments.ErrInvalidTransition) {
+				log.Printf("Failed to cancel deployment %s of release %s: %v", item.DeploymentID.Hex(), release.ID.Hex(), err)
+			}
+			continue
+		}
+		item.Status = canceled.Status
+	}
+}
+
+// Summarize counts a release's deployments by how far they got
+func Summarize(release *models.Release) {
+	summary := models.ReleaseSummary{Total: len(release.Items)}
+	for _, item := range release.Items {
+		switch {
+		case item.Status == models.StatusSucceeded:
+			summary.Succeeded++
+		case item.Status == models.StatusFailed:
+			summary.Failed++
+		case item.Released && slices.Contains(inProgress, item.Status):
+			summary.InProgress++
+		default:
+			summary.Waiting++
+		}
+	}
+	release.Summary = summary
+}
+
+func findDeployments(ctx context.Context, releaseID bson.ObjectID) (map[bson.ObjectID]models.Deployment, error) {
+	cursor, err := config.GetCollection("deployments").Find(ctx, bson.M{"release_id": releaseID})
+	if err != nil {
+		return nil, err
+	}
+	var children []models.Deployment
+	if err := cursor.All(ctx, &children); err != nil {
+		return nil, err
+	}
+	byID := make(map[bson.ObjectID]models.Deployment, len(children))
+	for _, child := range children {
+		byID[child.ID] = child
+	}
+	return byID, nil
+}
@@ -0,0 +1,218 @@
+package releases
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/models"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// useTestDB connects to the MongoDB at TEST_MONGO_URI with a database of its
+// own, dropped afterwards. Tests that need MongoDB are skipped without it.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI not set")
+	}
+	previous := config.App.Mongo
+	config.App.Mongo.URI = uri
+	config.App.Mongo.Database = "deployment_test_" + bson.NewObjectID().Hex()
+	config.ConnectDB()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		config.MongoClient.Database(config.App.Mongo.Database).Drop(ctx)
+		config.MongoClient.Disconnect(ctx)
+		config.MongoClient = nil
+		config.App.Mongo = previous
+	})
+}
+
+// item is a release item of a wave in a status, let go or not
+func item(wave int, status models.DeploymentStatus, released bool) models.ReleaseItem {
+	return models.ReleaseItem{Wave: wave, Status: status, Released: released}
+}
+
+func TestNextItems(t *testing.T) {
+	tests := []struct {
+		name        string
+		items       []models.ReleaseItem
+		maxParallel int
+		want        []int
+	}{
+		{
+			name:  "first wave",
+			items: []models.ReleaseItem{item(0, models.StatusWaiting, false), item(1, models.StatusWaiting, false), item(0, models.StatusWaiting, false)},
+			want:  []int{0, 2},
+		},
+		{
+			name:  "holds the next wave while one is in progress",
+			items: []models.ReleaseItem{item(0, models.StatusSucceeded, true), item(0, models.StatusRunning, true), item(1, models.StatusWaiting, false)},
+		},
+		{
+			name:  "holds the next wave while one awaits approval",
+			items: []models.ReleaseItem{item(0, models.StatusAwaitingApproval, true), item(1, models.StatusWaiting, false)},
+		},
+		{
+			name:  "next wave once the earlier ones are done",
+			items: []models.ReleaseItem{item(0, models.StatusSucceeded, true), item(1, models.StatusSucceeded, true), item(2, models.StatusWaiting, false), item(3, models.StatusWaiting, false)},
+			want:  []int{2},
+		},
+		{
+			name:  "a failed deployment is done",
+			items: []models.ReleaseItem{item(0, models.StatusFailed, true), item(0, models.StatusSucceeded, true), item(1, models.StatusWaiting, false)},
+			want:  []int{2},
+		},
+		{
+			name:        "as many as MaxParallel allows",
+			items:       []models.ReleaseItem{item(0, models.StatusWaiting, false), item(0, models.StatusWaiting, false), item(0, models.StatusWaiting, false)},
+			maxParallel: 2,
+			want:        []int{0, 1},
+		},
+		{
+			name:        "counting those in progress",
+			items:       []models.ReleaseItem{item(0, models.StatusRunning, true), item(0, models.StatusWaiting, false), item(0, models.StatusWaiting, false)},
+			maxParallel: 2,
+			want:        []int{1},
+		},
+		{
+			name:        "none while MaxParallel are in progress",
+			items:       []models.ReleaseItem{item(0, models.StatusRunning, true), item(0, models.StatusPending, true), item(0, models.StatusWaiting, false)},
+			maxParallel: 2,
+		},
+		{
+			name:  "all done",
+			items: []models.ReleaseItem{item(0, models.StatusSucceeded, true), item(1, models.StatusFailed, true)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := &models.Release{Items: tt.items, MaxParallel: tt.maxParallel}
+			if got := nextItems(release); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("nextItems = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	release := &models.Release{Items: []models.ReleaseItem{
+		item(0, models.StatusSucceeded, true),
+		item(0, models.StatusFailed, true),
+		item(1, models.StatusRunning, true),
+		item(1, models.StatusAwaitingApproval, true),
+		item(2, models.StatusWaiting, false),
+	}}
+	Summarize(release)
+	want := models.ReleaseSummary{Total: 5, Waiting: 2, InProgress: 1, Succeeded: 1, Failed: 1}
+	if release.Summary != want {
+		t.Fatalf("summary = %+v, want %+v", release.Summary, want)
+	}
+}
+
+// createRelease stores a running release with a deployment for each item
+func createRelease(t *testing.T, onFailure string, items ...models.ReleaseItem) models.Release {
+	t.Helper()
+	ctx := context.Background()
+	release := models.Release{
+		ID:        bson.NewObjectID(),
+		ProjectID: bson.NewObjectID(),
+		Status:    models.ReleaseRunning,
+		OnFailure: onFailure,
+		Changelog: []models.ChangelogEntry{},
+		CreatedAt: time.Now(),
+	}
+	for i, item := range items {
+		item.ServiceID = bson.NewObjectID()
+		item.ServiceName = "service-" + string(rune('a'+i))
+		item.DeploymentID = bson.NewObjectID()
+		deployment := models.Deployment{
+			ID:        item.DeploymentID,
+			ServiceID: item.ServiceID,
+			Status:    item.Status,
+			ReleaseID: release.ID,
+			Held:      !item.Released,
+		}
+		if _, err := config.GetCollection("deployments").InsertOne(ctx, deployment); err != nil {
+			t.Fatal(err)
+		}
+		release.Items = append(release.Items, item)
+	}
+	if _, err := config.GetCollection("releases").InsertOne(ctx, release); err != nil {
+		t.Fatal(err)
+	}
+	return release
+}
+
+func TestAdvanceStopsAfterFailure(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+	release := createRelease(t, models.OnFailureStop,
+		item(0, models.StatusFailed, true),
+		item(0, models.StatusRunning, true),
+		item(1, models.StatusWaiting, false),
+	)
+
+	if err := Advance(ctx, release.ID); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+
+	var advanced models.Release
+	if err := config.GetCollection("releases").FindOne(ctx, bson.M{"_id": release.ID}).Decode(&advanced); err != nil {
+		t.Fatal(err)
+	}
+	held := advanced.Items[2]
+	if held.Released || held.Status != models.StatusFailed {
+		t.Fatalf("later wave: released %v, status %s; want it canceled without being let go", held.Released, held.Status)
+	}
+	if advanced.Items[1].Status != models.StatusRunning {
+		t.Fatalf("deployment in progress is %s, want it left running", advanced.Items[1].Status)
+	}
+	if advanced.Status != models.ReleaseRunning {
+		t.Fatalf("release is %s, want Running until the running deployment is done", advanced.Status)
+	}
+
+	var canceled models.Deployment
+	if err := config.GetCollection("deployments").FindOne(ctx, bson.M{"_id": held.DeploymentID}).Decode(&canceled); err != nil {
+		t.Fatal(err)
+	}
+	if !canceled.Held || !strings.HasPrefix(canceled.FailureReason, "canceled: release stopped after service-a failed") {
+		t.Fatalf("later wave's deployment: held %v, reason %q", canceled.Held, canceled.FailureReason)
+	}
+}
+
+func TestAdvanceFinishes(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+	release := createRelease(t, models.OnFailureContinue,
+		item(0, models.StatusSucceeded, true),
+		item(1, models.StatusRunning, true),
+	)
+	if _, err := config.GetCollection("deployments").UpdateOne(ctx,
+		bson.M{"_id": release.Items[1].DeploymentID},
+		bson.M{"$set": bson.M{"status": models.StatusFailed}},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Advance(ctx, release.ID); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	var finished models.Release
+	if err := config.GetCollection("releases").FindOne(ctx, bson.M{"_id": release.ID}).Decode(&finished); err != nil {
+		t.Fatal(err)
+	}
+	if finished.Status != models.ReleaseFailed || finished.FinishedAt == nil || finished.FailureReason != "1 of 2 deployments failed" {
+		t.Fatalf("release is %s (%q), finished at %v", finished.Status, finished.FailureReason, finished.FinishedAt)
+	}
+	if !finished.LockUntil.IsZero() {
+		t.Fatal("release is still locked after advancing")
+	}
+}
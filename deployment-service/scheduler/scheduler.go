@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"context"
+	"deployment-service/config"
+	"deployment-service/deployments"
+	"deployment-service/models"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	// lockLease is how long a replica owns a deployment it claimed
+	lockLease = 2 * time.Minute
+	// maxPerPass bounds how many deployments one pass releases
+	maxPerPass = 100
+)
+
+// Start creates the indexes waiting deployments are claimed by and, every
+// SCHEDULER_INTERVAL, fires scheduled deployments whose time has come and
+// releases queued deployments whose freeze ended. Both are persisted, so
+// nothing is lost when a replica restarts.
+func Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := config.GetCollection("deployments").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "queue.until", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "scheduled_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.App.Scheduler.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx := context.Background()
+			if err := release(ctx, models.StatusScheduled, "scheduled_at", "schedule_lock_until", "scheduled time reached"); err != nil {
+				log.Printf("Deployment scheduler failed: %v", err)
+			}
+			if err := release(ctx, models.StatusQueued, "queue.until", "queue.lock_until", "freeze ended"); err != nil {
+				log.Printf("Deployment scheduler failed: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// release claims deployments in status whose dueField has passed one at a
+// time and moves them on. Claiming sets lockField, which other replicas
+// respect until it expires, so each deployment is fired by one replica; the
+// conditional status change makes firing it twice harmless anyway. Freezes
+// and deployments of the same service in progress are checked again at this
+// point, so a deployment may be Queued, or stay Scheduled, instead.
+func release(ctx context.Context, status models.DeploymentStatus, dueField, lockField, message string) error {
+	collection := config.GetCollection("deployments")
+
+	for i := 0; i < maxPerPass; i++ {
+		now := time.Now()
+		var deployment models.Deployment
+		err := collection.FindOneAndUpdate(ctx,
+			bson.M{
+				"status":  status,
+				dueField:  bson.M{"$lte": now},
+				lockField: bson.M{"$not": bson.M{"$gt": now}},
+			},
+			bson.M{"$set": bson.M{lockField: now.Add(lockLease)}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&deployment)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		releaseCtx, cancel := context.WithTimeout(ctx, lockLease/2)
+		released, err := deployments.Ready(releaseCtx, deployment, "scheduler", message)
+		cancel()
+		switch {
+		case errors.Is(err, deployments.ErrInvalidTransition):
+		case err != nil:
+			log.Printf("Failed to release %s deployment %s: %v", status, deployment.ID.Hex(), err)
+		case released.Status == models.StatusQueued:
+			log.Printf("Deployment %s is queued until %s", deployment.ID.Hex(), released.Queue.Until.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CertReloader holds a certificate, its key and a CA bundle loaded from disk.
+// Watch polls the files and swaps in rotated certificates without a restart.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	caPool   *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+// NewCertReloader loads the certificate, key and optional CA bundle
+func NewCertReloader(certFile, keyFile, caFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		modTimes: map[string]time.Time{},
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+func (r *CertReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %v", err)
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle: %v", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("CA bundle contains no certificates")
+		}
+	}
+
+	modTimes := map[string]time.Time{}
+	for _, file := range r.files() {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.caPool = pool
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *CertReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch checks the files every interval and reloads them when they change.
+// A failed reload keeps serving the previous certificate.
+func (r *CertReloader) Watch(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if !r.changed() {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				log.Printf("TLS certificate reload failed, keeping previous certificate: %v", err)
+				continue
+			}
+			log.Println("TLS certificate reloaded")
+		}
+	}()
+}
+
+func (r *CertReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.caPool
+}
+
+// ServerConfig returns a server TLS config that always presents the current
+// certificate and verifies client certificates against the current CA bundle
+func (r *CertReloader) ServerConfig(clientAuth tls.ClientAuthType) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientAuth:   clientAuth,
+				ClientCAs:    pool,
+			}, nil
+		},
+	}
+}
+
+// ParseClientAuth maps the TLS_CLIENT_AUTH setting to a tls.ClientAuthType
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(mode) {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unknown client auth mode %q (expected none, request or require)", mode)
+	}
+}
+
+// PeerSANs returns the DNS and URI subject alternative names of a certificate
+func PeerSANs(cert *x509.Certificate) []string {
+	sans := slices.Clone(cert.DNSNames)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
+// VerifiedPeerSAN returns the first SAN of a verified client certificate that
+// appears in the allow list
+func VerifiedPeerSAN(state *tls.ConnectionState, allowed []string) (string, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	for _, san := range PeerSANs(state.VerifiedChains[0][0]) {
+		if slices.Contains(allowed, san) {
+			return san, true
+		}
+	}
+	return "", false
+}
+
+// ClientConfig returns a client TLS config that presents the current
+// certificate and verifies servers against the current CA bundle. Server
+// verification is done in VerifyConnection so a rotated CA bundle applies to
+// new connections.
+func (r *CertReloader) ClientConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		},
+		// Verification happens in VerifyConnection below
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			_, pool := r.current()
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("server presented no certificate")
+			}
+			opts := x509.VerifyOptions{
+				Roots:         pool,
+				DNSName:       cs.ServerName,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		},
+	}
+}
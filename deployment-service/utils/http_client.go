@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"deployment-service/tlsutil"
+	"net/http"
+	"time"
+)
+
+var certReloader *tlsutil.CertReloader
+
+// SetCertReloader makes calls to core-service present the service certificate
+// and verify core-service against the configured CA bundle
+func SetCertReloader(reloader *tlsutil.CertReloader) {
+	certReloader = reloader
+}
+
+// NewCoreServiceClient returns an HTTP client for calling core-service, using
+// mutual TLS when a certificate is configured
+func NewCoreServiceClient(timeout time.Duration) *http.Client {
+	client := &http.Client{
+		Timeout: timeout,
+	}
+	if certReloader != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = certReloader.ClientConfig()
+		client.Transport = transport
+	}
+	return client
+}
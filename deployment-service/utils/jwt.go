@@ -1,26 +1,58 @@
 package utils
 
 import (
+	"crypto/rand"
 	"deployment-service/config"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Claims represents the JWT claims structure
+// Claims represents the JWT claims structure. RegisteredClaims.ID carries the
+// jti, which lets core-service revoke a single token or refuse to accept it twice.
+// Scope, Roles and ClientID are only set on tokens issued by core-service.
+// Legacy is set on tokens signed with the shared JWT secret.
 type Claims struct {
-	ServiceName string `json:"service_name"`
+	ServiceName string   `json:"service_name"`
+	ClientID    string   `json:"client_id,omitempty"`
+	Scope       string   `json:"scope,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Legacy      bool     `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// HasScope reports whether the token was granted a scope
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+// Identity names the caller a token was issued to: the OAuth client, or the
+// service for legacy shared-secret tokens
+func (c *Claims) Identity() string {
+	if c.Subject != "" {
+		return c.Subject
+	}
+	return c.ServiceName
+}
+
 // GenerateServiceToken creates a JWT token for service-to-service communication
 func GenerateServiceToken() (string, error) {
 	// Token valid for 1 hour
 	expirationTime := time.Now().Add(1 * time.Hour)
 
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %v", err)
+	}
+
 	claims := &Claims{
 		ServiceName: config.ServiceName,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -29,7 +61,7 @@ func GenerateServiceToken() (string, error) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(config.JWTSecret)
+	tokenString, err := token.SignedString(config.JWTSecret())
 	if err != nil {
 		return "", err
 	}
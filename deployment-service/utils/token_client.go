@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"deployment-service/config"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenClient obtains access tokens from the core-service OAuth2 issuer using
+// the client-credentials grant and caches them until shortly before expiry
+type TokenClient struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scope        string
+	HTTPClient   *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// refreshMargin is how long before expiry a cached token is replaced
+const refreshMargin = 30 * time.Second
+
+var defaultTokenClient *TokenClient
+var defaultTokenClientMu sync.Mutex
+
+// Token returns a cached access token, fetching a new one when needed
+func (c *TokenClient) Token() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Add(refreshMargin).Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if c.Scope != "" {
+		form.Set("scope", c.Scope)
+	}
+
+	req, err := http.NewRequest("POST", c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(c.ClientID, c.ClientSecret)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach token endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("token endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %v", err)
+	}
+	if tokenResponse.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint returned no access token")
+	}
+
+	c.token = tokenResponse.AccessToken
+	c.expiresAt = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	return c.token, nil
+}
+
+// GetServiceToken returns a token for calling core-service: an OAuth2 access
+// token when client credentials are configured, otherwise a self-signed token
+func GetServiceToken() (string, error) {
+	clientID, clientSecret := config.OAuthClientCredentials()
+	if clientID == "" {
+		return GenerateServiceToken()
+	}
+
+	// Start over with a fresh client when reloaded credentials differ
+	tokenURL := config.OAuthTokenURL()
+	defaultTokenClientMu.Lock()
+	if defaultTokenClient == nil || defaultTokenClient.TokenURL != tokenURL ||
+		defaultTokenClient.ClientID != clientID || defaultTokenClient.ClientSecret != clientSecret {
+		defaultTokenClient = &TokenClient{
+			TokenURL:     tokenURL,
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Scope:        config.App.OAuth.Scope,
+			HTTPClient:   NewCoreServiceClient(10 * time.Second),
+		}
+	}
+	client := defaultTokenClient
+	defaultTokenClientMu.Unlock()
+
+	return client.Token()
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"crypto/rsa"
+	"deployment-service/config"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksRefreshInterval bounds how often core-service's key set is fetched,
+// both on schedule and when a token names an unknown key
+const jwksRefreshInterval = 5 * time.Minute
+
+// jwksCache holds the RS256 public keys published by core-service
+type jwksCache struct {
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+var coreKeys = &jwksCache{}
+
+// key returns the public key for a kid, refreshing the key set when it is
+// stale or doesn't know the kid yet
+func (c *jwksCache) key(kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, ok := c.keys[kid]
+	if ok && time.Since(c.fetchedAt) < jwksRefreshInterval {
+		return key, nil
+	}
+	if !ok && time.Since(c.fetchedAt) < time.Second {
+		return nil, errors.New("unknown signing key")
+	}
+
+	keys, err := fetchJWKS()
+	if err != nil {
+		if ok {
+			// Keep using a known key while core-service is unreachable
+			return key, nil
+		}
+		return nil, err
+	}
+	c.keys = keys
+	c.fetchedAt = time.Now()
+
+	if key, ok = c.keys[kid]; !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	return key, nil
+}
+
+// fetchJWKS downloads core-service's JSON Web Key Set
+func fetchJWKS() (map[string]*rsa.PublicKey, error) {
+	client := NewCoreServiceClient(10 * time.Second)
+	resp, err := client.Get(config.CoreServiceURL() + "/.well-known/jwks.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signing key endpoint returned status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode signing keys: %v", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// ParseServiceToken verifies a bearer token issued by core-service (RS256) or
+// signed with the shared JWT secret (HS256). Anyone with the shared secret
+// can sign any claims, so a shared-secret token always identifies this
+// service and carries no roles or scopes.
+func ParseServiceToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA:
+			kid, _ := token.Header["kid"].(string)
+			return coreKeys.key(kid)
+		case *jwt.SigningMethodHMAC:
+			// Accept the previous secret during its rotation grace period
+			keys := jwt.VerificationKeySet{}
+			for _, secret := range config.JWTSecrets() {
+				keys.Keys = append(keys.Keys, secret)
+			}
+			return keys, nil
+		default:
+			return nil, jwt.ErrSignatureInvalid
+		}
+	}, jwt.WithValidMethods([]string{"RS256", "HS256"}))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("token is not valid")
+	}
+
+	if token.Method.Alg() == "RS256" {
+		if claims.Issuer != config.App.OAuth.Issuer {
+			return nil, errors.New("token has an unexpected issuer")
+		}
+		return claims, nil
+	}
+
+	claims.Legacy = true
+	claims.Subject = ""
+	claims.ServiceName = config.ServiceName
+	claims.ClientID = ""
+	claims.Scope = ""
+	claims.Roles = nil
+	return claims, nil
+}